@@ -1,9 +0,0 @@
-package main
-
-import (
-	"github.com/jaxsax/ntu-room-finder/internal/downloader"
-)
-
-func main() {
-	downloader.Download()
-}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/analytics"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+)
+
+func runAnalytics(args []string) error {
+	fs := newFlagSet("analytics", "",
+		"Reports how much each venue or building is booked in an average teaching week,\n"+
+			"per day, per hour of the day and per session type, least used first.\n"+
+			"Only hours within the opening hours of the rooms config count.\n"+
+			"-format heatmap writes the utilization of every hour of the day as a JSON matrix.")
+	var common commonFlags
+	common.register(fs)
+	format := fs.String("format", "table", "one of "+strings.Join(analytics.Formats, ", "))
+	by := fs.String("by", string(analytics.Venue), "group by venue or building")
+	days := fs.String("days", "MON,TUE,WED,THU,FRI", "comma separated days that count")
+	out := fs.String("out", "-", "output path, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := common.setup(nil); err != nil {
+		return err
+	}
+
+	level := analytics.Level(*by)
+	if level != analytics.Venue && level != analytics.Building {
+		return fmt.Errorf("-by must be venue or building, got %q", *by)
+	}
+	var weekdays []parser.Weekday
+	for _, day := range strings.Split(*days, ",") {
+		weekday, err := parser.ParseWeekday(strings.TrimSpace(day))
+		if err != nil {
+			return err
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	hours, err := openingHours(common.cfg.Rooms)
+	if err != nil {
+		return err
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	idx := occupancy.New(s)
+	idx.SetOpeningHours(hours)
+	report := analytics.Compute(idx, weekdays)
+
+	f, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return analytics.Write(f, *format, report, level)
+}
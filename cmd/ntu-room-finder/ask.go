@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/bot"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"strings"
+)
+
+func runAsk(args []string) error {
+	fs := newFlagSet("ask", "<message>",
+		"Answers a message the way the chat bots do, e.g.\n"+
+			"  ask free rooms in N4 now\n"+
+			"  ask room LT2A mon\n"+
+			"  ask index 10105")
+	var common commonFlags
+	common.register(fs)
+	registry := fs.String("registry", "", "CSV file of room capacities and buildings, see docs/rooms.csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("give a message, e.g. free rooms in N4 now")
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"registry": func(c *config.Config) string { return c.Rooms.Registry },
+	})
+	if err != nil {
+		return err
+	}
+
+	var registered *rooms.Registry
+	if *registry != "" {
+		if registered, err = rooms.Load(*registry); err != nil {
+			return err
+		}
+	}
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	fmt.Println(bot.New(s, occupancy.New(s), registered).Answer(strings.Join(fs.Args(), " ")))
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"os"
+	"text/tabwriter"
+)
+
+var errClashes = errors.New("indexes clash")
+
+func runClashes(args []string) error {
+	fs := newFlagSet("clashes", "<index>...",
+		"Lists every pair of sessions of the given indexes that are held at the\n"+
+			"same time, with the teaching weeks they share. Exits with an error\n"+
+			"when any clash is found, e.g. to check an index swap before\n"+
+			"registration. Give at most one index of each subject.")
+	var common commonFlags
+	common.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := common.setup(nil); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("expected at least two index numbers")
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	indexes, err := planner.FindIndexes(s.Subjects(), fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	clashes, err := planner.Clashes(indexes...)
+	if err != nil {
+		return err
+	}
+	if len(clashes) == 0 {
+		fmt.Println("no clashes")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range clashes {
+		fmt.Fprintf(w, "%s\t%s-%s\tweeks %s\t%s %s %s\t%s\t%s %s %s\t%s\n",
+			c.Day, c.Start, c.End, c.Weeks,
+			c.A.SubjectId, c.A.Index, c.A.Type, c.A.Venue,
+			c.B.SubjectId, c.B.Index, c.B.Type, c.B.Venue)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %d clashes", errClashes, len(clashes))
+}
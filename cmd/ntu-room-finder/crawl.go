@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runCrawl(args []string) error {
+	fs := newFlagSet("crawl", "",
+		"Downloads the course list and every course schedule into a snapshot folder\n"+
+			"named after today's date inside -data-dir, suffixed with the semester when\n"+
+			"one is given.\n\n"+
+			"Subjects that are not listed under any course, such as some electives,\n"+
+			"can be looked up by code with -subjects or -subjects-file, or found with\n"+
+			"-discover which searches the codes named in prerequisites and mutually\n"+
+			"exclusive lists. Results are merged into the snapshot, subjects that are\n"+
+			"already in it are not added again. -search-only skips the course crawl\n"+
+			"and searches into -snapshot or the latest snapshot.")
+	var common commonFlags
+	common.register(fs)
+	semesters := fs.String("semester", "", "comma separated acadsem keys to crawl, e.g. \"2018;1\" (default: latest)")
+	concurrency := fs.Int("concurrency", 1, "number of courses downloaded at once")
+	delay := fs.Duration("delay", time.Second, "pause between requests of a worker")
+	subjects := fs.String("subjects", "", "comma separated subject codes to look up, e.g. CZ2001,HE9091")
+	subjectsFile := fs.String("subjects-file", "", "file with one subject code to look up per line")
+	discover := fs.Bool("discover", false, "look up subject codes referenced by crawled subjects")
+	searchOnly := fs.Bool("search-only", false, "only look up subjects, into an existing snapshot")
+	exams := fs.Bool("exams", false, "also download the exam timetable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"semester":    func(c *config.Config) string { return strings.Join(c.Semesters, ",") },
+		"concurrency": func(c *config.Config) string { return strconv.Itoa(c.Crawl.Concurrency) },
+		"delay":       func(c *config.Config) string { return c.Crawl.Delay.String() },
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{""}
+	if *semesters != "" {
+		keys = strings.Split(*semesters, ",")
+	}
+	if len(keys) > 1 && common.snapshot != "" {
+		return errors.New("-snapshot can only be used with a single semester")
+	}
+
+	codes, err := subjectCodes(*subjects, *subjectsFile)
+	if err != nil {
+		return err
+	}
+	search := len(codes) > 0 || *discover
+
+	if *searchOnly {
+		if !search {
+			return errors.New("-search-only needs -subjects, -subjects-file or -discover")
+		}
+		if len(keys) > 1 {
+			return errors.New("-search-only can only be used with a single semester")
+		}
+		folder, err := common.snapshotPath()
+		if err != nil {
+			return err
+		}
+		return searchSubjects(folder, strings.TrimSpace(*semesters), codes, *discover, *concurrency, *delay)
+	}
+
+	for _, key := range keys {
+		opts := downloader.Options{
+			DataDir:     common.dataDir,
+			Snapshot:    common.snapshot,
+			Semester:    strings.TrimSpace(key),
+			Concurrency: *concurrency,
+			Delay:       *delay,
+		}
+		if err := downloader.Download(opts); err != nil {
+			return err
+		}
+		if *exams {
+			if err := downloader.DownloadExams(downloader.ExamOptions{Snapshot: opts.Folder()}); err != nil {
+				return err
+			}
+		}
+		if search {
+			err := searchSubjects(opts.Folder(), opts.Semester, codes, *discover, *concurrency, *delay)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the codes given with -subjects and -subjects-file
+func subjectCodes(list, path string) ([]string, error) {
+	var codes []string
+	if list != "" {
+		codes = append(codes, strings.Split(list, ",")...)
+	}
+	if path != "" {
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				codes = append(codes, line)
+			}
+		}
+	}
+	return codes, nil
+}
+
+func searchSubjects(folder, semester string, codes []string, discover bool,
+	concurrency int, delay time.Duration) error {
+
+	s, err := snapshot.Load(folder)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for id := range s.Subjects() {
+		known[id] = true
+	}
+	if discover {
+		referenced := s.ReferencedSubjects()
+		logging.Infof("found %d referenced subjects missing from the snapshot", len(referenced))
+		codes = append(codes, referenced...)
+	}
+
+	return downloader.Search(downloader.SearchOptions{
+		Snapshot:    folder,
+		Semester:    semester,
+		Codes:       codes,
+		Known:       known,
+		Concurrency: concurrency,
+		Delay:       delay,
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+)
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "<old-snapshot> <new-snapshot>",
+		"Prints the sessions added (+) or removed (-) between two snapshot folders.")
+	var common commonFlags
+	common.registerConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := common.setup(nil); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected 2 snapshot folders, got %d", fs.NArg())
+	}
+
+	before, err := snapshot.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := snapshot.Load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	for _, change := range snapshot.Diff(before, after) {
+		fmt.Println(change)
+	}
+	return nil
+}
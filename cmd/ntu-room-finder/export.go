@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/export"
+	"strings"
+)
+
+func runExport(args []string) error {
+	fs := newFlagSet("export", "",
+		"Writes every course, subject and session of a snapshot as JSON or CSV.")
+	var common commonFlags
+	common.register(fs)
+	format := fs.String("format", "json", "one of "+strings.Join(export.Formats, ", "))
+	out := fs.String("out", "-", "output path, - for stdout, falls back to output.json or output.csv of the config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"out": func(c *config.Config) string {
+			switch *format {
+			case "json":
+				return orStdout(c.Output.JSON)
+			case "csv":
+				return orStdout(c.Output.CSV)
+			}
+			return "-"
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	f, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return export.Write(f, *format, s)
+}
+
+func orStdout(path string) string {
+	if path == "" {
+		return "-"
+	}
+	return path
+}
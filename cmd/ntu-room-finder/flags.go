@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"io"
+	"os"
+)
+
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "usage: %s %s [flags] %s\n\n%s\n\nflags:\n", program, name, args, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Flags shared by every command
+type commonFlags struct {
+	fs         *flag.FlagSet
+	configPath string
+	dataDir    string
+	snapshot   string
+	logLevel   string
+
+	cfg *config.Config
+	set map[string]bool
+}
+
+// Registers -config and -log-level, used by commands that do not read
+// from the data folder
+func (c *commonFlags) registerConfig(fs *flag.FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.configPath, "config", "", "config file (default: $"+config.PathEnv+" or ./"+config.DefaultPath+")")
+	fs.StringVar(&c.logLevel, "log-level", "info", "one of debug, info, warn or error")
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	c.registerConfig(fs)
+	fs.StringVar(&c.dataDir, "data-dir", ".", "folder containing dated snapshot folders")
+	fs.StringVar(&c.snapshot, "snapshot", "", "snapshot folder to use (default: latest in -data-dir)")
+}
+
+// Loads the config and fills every flag missing from the command line
+// with its config value, so flags win over environment variables which
+// win over the config file. fromConfig maps flag names to config values.
+func (c *commonFlags) setup(fromConfig map[string]func(*config.Config) string) error {
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		return err
+	}
+	c.cfg = cfg
+
+	c.set = make(map[string]bool)
+	c.fs.Visit(func(f *flag.Flag) {
+		c.set[f.Name] = true
+	})
+
+	values := map[string]func(*config.Config) string{
+		"data-dir":  func(cfg *config.Config) string { return cfg.DataDir },
+		"log-level": func(cfg *config.Config) string { return cfg.LogLevel },
+	}
+	for name, value := range fromConfig {
+		values[name] = value
+	}
+	for name, value := range values {
+		if c.set[name] || c.fs.Lookup(name) == nil {
+			continue
+		}
+		if err := c.fs.Set(name, value(cfg)); err != nil {
+			return fmt.Errorf("-%s: %v", name, err)
+		}
+	}
+
+	level, err := logging.ParseLevel(c.logLevel)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+	return nil
+}
+
+func (c *commonFlags) snapshotPath() (string, error) {
+	if c.snapshot != "" {
+		return c.snapshot, nil
+	}
+	return snapshot.Latest(c.dataDir)
+}
+
+func (c *commonFlags) load() (*snapshot.Snapshot, error) {
+	path, err := c.snapshotPath()
+	if err != nil {
+		return nil, err
+	}
+	logging.Infof("loading snapshot %s", path)
+	return snapshot.Load(path)
+}
+
+// Stdout as returned by createOutput, closing it does nothing so output
+// can still be written after
+type stdout struct {
+	io.Writer
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+// Opens path for writing, "-" means stdout
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return stdout{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/grid"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"strings"
+)
+
+func runGrid(args []string) error {
+	fs := newFlagSet("grid", "",
+		"Draws the week of -room or of the sessions of -indexes as a grid of days and\n"+
+			"half hour slots, for the terminal, as HTML or as SVG. Overlapping sessions\n"+
+			"are drawn side by side and sessions not held every teaching week are\n"+
+			"marked with their weeks, e.g. Wk2-13.")
+	var common commonFlags
+	common.register(fs)
+	room := fs.String("room", "", "venue to draw, e.g. LT2A")
+	indexes := fs.String("indexes", "", "comma separated index numbers to draw, e.g. 10105,00731")
+	format := fs.String("format", "text", "one of "+strings.Join(grid.Formats, ", "))
+	out := fs.String("out", "-", "output path, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*room == "") == (*indexes == "") {
+		return fmt.Errorf("give either -room or -indexes")
+	}
+	if err := common.setup(nil); err != nil {
+		return err
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	var g *grid.Grid
+	if *room != "" {
+		bookings := occupancy.New(s).Bookings(*room)
+		if len(bookings) == 0 {
+			return fmt.Errorf("%s has no bookings in %s", *room, s.Path)
+		}
+		g = grid.Room(*room, bookings)
+	} else {
+		var numbers []string
+		for _, number := range strings.Split(*indexes, ",") {
+			if number = strings.TrimSpace(number); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
+		found, err := planner.FindIndexes(s.Subjects(), numbers...)
+		if err != nil {
+			return err
+		}
+		g = grid.Indexes(strings.Join(numbers, ", "), found...)
+	}
+
+	f, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return grid.Write(f, *format, g)
+}
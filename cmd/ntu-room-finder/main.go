@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const program = "ntu-room-finder"
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"crawl", "download the class schedule into a snapshot folder", runCrawl},
+	{"parse", "parse a snapshot folder into SQL", runParse},
+	{"query", "list free rooms or the bookings of a room", runQuery},
+	{"plan", "find clash-free index combinations for subjects", runPlan},
+	{"clashes", "check a set of indexes for clashing sessions", runClashes},
+	{"grid", "draw the week of a room or of indexes", runGrid},
+	{"serve", "serve room queries over HTTP", runServe},
+	{"ask", "answer a chat message the way the bots do", runAsk},
+	{"diff", "show sessions that changed between two snapshots", runDiff},
+	{"export", "export a snapshot as JSON or CSV", runExport},
+	{"analytics", "report how much rooms and buildings are used", runAnalytics},
+}
+
+func usage() {
+	var b strings.Builder
+	fmt.Fprintf(&b, "usage: %s <command> [flags]\n\ncommands:\n", program)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(&b, "\nRun '%s <command> --help' for the flags of a command.\n", program)
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", program, name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", program, name)
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/parser"
+	"runtime"
+	"strconv"
+)
+
+func runParse(args []string) error {
+	fs := newFlagSet("parse", "",
+		"Parses every course page of a snapshot and writes the schedules as SQL.")
+	var common commonFlags
+	common.register(fs)
+	out := fs.String("out", "out.sql", "path of the generated SQL")
+	initSQL := fs.String("init-sql", "sql/init.sql", "schema written before the generated SQL")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of course pages parsed at once")
+	failOnError := fs.Bool("fail-on-error", false, "exit with an error when any course fails to parse")
+	lenient := fs.Bool("lenient", false, "skip schedule and exam rows that cannot be parsed instead of failing the course or timetable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"out":      func(c *config.Config) string { return c.Output.SQL },
+		"init-sql": func(c *config.Config) string { return c.Output.InitSQL },
+		"concurrency": func(c *config.Config) string {
+			if c.Parse.Concurrency == 0 {
+				return strconv.Itoa(runtime.NumCPU())
+			}
+			return strconv.Itoa(c.Parse.Concurrency)
+		},
+		"fail-on-error": func(c *config.Config) string { return strconv.FormatBool(c.Parse.FailOnError) },
+		"lenient":       func(c *config.Config) string { return strconv.FormatBool(c.Parse.Lenient) },
+	})
+	if err != nil {
+		return err
+	}
+
+	path, err := common.snapshotPath()
+	if err != nil {
+		return err
+	}
+	_, err = parser.Parse(parser.Options{
+		Snapshot:    path,
+		Output:      *out,
+		InitSQL:     *initSQL,
+		FailOnError: *failOnError,
+		CourseOptions: parser.CourseOptions{
+			Concurrency: *concurrency,
+			Lenient:     *lenient,
+		},
+	})
+	return err
+}
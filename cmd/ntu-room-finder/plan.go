@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func runPlan(args []string) error {
+	fs := newFlagSet("plan", "<subject code>...",
+		"Lists the clash-free combinations of one index per subject, cheapest\n"+
+			"first. Sessions held in different teaching weeks do not clash. Each\n"+
+			"early session and each hour between sessions adds to the cost of a\n"+
+			"plan and each weekday without sessions takes from it.")
+	var common commonFlags
+	common.register(fs)
+	notBefore := fs.String("not-before", "0930", "sessions starting before this time count as early, \"\" counts none")
+	early := fs.Float64("early", 1, "cost of each early session")
+	gap := fs.Float64("gap", 0.5, "cost of each hour between sessions on the same day")
+	freeDay := fs.Float64("free-day", 2, "taken off the cost for each weekday without sessions")
+	limit := fs.Int("limit", 10, "number of plans listed, 0 lists every plan")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"not-before": func(c *config.Config) string { return c.Planner.NotBefore },
+		"early":      func(c *config.Config) string { return formatFloat(c.Planner.Early) },
+		"gap":        func(c *config.Config) string { return formatFloat(c.Planner.Gap) },
+		"free-day":   func(c *config.Config) string { return formatFloat(c.Planner.FreeDay) },
+		"limit":      func(c *config.Config) string { return strconv.Itoa(c.Planner.Limit) },
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("expected at least one subject code")
+	}
+
+	opts, err := plannerOptions(config.Planner{
+		NotBefore: *notBefore, Early: *early, Gap: *gap, FreeDay: *freeDay, Limit: *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	all := s.Subjects()
+	var subjects []parser.Subject
+	for _, code := range fs.Args() {
+		subject, ok := all[strings.ToUpper(code)]
+		if !ok {
+			return fmt.Errorf("subject %s not found in %s", code, s.Path)
+		}
+		subjects = append(subjects, subject)
+	}
+
+	result, err := planner.Search(subjects, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d clash-free plans\n", result.Combinations)
+	for i, plan := range result.Plans {
+		fmt.Printf("\n#%d cost %s, %d early, %d min of gaps, free %s\n", i+1,
+			formatFloat(plan.Cost), plan.EarlySessions, plan.GapMinutes, joinWeekdays(plan.FreeDays))
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, index := range plan.Indexes {
+			for _, session := range index.Sessions {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", index.SubjectId, index.Index,
+					session.Type, session.Day, session.TimeText, session.Venue, session.Weeks())
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the ranking settings shared by the plan and serve commands
+func plannerOptions(c config.Planner) (planner.Options, error) {
+	opts := planner.Options{
+		Preferences: planner.Preferences{Early: c.Early, Gap: c.Gap, FreeDay: c.FreeDay},
+		Limit:       c.Limit,
+		MaxWork:     c.MaxWork,
+	}
+	if c.NotBefore != "" {
+		notBefore, err := occupancy.ParseClock(c.NotBefore)
+		if err != nil {
+			return opts, err
+		}
+		opts.NotBefore = notBefore
+	}
+	return opts, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func joinWeekdays(days []parser.Weekday) string {
+	if len(days) == 0 {
+		return "none"
+	}
+	names := make([]string, len(days))
+	for i, day := range days {
+		names[i] = string(day)
+	}
+	return strings.Join(names, ",")
+}
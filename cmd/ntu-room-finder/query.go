@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/campus"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runQuery(args []string) error {
+	now := time.Now()
+	fs := newFlagSet("query", "",
+		"Lists the rooms that are free for the whole of -from to -to on -day,\n"+
+			"or on -date which also takes the exam timetable into account,\n"+
+			"closest first with walking minutes when -near names a building or venue,\n"+
+			"or the weekly bookings of -room, or the AUs and sessions of -subject.\n"+
+			"With -room, -min-duration lists its free blocks on -day of at least that\n"+
+			"long and -next its next free slot after -from on -date or today.\n"+
+			"With -programme, -year or -type it lists matching sessions instead,\n"+
+			"e.g. -programme EEE -year 2 -type TUT for every year 2 EEE tutorial.")
+	var common commonFlags
+	common.register(fs)
+	day := fs.String("day", string(parser.WeekdayOf(now.Weekday())), "day of week, e.g. WED")
+	from := fs.String("from", fmt.Sprintf("%02d%02d", now.Hour(), now.Minute()), "start time, e.g. 0830")
+	to := fs.String("to", "", "end time, e.g. 1030 (default: an hour after -from)")
+	date := fs.String("date", "", "date instead of -day, e.g. 2018-11-19")
+	room := fs.String("room", "", "show the bookings of this venue instead")
+	minDuration := fs.Duration("min-duration", 0, "with -room, list its free blocks of at least this long, e.g. 2h")
+	next := fs.Bool("next", false, "with -room, show when it is next free")
+	near := fs.String("near", "", "rank free rooms by walking distance from this building or venue, e.g. NS4-05-37")
+	campusFile := fs.String("campus", "", "campus file with buildings and walking times, see docs/campus.yaml")
+	subjectId := fs.String("subject", "", "show this subject code instead, e.g. CZ2001")
+	programme := fs.String("programme", "", "list sessions of this programme, e.g. EEE")
+	year := fs.Int("year", 0, "list sessions of this year of study")
+	sessionType := fs.String("type", "", "list sessions of this type, e.g. TUT")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"campus": func(c *config.Config) string { return c.Rooms.Campus },
+	})
+	if err != nil {
+		return err
+	}
+	var buildings *campus.Campus
+	if *near != "" {
+		if *campusFile == "" {
+			return fmt.Errorf("-near needs a campus file, set -campus or rooms.campus")
+		}
+		if buildings, err = campus.Load(*campusFile); err != nil {
+			return err
+		}
+	}
+
+	weekday, err := parser.ParseWeekday(*day)
+	if err != nil {
+		return err
+	}
+	start, err := occupancy.ParseClock(*from)
+	if err != nil {
+		return err
+	}
+	end := start + 60
+	if *to != "" {
+		end, err = occupancy.ParseClock(*to)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return fmt.Errorf("%s is not after %s", end, start)
+		}
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+
+	if *programme != "" || *year != 0 || *sessionType != "" {
+		filter := snapshot.SessionFilter{Programme: *programme, Year: *year}
+		if *sessionType != "" {
+			// Unknown types are still matched as they appear on the page
+			filter.Type, _ = parser.ParseSessionType(*sessionType)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, session := range s.Sessions(filter) {
+			schedule := session.Schedule
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", session.Course.Text, session.SubjectId,
+				schedule.Index, schedule.Type, schedule.Group, schedule.Day, schedule.TimeText, schedule.Venue,
+				schedule.UID)
+		}
+		return w.Flush()
+	}
+
+	if *subjectId != "" {
+		subject, ok := s.Subjects()[strings.ToUpper(*subjectId)]
+		if !ok {
+			return fmt.Errorf("subject %s not found in %s", *subjectId, s.Path)
+		}
+		au := subject.AU.String()
+		if au == "" {
+			au = subject.AuRaw
+		}
+		fmt.Printf("%s %s (%s AU)\n", subject.Id, subject.Title, au)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, schedule := range subject.Schedules {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", schedule.Index, schedule.Type,
+				schedule.Group, schedule.Day, schedule.TimeText, schedule.Venue, schedule.UID)
+		}
+		return w.Flush()
+	}
+
+	hours, err := openingHours(common.cfg.Rooms)
+	if err != nil {
+		return err
+	}
+	idx := occupancy.New(s)
+	idx.SetOpeningHours(hours)
+
+	if *room != "" && *next {
+		after := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if *date != "" {
+			if after, err = time.ParseInLocation("2006-01-02", *date, time.Local); err != nil {
+				return err
+			}
+		}
+		after = after.Add(time.Duration(start) * time.Minute)
+		slot, ok := idx.NextFree(*room, after)
+		if !ok {
+			return fmt.Errorf("%s is not free in the two weeks after %s", *room, after.Format("2006-01-02 1504"))
+		}
+		fmt.Printf("%s %s-%s\n", slot.Start.Format("2006-01-02 Mon"), slot.Start.Format("1504"),
+			endClock(slot.Start, slot.End))
+		return nil
+	}
+
+	if *room != "" && *minDuration > 0 {
+		for _, b := range idx.FreeBlocks(*room, weekday, *minDuration) {
+			fmt.Printf("%s\t%s-%s\t%s\n", b.Day, b.Start, b.End, b.Duration())
+		}
+		return nil
+	}
+
+	if *room != "" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, b := range idx.Bookings(*room) {
+			fmt.Fprintf(w, "%s\t%s-%s\t%s\t%s\t%s\n",
+				b.Day, b.Start, b.End, b.SubjectId, b.Schedule.Index, b.Schedule.Type)
+		}
+		for _, b := range idx.Exams(*room) {
+			fmt.Fprintf(w, "%s\t%s-%s\t%s\tEXAM\n",
+				b.Start.Format("2006-01-02"), b.Start.Format("1504"), b.End.Format("1504"), b.Exam.SubjectId)
+		}
+		return w.Flush()
+	}
+
+	if *date != "" {
+		on, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			return err
+		}
+		return printRooms(idx.FreeOn(on, start, end), buildings, *near)
+	}
+
+	return printRooms(idx.Free(weekday, start, end), buildings, *near)
+}
+
+// Prints venues one a line, closest first with walking minutes when
+// buildings is set
+func printRooms(venues []string, buildings *campus.Campus, near string) error {
+	if buildings == nil {
+		for _, venue := range venues {
+			fmt.Println(venue)
+		}
+		return nil
+	}
+	distances, err := buildings.Rank(near, venues)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, d := range distances {
+		minutes := "-"
+		if d.Reachable {
+			minutes = fmt.Sprintf("%.0f min", d.Minutes)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Venue, minutes, d.Building)
+	}
+	return w.Flush()
+}
+
+// End of a slot as a clock, 2400 when it runs until midnight
+func endClock(start, end time.Time) string {
+	if end.Day() != start.Day() {
+		return "2400"
+	}
+	return end.Format("1504")
+}
+
+// Reads the building opening hours shared by the query and serve commands
+func openingHours(c config.Rooms) (occupancy.OpeningHours, error) {
+	hours := occupancy.OpeningHours{Buildings: make(map[string]occupancy.Hours)}
+	parse := func(open, close string) (occupancy.Hours, error) {
+		var h occupancy.Hours
+		var err error
+		if h.Open, err = occupancy.ParseClock(open); err != nil {
+			return h, err
+		}
+		h.Close, err = occupancy.ParseClock(close)
+		return h, err
+	}
+	var err error
+	if hours.Default, err = parse(c.Open, c.Close); err != nil {
+		return hours, err
+	}
+	for prefix, b := range c.Buildings {
+		if hours.Buildings[prefix], err = parse(b.Open, b.Close); err != nil {
+			return hours, err
+		}
+	}
+	return hours, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/bot"
+	"github.com/jaxsax/ntu-room-finder/internal/campus"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"github.com/jaxsax/ntu-room-finder/internal/server"
+	"github.com/jaxsax/ntu-room-finder/internal/swap"
+	"github.com/jaxsax/ntu-room-finder/internal/web"
+	"net/http"
+	"time"
+)
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "",
+		"Serves free room and room schedule queries over HTTP from a snapshot,\n"+
+			"along with the planner and index swap requests kept in -swap-db.\n"+
+			"The swap API trusts the user it is given, callers send swap.secret as a bearer token.\n"+
+			"The API is under /api/, everything else is a web page for finding free rooms.\n"+
+			"With bot.telegram.token set a Telegram bot takes updates on /bot/telegram.")
+	var common commonFlags
+	common.register(fs)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	campusFile := fs.String("campus", "", "campus file used to rank free rooms with near=, see docs/campus.yaml")
+	registry := fs.String("registry", "", "CSV file of room capacities and buildings, see docs/rooms.csv")
+	swapDB := fs.String("swap-db", "", "SQLite database of index swap requests, \"\" turns swaps off")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	err := common.setup(map[string]func(*config.Config) string{
+		"addr":     func(c *config.Config) string { return c.Server.Addr() },
+		"swap-db":  func(c *config.Config) string { return c.Swap.Database },
+		"campus":   func(c *config.Config) string { return c.Rooms.Campus },
+		"registry": func(c *config.Config) string { return c.Rooms.Registry },
+	})
+	if err != nil {
+		return err
+	}
+
+	plans, err := plannerOptions(common.cfg.Planner)
+	if err != nil {
+		return err
+	}
+
+	hours, err := openingHours(common.cfg.Rooms)
+	if err != nil {
+		return err
+	}
+
+	var registered *rooms.Registry
+	if *registry != "" {
+		if registered, err = rooms.Load(*registry); err != nil {
+			return err
+		}
+	}
+
+	s, err := common.load()
+	if err != nil {
+		return err
+	}
+	srv := server.New(s)
+	srv.Planner = plans
+	srv.SetOpeningHours(hours)
+	if *campusFile != "" {
+		if srv.Campus, err = campus.Load(*campusFile); err != nil {
+			return err
+		}
+	}
+	if *swapDB != "" {
+		if common.cfg.Swap.Secret == "" {
+			return errors.New("-swap-db needs swap.secret")
+		}
+		store, err := swap.Open(*swapDB)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		srv.Swaps = swap.NewService(store, s.Semester.Key, s.Subjects(), common.cfg.Swap.MaxCycle)
+		srv.SwapSecret = common.cfg.Swap.Secret
+	}
+	idx := occupancy.New(s)
+	idx.SetOpeningHours(hours)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", srv)
+	mux.Handle("/", web.New(s, idx, registered))
+	if telegram := common.cfg.Bot.Telegram; telegram.Token != "" {
+		handler := bot.NewTelegram(telegram.Token, bot.New(s, idx, registered))
+		handler.Secret = telegram.Secret
+		if telegram.WebhookURL != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := handler.SetWebhook(ctx, telegram.WebhookURL)
+			cancel()
+			if err != nil {
+				return err
+			}
+			logging.Infof("telegram webhook set to %s", telegram.WebhookURL)
+		}
+		mux.Handle("/bot/telegram", handler)
+	}
+
+	logging.Infof("listening on %s", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
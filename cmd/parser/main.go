@@ -1,9 +0,0 @@
-package main
-
-import (
-	"github.com/jaxsax/ntu-room-finder/internal/parser"
-)
-
-func main() {
-	parser.Parse("2018-09-13")
-}
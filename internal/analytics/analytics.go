@@ -0,0 +1,213 @@
+package analytics
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+)
+
+// How usage is grouped
+type Level string
+
+const (
+	Venue    Level = "venue"
+	Building Level = "building"
+)
+
+// Booked and open hours of a venue or building in an average teaching
+// week. Sessions held in some weeks only count for those weeks, sessions
+// sharing a room at the same time count once and only hours in which the
+// building is open count.
+type Usage struct {
+	Name  string
+	Hours float64
+	Open  float64
+	// Hours over Open, 0 when never open
+	Utilization float64
+	Days        map[parser.Weekday]float64
+	// Booked hours in each hour of the day, e.g. HourOfDay[9] is 0900-1000
+	HourOfDay [24]float64
+	// Open hours in each hour of the day
+	OpenHourOfDay [24]float64
+	Types         map[parser.SessionType]float64
+}
+
+// Utilization of an hour of the day, false when the rooms are never open
+// then
+func (u *Usage) HourUtilization(hour int) (float64, bool) {
+	if u.OpenHourOfDay[hour] == 0 {
+		return 0, false
+	}
+	return u.HourOfDay[hour] / u.OpenHourOfDay[hour], true
+}
+
+func newUsage(name string) Usage {
+	return Usage{
+		Name:  name,
+		Days:  make(map[parser.Weekday]float64),
+		Types: make(map[parser.SessionType]float64),
+	}
+}
+
+func (u *Usage) add(o Usage) {
+	u.Hours += o.Hours
+	u.Open += o.Open
+	for day, hours := range o.Days {
+		u.Days[day] += hours
+	}
+	for t, hours := range o.Types {
+		u.Types[t] += hours
+	}
+	for h := range u.HourOfDay {
+		u.HourOfDay[h] += o.HourOfDay[h]
+		u.OpenHourOfDay[h] += o.OpenHourOfDay[h]
+	}
+}
+
+func (u *Usage) finish() {
+	if u.Open > 0 {
+		u.Utilization = u.Hours / u.Open
+	}
+}
+
+type Report struct {
+	Days []parser.Weekday
+	// Session types seen, known types first
+	Types []parser.SessionType
+	// Least used first
+	Venues    []Usage
+	Buildings []Usage
+}
+
+func (r *Report) Usage(level Level) []Usage {
+	if level == Building {
+		return r.Buildings
+	}
+	return r.Venues
+}
+
+// Computes the usage of every venue of idx on days, and of the buildings
+// they are in going by parser.Building
+func Compute(idx *occupancy.Index, days []parser.Weekday) Report {
+	report := Report{Days: days}
+	buildings := make(map[string]*Usage)
+	types := make(map[parser.SessionType]bool)
+	for _, venue := range idx.Venues() {
+		usage := venueUsage(idx, venue, days)
+		for t := range usage.Types {
+			types[t] = true
+		}
+		report.Venues = append(report.Venues, usage)
+
+		code := parser.Building(venue)
+		building, ok := buildings[code]
+		if !ok {
+			u := newUsage(code)
+			building = &u
+			buildings[code] = building
+		}
+		building.add(usage)
+	}
+	for _, building := range buildings {
+		building.finish()
+		report.Buildings = append(report.Buildings, *building)
+	}
+	sortUsage(report.Venues)
+	sortUsage(report.Buildings)
+
+	for _, t := range parser.SessionTypes {
+		if types[t] {
+			report.Types = append(report.Types, t)
+			delete(types, t)
+		}
+	}
+	var other []parser.SessionType
+	for t := range types {
+		other = append(other, t)
+	}
+	sort.Slice(other, func(i, j int) bool { return other[i] < other[j] })
+	report.Types = append(report.Types, other...)
+	return report
+}
+
+func sortUsage(usage []Usage) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Utilization != usage[j].Utilization {
+			return usage[i].Utilization < usage[j].Utilization
+		}
+		return usage[i].Name < usage[j].Name
+	})
+}
+
+const minutesPerWeek = 60 * parser.TeachingWeeks
+
+func venueUsage(idx *occupancy.Index, venue string, days []parser.Weekday) Usage {
+	usage := newUsage(venue)
+	hours := idx.HoursOf(venue)
+	bookings := idx.Bookings(venue)
+	// Earlier bookings win the minutes they share with later ones
+	sort.SliceStable(bookings, func(i, j int) bool {
+		if bookings[i].Start != bookings[j].Start {
+			return bookings[i].Start < bookings[j].Start
+		}
+		return bookings[i].Schedule.Type < bookings[j].Schedule.Type
+	})
+
+	// Booked minutes over all teaching weeks, divided by minutesPerWeek
+	// at the end
+	var total int
+	booked := make(map[parser.Weekday]int)
+	types := make(map[parser.SessionType]int)
+	var hourOfDay, openHourOfDay [24]int
+	for _, day := range days {
+		for m := hours.Open; m < hours.Close; m++ {
+			openHourOfDay[m/60]++
+		}
+		usage.Open += float64(hours.Close-hours.Open) / 60
+
+		for week := 1; week <= parser.TeachingWeeks; week++ {
+			// Index of the booking holding each minute plus one, 0 when free
+			var minutes [24 * 60]int
+			for i, b := range bookings {
+				if b.Day != day || !b.Schedule.Weeks().Has(week) {
+					continue
+				}
+				start, end := b.Start, b.End
+				if start < hours.Open {
+					start = hours.Open
+				}
+				if end > hours.Close {
+					end = hours.Close
+				}
+				for m := start; m < end; m++ {
+					if minutes[m] == 0 {
+						minutes[m] = i + 1
+					}
+				}
+			}
+			for m, booking := range minutes {
+				if booking == 0 {
+					continue
+				}
+				total++
+				booked[day]++
+				hourOfDay[m/60]++
+				types[bookings[booking-1].Schedule.Type]++
+			}
+		}
+	}
+
+	usage.Hours = float64(total) / minutesPerWeek
+	for day, n := range booked {
+		usage.Days[day] = float64(n) / minutesPerWeek
+	}
+	for t, n := range types {
+		usage.Types[t] = float64(n) / minutesPerWeek
+	}
+	for h, n := range hourOfDay {
+		usage.HourOfDay[h] = float64(n) / minutesPerWeek
+		usage.OpenHourOfDay[h] = float64(openHourOfDay[h]) / 60
+	}
+	usage.finish()
+	return usage
+}
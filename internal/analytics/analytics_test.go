@@ -0,0 +1,172 @@
+package analytics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jaxsax/ntu-room-finder/internal/analytics"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(t parser.SessionType, day parser.Weekday, venue string, from, to time.Time, remark string) parser.Schedule {
+	return parser.Schedule{Index: "10101", Type: t, Day: day, Venue: venue, Remark: remark,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+func index(t *testing.T) *occupancy.Index {
+	idx := occupancy.New(&snapshot.Snapshot{
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id: "CZ2001",
+			Schedules: []parser.Schedule{
+				session(parser.Lecture, parser.Monday, "LT2A", at(8, 30), at(10, 30), ""),
+				// Shares 0930-1030 with the lecture, which keeps it
+				session(parser.Tutorial, parser.Monday, "LT2A", at(9, 30), at(11, 30), ""),
+				// Only held in odd weeks, 7 of 13
+				session(parser.Laboratory, parser.Tuesday, "NS4-05-37", at(14, 0), at(17, 0), "Teaching Wk1,3,5,7,9,11,13"),
+				// Before the building opens at 0800
+				session(parser.Tutorial, parser.Wednesday, "NS4-05-38", at(7, 0), at(9, 0), ""),
+				// Saturdays do not count
+				session(parser.Tutorial, parser.Saturday, "NS4-05-38", at(9, 0), at(12, 0), ""),
+			},
+		}}}},
+	})
+	idx.SetOpeningHours(occupancy.OpeningHours{Default: occupancy.Hours{Open: 8 * 60, Close: 18 * 60}})
+	return idx
+}
+
+var weekdays = []parser.Weekday{parser.Monday, parser.Tuesday, parser.Wednesday, parser.Thursday, parser.Friday}
+
+func TestCompute(t *testing.T) {
+	report := analytics.Compute(index(t), weekdays)
+
+	cases := []struct {
+		usage       []analytics.Usage
+		name        string
+		hours       float64
+		utilization float64
+		days        map[parser.Weekday]float64
+		types       map[parser.SessionType]float64
+	}{
+		{report.Venues, "LT2A", 3, 3.0 / 50,
+			map[parser.Weekday]float64{parser.Monday: 3},
+			map[parser.SessionType]float64{parser.Lecture: 2, parser.Tutorial: 1}},
+		{report.Venues, "NS4-05-37", 21.0 / 13, 21.0 / 13 / 50,
+			map[parser.Weekday]float64{parser.Tuesday: 21.0 / 13},
+			map[parser.SessionType]float64{parser.Laboratory: 21.0 / 13}},
+		{report.Venues, "NS4-05-38", 1, 1.0 / 50,
+			map[parser.Weekday]float64{parser.Wednesday: 1},
+			map[parser.SessionType]float64{parser.Tutorial: 1}},
+		{report.Buildings, "NS4", 34.0 / 13, 34.0 / 13 / 100,
+			map[parser.Weekday]float64{parser.Tuesday: 21.0 / 13, parser.Wednesday: 1},
+			map[parser.SessionType]float64{parser.Laboratory: 21.0 / 13, parser.Tutorial: 1}},
+	}
+
+	for i, test := range cases {
+		var usage *analytics.Usage
+		for j := range test.usage {
+			if test.usage[j].Name == test.name {
+				usage = &test.usage[j]
+			}
+		}
+		if usage == nil {
+			t.Errorf("id=%d expected=%s got=none", i, test.name)
+			continue
+		}
+		if !near(usage.Hours, test.hours) || !near(usage.Utilization, test.utilization) {
+			t.Errorf("id=%d expected=%g,%g got=%g,%g", i, test.hours, test.utilization, usage.Hours, usage.Utilization)
+		}
+		if !nearDays(usage.Days, test.days) || !nearTypes(usage.Types, test.types) {
+			t.Errorf("id=%d expected=%v,%v got=%v,%v", i, test.days, test.types, usage.Days, usage.Types)
+		}
+	}
+
+	if report.Venues[0].Name != "NS4-05-38" || report.Venues[2].Name != "LT2A" {
+		t.Errorf("expected least used first got=%s,%s,%s",
+			report.Venues[0].Name, report.Venues[1].Name, report.Venues[2].Name)
+	}
+	expectedTypes := []parser.SessionType{parser.Lecture, parser.Tutorial, parser.Laboratory}
+	if len(report.Types) != len(expectedTypes) {
+		t.Fatalf("expected=%v got=%v", expectedTypes, report.Types)
+	}
+	for i, typ := range expectedTypes {
+		if report.Types[i] != typ {
+			t.Errorf("id=%d expected=%s got=%s", i, typ, report.Types[i])
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func nearDays(a, b map[parser.Weekday]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range b {
+		if !near(a[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func nearTypes(a, b map[parser.SessionType]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range b {
+		if !near(a[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHeatmap(t *testing.T) {
+	report := analytics.Compute(index(t), weekdays)
+	var buf bytes.Buffer
+	if err := analytics.Write(&buf, "heatmap", report, analytics.Venue); err != nil {
+		t.Fatal(err)
+	}
+	var m analytics.HeatmapMatrix
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Rows) != 3 || len(m.Columns) != 24 || m.Rows[2] != "LT2A" {
+		t.Fatalf("unexpected rows=%v columns=%v", m.Rows, m.Columns)
+	}
+	lt := m.Values[2]
+	// Closed before 0800, 0800-0900 is booked on 1 of 5 days from 0830
+	if lt[7] != nil || lt[8] == nil || *lt[8] != 0.1 || *lt[9] != 0.2 || *lt[12] != 0 {
+		t.Errorf("unexpected values=%v", lt)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	report := analytics.Compute(index(t), weekdays)
+	var buf bytes.Buffer
+	if err := analytics.Write(&buf, "csv", report, analytics.Building); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := "building,hours_per_week,open_hours_per_week,utilization,day_MON,day_TUE,day_WED,day_THU,day_FRI," +
+		"type_LEC/STUDIO,type_TUT,type_LAB,hour_0000"
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], expected) {
+		t.Fatalf("unexpected csv=%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[2], "LT2A,3.00,50.00,0.0600,3.00,0.00,0.00,0.00,0.00,2.00,1.00,0.00,") {
+		t.Errorf("unexpected row=%s", lines[2])
+	}
+	if err := analytics.Write(&buf, "xml", report, analytics.Venue); err == nil {
+		t.Errorf("expected=%v got=nil", analytics.ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	ErrUnknownFormat = errors.New("analytics: unknown format")
+)
+
+var Formats = []string{"table", "csv", "heatmap"}
+
+func Write(w io.Writer, format string, r Report, level Level) error {
+	switch format {
+	case "table":
+		return Table(w, r, level)
+	case "csv":
+		return CSV(w, r, level)
+	case "heatmap":
+		return Heatmap(w, r, level)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// Writes booked hours a week per day and session type, least used first
+func Table(w io.Writer, r Report, level Level) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tHOURS\tOPEN\tUSED\t", strings.ToUpper(string(level)))
+	for _, day := range r.Days {
+		fmt.Fprintf(tw, "%s\t", day)
+	}
+	for _, t := range r.Types {
+		fmt.Fprintf(tw, "%s\t", t)
+	}
+	fmt.Fprintln(tw)
+	for _, u := range r.Usage(level) {
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%.0f%%\t", u.Name, u.Hours, u.Open, u.Utilization*100)
+		for _, day := range r.Days {
+			fmt.Fprintf(tw, "%.1f\t", u.Days[day])
+		}
+		for _, t := range r.Types {
+			fmt.Fprintf(tw, "%.1f\t", u.Types[t])
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', 2, 64)
+}
+
+func hourLabel(hour int) string {
+	return fmt.Sprintf("%02d00", hour)
+}
+
+// Writes one row per venue or building with booked hours a week per day,
+// session type and hour of the day
+func CSV(w io.Writer, r Report, level Level) error {
+	writer := csv.NewWriter(w)
+	header := []string{string(level), "hours_per_week", "open_hours_per_week", "utilization"}
+	for _, day := range r.Days {
+		header = append(header, "day_"+string(day))
+	}
+	for _, t := range r.Types {
+		header = append(header, "type_"+string(t))
+	}
+	for hour := 0; hour < 24; hour++ {
+		header = append(header, "hour_"+hourLabel(hour))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, u := range r.Usage(level) {
+		row := []string{u.Name, formatHours(u.Hours), formatHours(u.Open), strconv.FormatFloat(u.Utilization, 'f', 4, 64)}
+		for _, day := range r.Days {
+			row = append(row, formatHours(u.Days[day]))
+		}
+		for _, t := range r.Types {
+			row = append(row, formatHours(u.Types[t]))
+		}
+		for hour := 0; hour < 24; hour++ {
+			row = append(row, formatHours(u.HourOfDay[hour]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Utilization of each venue or building in each hour of the day, null
+// when closed
+type HeatmapMatrix struct {
+	Level   Level        `json:"level"`
+	Rows    []string     `json:"rows"`
+	Columns []string     `json:"columns"`
+	Values  [][]*float64 `json:"values"`
+}
+
+func NewHeatmap(r Report, level Level) HeatmapMatrix {
+	m := HeatmapMatrix{Level: level, Rows: []string{}, Values: [][]*float64{}}
+	for hour := 0; hour < 24; hour++ {
+		m.Columns = append(m.Columns, hourLabel(hour))
+	}
+	for _, u := range r.Usage(level) {
+		row := make([]*float64, 24)
+		for hour := range row {
+			if used, ok := u.HourUtilization(hour); ok {
+				used = math.Round(used*1000) / 1000
+				row[hour] = &used
+			}
+		}
+		m.Rows = append(m.Rows, u.Name)
+		m.Values = append(m.Values, row)
+	}
+	return m
+}
+
+func Heatmap(w io.Writer, r Report, level Level) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(NewHeatmap(r, level))
+}
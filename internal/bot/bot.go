@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+	"time"
+)
+
+const usage = `Ask me about rooms:
+free rooms in N4 now
+free NS4 wed 1030-1230
+free tomorrow 2pm to 4pm
+room LT2A mon
+index 10105`
+
+// Answers room queries in chat messages, chat platforms hand it the
+// text of a message and send back the reply
+type Bot struct {
+	occupancy *occupancy.Index
+	rooms     *rooms.Registry
+	subjects  map[string]parser.Subject
+	// Lines listed in a reply before the rest are only counted, 0 lists
+	// every line
+	Limit int
+	// Time messages are read at, e.g. for "now"
+	Now func() time.Time
+}
+
+// registry may be nil, rooms then have no capacity and are in the
+// building going by their name
+func New(s *snapshot.Snapshot, idx *occupancy.Index, registry *rooms.Registry) *Bot {
+	return &Bot{
+		occupancy: idx,
+		rooms:     registry,
+		subjects:  s.Subjects(),
+		Limit:     20,
+		Now:       time.Now,
+	}
+}
+
+// Reply to a message, messages that cannot be read get the reason along
+// with the usage
+func (b *Bot) Answer(text string) string {
+	c, err := Parse(text, b.Now())
+	if errors.Is(err, ErrUnknownCommand) {
+		return "I don't know that one.\n\n" + usage
+	}
+	if err != nil {
+		return strings.TrimPrefix(err.Error(), ErrInvalidCommand.Error()+": ") + "\n\n" + usage
+	}
+	logging.Debugf("bot %s %s", c.Kind, c.Target)
+
+	switch c.Kind {
+	case FreeRooms:
+		return b.freeRooms(c)
+	case RoomSchedule:
+		return b.roomSchedule(c)
+	case IndexSessions:
+		return b.indexSessions(c)
+	}
+	return usage
+}
+
+// e.g. WED 19 Sep or WED
+func when(c Command) string {
+	if c.Date.IsZero() {
+		return string(c.Day)
+	}
+	return fmt.Sprintf("%s %s", c.Day, c.Date.Format("2 Jan"))
+}
+
+func (b *Bot) freeRooms(c Command) string {
+	var venues []string
+	if c.Date.IsZero() {
+		venues = b.occupancy.Free(c.Day, c.From, c.To)
+	} else {
+		venues = b.occupancy.FreeOn(c.Date, c.From, c.To)
+	}
+	where := ""
+	if c.Target != "" {
+		where = " in " + c.Target
+	}
+
+	var lines []string
+	for _, venue := range venues {
+		if c.Target != "" && !strings.EqualFold(b.rooms.Building(venue), c.Target) {
+			continue
+		}
+		line := venue
+		if room, ok := b.rooms.Room(venue); ok && room.Capacity > 0 {
+			line += fmt.Sprintf(" (%d seats)", room.Capacity)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("No rooms free%s on %s %s-%s", where, when(c), c.From, c.To)
+	}
+	return fmt.Sprintf("Free%s on %s %s-%s:\n%s", where, when(c), c.From, c.To, b.join(lines))
+}
+
+// Lines of a reply up to Limit, followed by a count of the rest
+func (b *Bot) join(lines []string) string {
+	if b.Limit > 0 && len(lines) > b.Limit {
+		lines = append(lines[:b.Limit:b.Limit], fmt.Sprintf("and %d more", len(lines)-b.Limit))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// e.g. TUE 0930-1030 CZ2001 TUT 10105 Wk2-13, empty parts are left out
+func sessionLine(s parser.Schedule, parts ...string) string {
+	fields := []string{string(s.Day), s.TimeText}
+	for _, part := range parts {
+		if part != "" {
+			fields = append(fields, part)
+		}
+	}
+	if weeks := s.Weeks(); weeks != parser.AllWeeks {
+		fields = append(fields, "Wk"+weeks.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+func (b *Bot) roomSchedule(c Command) string {
+	bookings := b.occupancy.Bookings(c.Target)
+	if len(bookings) == 0 && len(b.occupancy.Exams(c.Target)) == 0 {
+		return fmt.Sprintf("No room %s in the timetable", c.Target)
+	}
+
+	var lines []string
+	for _, booking := range bookings {
+		if c.Day == "" || booking.Day == c.Day {
+			lines = append(lines, sessionLine(booking.Schedule, booking.SubjectId, string(booking.Schedule.Type), booking.Schedule.Index))
+		}
+	}
+	if c.Day == "" {
+		if len(lines) == 0 {
+			return fmt.Sprintf("%s has no weekly bookings", c.Target)
+		}
+		return c.Target + ":\n" + b.join(lines)
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("%s has no bookings on %s", c.Target, when(c))
+	}
+	return fmt.Sprintf("%s on %s:\n%s", c.Target, when(c), b.join(lines))
+}
+
+func (b *Bot) indexSessions(c Command) string {
+	indexes, err := planner.FindIndexes(b.subjects, c.Target)
+	if err != nil {
+		return fmt.Sprintf("No index %s in the timetable", c.Target)
+	}
+	index := indexes[0]
+	var lines []string
+	for _, s := range index.Sessions {
+		lines = append(lines, sessionLine(s, string(s.Type), s.Venue))
+	}
+	return fmt.Sprintf("%s %s %s:\n%s", index.Index, index.SubjectId, b.subjects[index.SubjectId].Title, b.join(lines))
+}
@@ -0,0 +1,240 @@
+package bot_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/bot"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(index string, t parser.SessionType, day parser.Weekday, venue, remark string, from, to time.Time) parser.Schedule {
+	return parser.Schedule{Index: index, Type: t, Day: day, Venue: venue, Remark: remark,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+func newBot(t *testing.T) *bot.Bot {
+	s := &snapshot.Snapshot{
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id:    "CZ2001",
+			Title: "ALGORITHMS",
+			Schedules: []parser.Schedule{
+				session("10105", parser.Lecture, parser.Monday, "LT2A", "", at(13, 30), at(14, 30)),
+				session("10105", parser.Tutorial, parser.Wednesday, "N4-01A-02", "Teaching Wk2-13", at(14, 30), at(15, 30)),
+				session("10105", parser.Laboratory, parser.Friday, "", "", at(8, 30), at(10, 30)),
+				session("10106", parser.Tutorial, parser.Wednesday, "N4-01A-03", "", at(9, 30), at(10, 30)),
+				session("10106", parser.Tutorial, parser.Monday, "NS4-05-37", "", at(8, 30), at(9, 30)),
+			},
+		}}}},
+	}
+	registry, err := rooms.Read(strings.NewReader("venue,capacity,building\nN4-01A-03,40,\nLT2A,250,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bot.New(s, occupancy.New(s), registry)
+	b.Now = func() time.Time { return now }
+	return b
+}
+
+func TestAnswer(t *testing.T) {
+	b := newBot(t)
+	cases := []struct {
+		text     string
+		expected string
+	}{
+		{"free rooms in N4 now", "Free in N4 on WED 19 Sep 1407-1507:\nN4-01A-03 (40 seats)"},
+		{"free rooms in n4 at 9am", "Free in N4 on WED 19 Sep 0900-1000:\nN4-01A-02"},
+		{"free n4 wed 0930-1500", "No rooms free in N4 on WED 0930-1500"},
+		{"free n4 wed 0930", "Free in N4 on WED 0930-1030:\nN4-01A-02"},
+		{"free mon 0800-1400", "Free on MON 0800-1400:\nN4-01A-02\nN4-01A-03 (40 seats)"},
+		{"room lt2a", "LT2A:\nMON 1330-1430 CZ2001 LEC/STUDIO 10105"},
+		{"room N4-01A-02 today", "N4-01A-02 on WED:\nWED 1430-1530 CZ2001 TUT 10105 Wk2-13"},
+		{"room N4-01A-02 tue", "N4-01A-02 has no bookings on TUE"},
+		{"room LT99", "No room LT99 in the timetable"},
+		{"index 10105", "10105 CZ2001 ALGORITHMS:\nMON 1330-1430 LEC/STUDIO LT2A\n" +
+			"WED 1430-1530 TUT N4-01A-02 Wk2-13\nFRI 0830-1030 LAB"},
+		{"index 99999", "No index 99999 in the timetable"},
+		{"free N4 NS4", "free rooms are in one building, got N4 and NS4\n\nAsk me about rooms:"},
+		{"hello", "I don't know that one.\n\nAsk me about rooms:"},
+		{"/help", "Ask me about rooms:"},
+	}
+
+	for id, c := range cases {
+		if got := b.Answer(c.text); !strings.HasPrefix(got, c.expected) {
+			t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+		}
+	}
+
+	b.Limit = 2
+	limited := []struct {
+		text     string
+		expected string
+	}{
+		{"free mon 1000", "Free on MON 1000-1100:\nLT2A (250 seats)\nN4-01A-02\nand 2 more"},
+		{"index 10105", "10105 CZ2001 ALGORITHMS:\nMON 1330-1430 LEC/STUDIO LT2A\nWED 1430-1530 TUT N4-01A-02 Wk2-13\nand 1 more"},
+		{"room N4-01A-02", "N4-01A-02:\nWED 1430-1530 CZ2001 TUT 10105 Wk2-13"},
+	}
+	for id, c := range limited {
+		if got := b.Answer(c.text); got != c.expected {
+			t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		text     string
+		max      int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"line one\nline two\nline three", 20, "line one\nline two\n…"},
+		{"one long line", 8, "one lo\n…"},
+		{"día\ndía", 7, "día\ndía"},
+	}
+
+	for id, c := range cases {
+		if got := bot.Truncate(c.text, c.max); got != c.expected {
+			t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+		}
+	}
+}
+
+// Records the calls of a fake Bot API
+type fakeAPI struct {
+	mu     sync.Mutex
+	calls  []string
+	params []map[string]interface{}
+	// Description of the error every call fails with, empty succeeds
+	fail string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, r.URL.Path)
+	f.params = append(f.params, params)
+	fail := f.fail
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if fail != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 400, "description": fail})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": true})
+}
+
+func newTelegram(t *testing.T) (*bot.Telegram, *fakeAPI) {
+	fake := &fakeAPI{}
+	api := httptest.NewServer(fake)
+	t.Cleanup(api.Close)
+	telegram := bot.NewTelegram("123:abc", newBot(t))
+	telegram.API = api.URL
+	telegram.Secret = "s3cret"
+	return telegram, fake
+}
+
+func TestTelegramWebhook(t *testing.T) {
+	telegram, fake := newTelegram(t)
+	webhook := httptest.NewServer(telegram)
+	defer webhook.Close()
+
+	cases := []struct {
+		method string
+		secret string
+		body   string
+		status int
+		// Text of the sendMessage call, empty when nothing is sent
+		reply string
+	}{
+		{http.MethodPost, "s3cret", `{"update_id": 1, "message": {"message_id": 7, "chat": {"id": -100}, "text": "/index@RoomBot 10106"}}`,
+			http.StatusOK, "10106 CZ2001 ALGORITHMS:\nWED 0930-1030 TUT N4-01A-03\nMON 0830-0930 TUT NS4-05-37"},
+		{http.MethodPost, "s3cret", `{"update_id": 2, "edited_message": {"message_id": 7, "chat": {"id": -100}, "text": "x"}}`,
+			http.StatusOK, ""},
+		{http.MethodPost, "s3cret", `{"update_id": 3, "message": {"message_id": 8, "chat": {"id": 5}}}`, http.StatusOK, ""},
+		{http.MethodPost, "wrong", `{"update_id": 4, "message": {"message_id": 9, "chat": {"id": 5}, "text": "help"}}`,
+			http.StatusUnauthorized, ""},
+		{http.MethodPost, "s3cret", `{"update_id":`, http.StatusBadRequest, ""},
+		{http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for id, c := range cases {
+		fake.calls, fake.params = nil, nil
+		request, err := http.NewRequest(c.method, webhook.URL, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("X-Telegram-Bot-Api-Secret-Token", c.secret)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != c.status {
+			t.Errorf("id=%d expected=%d got=%d", id, c.status, response.StatusCode)
+		}
+
+		if c.reply == "" {
+			if len(fake.calls) != 0 {
+				t.Errorf("id=%d expected no calls got=%v", id, fake.calls)
+			}
+			continue
+		}
+		if len(fake.calls) != 1 || fake.calls[0] != "/bot123:abc/sendMessage" {
+			t.Errorf("id=%d expected=sendMessage got=%v", id, fake.calls)
+			continue
+		}
+		params := fake.params[0]
+		if params["chat_id"] != float64(-100) || params["text"] != c.reply {
+			t.Errorf("id=%d expected=%q got=%v", id, c.reply, params)
+		}
+		reply, _ := params["reply_parameters"].(map[string]interface{})
+		if reply["message_id"] != float64(7) {
+			t.Errorf("id=%d expected reply to 7 got=%v", id, params["reply_parameters"])
+		}
+	}
+}
+
+func TestTelegramErrors(t *testing.T) {
+	telegram, fake := newTelegram(t)
+	if err := telegram.SetWebhook(context.Background(), "https://rooms.example.com/bot/telegram"); err != nil {
+		t.Fatal(err)
+	}
+	params := fake.params[0]
+	if fake.calls[0] != "/bot123:abc/setWebhook" || params["url"] != "https://rooms.example.com/bot/telegram" ||
+		params["secret_token"] != "s3cret" {
+		t.Errorf("expected setWebhook with the secret got=%v %v", fake.calls, params)
+	}
+
+	fake.fail = "Bad Request: chat not found"
+	err := telegram.SendMessage(context.Background(), 5, "hi", 0)
+	if !errors.Is(err, bot.ErrTelegram) || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected=%v got=%v", bot.ErrTelegram, err)
+	}
+
+	// Errors reaching the API leave the token out
+	telegram.API = "http://127.0.0.1:1"
+	err = telegram.SendMessage(context.Background(), 5, "hi", 0)
+	if !errors.Is(err, bot.ErrTelegram) || strings.Contains(err.Error(), "123:abc") {
+		t.Errorf("expected=%v without the token got=%v", bot.ErrTelegram, err)
+	}
+}
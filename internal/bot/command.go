@@ -0,0 +1,265 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownCommand = errors.New("bot: unknown command")
+	ErrInvalidCommand = errors.New("bot: invalid command")
+)
+
+type Kind string
+
+const (
+	Help Kind = "help"
+	// Rooms free in a building, or anywhere, at a time
+	FreeRooms Kind = "free"
+	// Weekly bookings of a room
+	RoomSchedule Kind = "room"
+	// Sessions of an index
+	IndexSessions Kind = "index"
+)
+
+// A chat message read into a query, the same for every chat platform
+type Command struct {
+	Kind Kind
+	// Building of FreeRooms, empty for any, the venue of RoomSchedule or
+	// the index number of IndexSessions
+	Target string
+	// Set for now, today and tomorrow of FreeRooms, zero when a weekday
+	// is given
+	Date time.Time
+	// Day of Date or the weekday given, empty for every day of a room
+	Day parser.Weekday
+	// Part of the day of FreeRooms
+	From, To occupancy.Clock
+}
+
+// First words of each kind of command, with or without a leading slash
+var verbs = map[string]Kind{
+	"help":     Help,
+	"start":    Help,
+	"free":     FreeRooms,
+	"rooms":    FreeRooms,
+	"room":     RoomSchedule,
+	"schedule": RoomSchedule,
+	"index":    IndexSessions,
+}
+
+// Words that only make a message read better, e.g. free rooms in N4 at 2pm
+var fillers = map[string]bool{
+	"rooms": true, "room": true, "in": true, "at": true, "on": true, "from": true, "for": true, "of": true,
+	"a": true, "the": true, "is": true, "what": true, "whats": true,
+}
+
+var weekdays = map[string]parser.Weekday{
+	"monday": parser.Monday, "tuesday": parser.Tuesday, "wednesday": parser.Wednesday,
+	"thursday": parser.Thursday, "friday": parser.Friday, "saturday": parser.Saturday, "sunday": parser.Sunday,
+}
+
+// Times like 1030, 10:30, 10am and 2.30pm
+var timePattern = regexp.MustCompile(`^(\d{1,2})(?:[:.]?(\d{2}))?(am|pm)?$`)
+
+func parseTime(s string) (occupancy.Clock, bool) {
+	m := timePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" && (hour < 1 || hour > 12) {
+		return 0, false
+	}
+	switch m[3] {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, false
+	}
+	return occupancy.Clock(hour*60 + minute), true
+}
+
+func weekday(word string) (parser.Weekday, bool) {
+	if day, ok := weekdays[word]; ok {
+		return day, true
+	}
+	if day, err := parser.ParseWeekday(word); err == nil {
+		return day, true
+	}
+	return "", false
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func clockOf(t time.Time) occupancy.Clock {
+	return occupancy.Clock(t.Hour()*60 + t.Minute())
+}
+
+// Reads a chat message such as "free rooms in N4 now", "/free NS4 wed
+// 1030-1230", "/room LT2A mon" or "index 10105". A leading slash and the
+// @botname Telegram adds to commands in groups are optional, now is the
+// time the message was sent.
+func Parse(text string, now time.Time) (Command, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return Command{Kind: Help}, nil
+	}
+	verb := strings.ToLower(strings.TrimPrefix(words[0], "/"))
+	if i := strings.IndexByte(verb, '@'); i >= 0 {
+		verb = verb[:i]
+	}
+	kind, ok := verbs[verb]
+	if !ok {
+		return Command{}, fmt.Errorf("%w: %q", ErrUnknownCommand, words[0])
+	}
+	c := Command{Kind: kind}
+	args := words[1:]
+
+	var err error
+	switch kind {
+	case FreeRooms:
+		err = c.parseFree(args, now)
+	case RoomSchedule:
+		err = c.parseRoom(args, now)
+	case IndexSessions:
+		if len(args) != 1 {
+			return Command{}, fmt.Errorf("%w: index takes an index number, e.g. index 10105", ErrInvalidCommand)
+		}
+		c.Target = args[0]
+	}
+	if err != nil {
+		return Command{}, err
+	}
+	return c, nil
+}
+
+// Sets Date or Day when word names one
+func (c *Command) setDay(word string, now time.Time) bool {
+	switch word {
+	case "today", "now":
+		c.Date = dateOf(now)
+	case "tomorrow":
+		c.Date = dateOf(now).AddDate(0, 0, 1)
+	default:
+		day, ok := weekday(word)
+		if !ok {
+			return false
+		}
+		c.Day = day
+		return true
+	}
+	c.Day = parser.WeekdayOf(c.Date.Weekday())
+	return true
+}
+
+func (c *Command) parseFree(args []string, now time.Time) error {
+	hasFrom, hasTo := false, false
+	for i := 0; i < len(args); i++ {
+		word := strings.ToLower(args[i])
+		switch {
+		case fillers[word]:
+		case word == "now":
+			c.setDay(word, now)
+			c.From, hasFrom = clockOf(now), true
+		case c.setDay(word, now):
+		case word == "to" || word == "until" || word == "till":
+			if i+1 == len(args) {
+				return fmt.Errorf("%w: %s needs a time", ErrInvalidCommand, word)
+			}
+			i++
+			to, ok := parseTime(strings.ToLower(args[i]))
+			if !ok {
+				return fmt.Errorf("%w: %q is not a time such as 1430 or 2:30pm", ErrInvalidCommand, args[i])
+			}
+			c.To, hasTo = to, true
+		case strings.Contains(word, "-") && !hasFrom:
+			parts := strings.SplitN(word, "-", 2)
+			from, fromOk := parseTime(parts[0])
+			to, toOk := parseTime(parts[1])
+			if !fromOk || !toOk {
+				// Venues such as NS4-05-37 have dashes too
+				if c.Target != "" {
+					return fmt.Errorf("%w: free rooms are in one building, got %s and %s", ErrInvalidCommand, c.Target, args[i])
+				}
+				c.Target = strings.ToUpper(args[i])
+				continue
+			}
+			c.From, c.To, hasFrom, hasTo = from, to, true, true
+		default:
+			if clock, ok := parseTime(word); ok {
+				if !hasFrom {
+					c.From, hasFrom = clock, true
+				} else if !hasTo {
+					c.To, hasTo = clock, true
+				} else {
+					return fmt.Errorf("%w: too many times, from %s to %s and %s", ErrInvalidCommand, c.From, c.To, args[i])
+				}
+				continue
+			}
+			if c.Target != "" {
+				return fmt.Errorf("%w: free rooms are in one building, got %s and %s", ErrInvalidCommand, c.Target, args[i])
+			}
+			c.Target = strings.ToUpper(args[i])
+		}
+	}
+
+	if c.Day == "" {
+		c.setDay("today", now)
+	}
+	if !hasFrom {
+		if !c.Date.Equal(dateOf(now)) {
+			return fmt.Errorf("%w: free rooms on %s needs a time, e.g. free %s 1030", ErrInvalidCommand, c.Day, c.Day)
+		}
+		c.From = clockOf(now)
+	}
+	if !hasTo {
+		c.To = c.From + 60
+		if c.To > 24*60 {
+			c.To = 24 * 60
+		}
+	}
+	if c.To <= c.From {
+		return fmt.Errorf("%w: %s is not after %s", ErrInvalidCommand, c.To, c.From)
+	}
+	return nil
+}
+
+func (c *Command) parseRoom(args []string, now time.Time) error {
+	// Venues may have spaces, e.g. NORTH SPINE HALL, so the day can only
+	// come last
+	if n := len(args); n > 1 && c.setDay(strings.ToLower(args[n-1]), now) {
+		args = args[:n-1]
+	}
+	// Only weekly bookings are listed, there is no calendar of teaching
+	// weeks to tell which run on a date, so today and tomorrow are just
+	// their weekday
+	c.Date = time.Time{}
+	for len(args) > 1 && fillers[strings.ToLower(args[0])] {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("%w: room takes a venue, e.g. room LT2A mon", ErrInvalidCommand)
+	}
+	c.Target = strings.ToUpper(strings.Join(args, " "))
+	return nil
+}
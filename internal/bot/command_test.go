@@ -0,0 +1,82 @@
+package bot_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/bot"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+	"time"
+)
+
+// A Wednesday afternoon
+var now = time.Date(2018, 9, 19, 14, 7, 0, 0, time.UTC)
+
+func date(day int) time.Time {
+	return time.Date(2018, 9, day, 0, 0, 0, 0, time.UTC)
+}
+
+func clock(hour, minute int) occupancy.Clock {
+	return occupancy.Clock(hour*60 + minute)
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected bot.Command
+	}{
+		{"free rooms in N4 now", bot.Command{Kind: bot.FreeRooms, Target: "N4", Date: date(19), Day: parser.Wednesday,
+			From: clock(14, 7), To: clock(15, 7)}},
+		{"/free NS4 wed 1030-1230", bot.Command{Kind: bot.FreeRooms, Target: "NS4", Day: parser.Wednesday,
+			From: clock(10, 30), To: clock(12, 30)}},
+		{"/free@RoomBot tomorrow 2pm to 4:30pm", bot.Command{Kind: bot.FreeRooms, Date: date(20), Day: parser.Thursday,
+			From: clock(14, 0), To: clock(16, 30)}},
+		{"Rooms at LT on Friday 9am 11am", bot.Command{Kind: bot.FreeRooms, Target: "LT", Day: parser.Friday,
+			From: clock(9, 0), To: clock(11, 0)}},
+		{"free", bot.Command{Kind: bot.FreeRooms, Date: date(19), Day: parser.Wednesday, From: clock(14, 7), To: clock(15, 7)}},
+		{"free today 1200am", bot.Command{Kind: bot.FreeRooms, Date: date(19), Day: parser.Wednesday, From: 0, To: clock(1, 0)}},
+		{"free 2330", bot.Command{Kind: bot.FreeRooms, Date: date(19), Day: parser.Wednesday, From: clock(23, 30), To: clock(24, 0)}},
+		{"/room LT2A", bot.Command{Kind: bot.RoomSchedule, Target: "LT2A"}},
+		{"schedule of north spine hall tomorrow", bot.Command{Kind: bot.RoomSchedule, Target: "NORTH SPINE HALL",
+			Day: parser.Thursday}},
+		{"room tr+15 MON", bot.Command{Kind: bot.RoomSchedule, Target: "TR+15", Day: parser.Monday}},
+		{"/index 10105", bot.Command{Kind: bot.IndexSessions, Target: "10105"}},
+		{"/start", bot.Command{Kind: bot.Help}},
+		{"   ", bot.Command{Kind: bot.Help}},
+	}
+
+	for id, c := range cases {
+		got, err := bot.Parse(c.text, now)
+		if err != nil {
+			t.Errorf("id=%d unexpected error %v", id, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("id=%d expected=%+v got=%+v", id, c.expected, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected error
+	}{
+		{"where can I study", bot.ErrUnknownCommand},
+		{"free N4 NS4 now", bot.ErrInvalidCommand},
+		{"free wed", bot.ErrInvalidCommand},
+		{"free 1400 1300", bot.ErrInvalidCommand},
+		{"free 1000 until", bot.ErrInvalidCommand},
+		{"free 1000 to 13pm", bot.ErrInvalidCommand},
+		{"free 9 10 11", bot.ErrInvalidCommand},
+		{"room", bot.ErrInvalidCommand},
+		{"index", bot.ErrInvalidCommand},
+	}
+
+	for id, c := range cases {
+		_, err := bot.Parse(c.text, now)
+		if !errors.Is(err, c.expected) {
+			t.Errorf("id=%d expected=%v got=%v", id, c.expected, err)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+package bot
+
+// Exposes internals to the external bot_test package
+var Truncate = truncate
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTelegram = errors.New("bot: telegram")
+)
+
+// Base URL of the Telegram Bot API
+const TelegramAPI = "https://api.telegram.org"
+
+// Header Telegram sends the secret of setWebhook back in
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Longest text sendMessage takes
+const telegramMaxText = 4096
+
+// Answers the messages Telegram posts to a webhook with sendMessage
+type Telegram struct {
+	token string
+	bot   *Bot
+	// Base URL of the Bot API, TelegramAPI unless testing
+	API    string
+	Client *http.Client
+	// Updates without it in the secret header are refused when set
+	Secret string
+}
+
+func NewTelegram(token string, bot *Bot) *Telegram {
+	return &Telegram{
+		token:  token,
+		bot:    bot,
+		API:    TelegramAPI,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Only the parts of an Update that are read
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// Calls a method of the Bot API with params as JSON
+func (t *Telegram) call(ctx context.Context, method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.API+"/bot"+t.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := t.Client.Do(request)
+	if err != nil {
+		// The URL has the token in it, keep it out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%w: %s: %v", ErrTelegram, method, err)
+	}
+	defer response.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: %s: %s: %v", ErrTelegram, method, response.Status, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("%w: %s: %s", ErrTelegram, method, result.Description)
+	}
+	return nil
+}
+
+// Points Telegram at the webhook, updates are then posted to webhookURL
+// with Secret in the secret header
+func (t *Telegram) SetWebhook(ctx context.Context, webhookURL string) error {
+	params := map[string]interface{}{
+		"url":             webhookURL,
+		"allowed_updates": []string{"message"},
+	}
+	if t.Secret != "" {
+		params["secret_token"] = t.Secret
+	}
+	return t.call(ctx, "setWebhook", params)
+}
+
+func (t *Telegram) SendMessage(ctx context.Context, chatID int64, text string, replyTo int64) error {
+	params := map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}
+	if replyTo != 0 {
+		params["reply_parameters"] = map[string]interface{}{
+			"message_id":                  replyTo,
+			"allow_sending_without_reply": true,
+		}
+	}
+	return t.call(ctx, "sendMessage", params)
+}
+
+// Cuts text after the last whole line that fits in max characters,
+// marking the cut with an ellipsis. Telegram counts UTF-16 code units,
+// the same as runes for the text of replies.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max-2])
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "\n…"
+}
+
+// POST of an Update from Telegram. Updates that fail to be answered are
+// still acknowledged, Telegram would otherwise keep posting them.
+func (t *Telegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if t.Secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(telegramSecretHeader)), []byte(t.Secret)) != 1 {
+		http.Error(w, "wrong secret token", http.StatusUnauthorized)
+		return
+	}
+	var update telegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Edits, joins and the like have no text to answer
+	if update.Message == nil || update.Message.Text == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	message := update.Message
+	reply := truncate(t.bot.Answer(message.Text), telegramMaxText)
+	if err := t.SendMessage(r.Context(), message.Chat.ID, reply, message.MessageID); err != nil {
+		logging.Errorf("failed to answer update %d %v", update.UpdateID, err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,245 @@
+package campus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidCampus   = errors.New("campus: invalid campus file")
+	ErrUnknownBuilding = errors.New("campus: building not found")
+)
+
+// Metres walked a minute, used between buildings with coordinates but no
+// path of edges
+const DefaultWalkingSpeed = 80
+
+type Building struct {
+	Code string `yaml:"code"`
+	Name string `yaml:"name"`
+	// Coordinates in degrees, both 0 when unknown
+	Lat float64 `yaml:"lat"`
+	Lng float64 `yaml:"lng"`
+	// Venue prefixes that are in the building although parser.Building
+	// does not return Code for them, e.g. TR+ for tutorial rooms
+	Venues []string `yaml:"venues"`
+}
+
+func (b Building) located() bool {
+	return b.Lat != 0 || b.Lng != 0
+}
+
+// Walking time between two buildings, edges can be walked both ways
+type Edge struct {
+	From    string  `yaml:"from"`
+	To      string  `yaml:"to"`
+	Minutes float64 `yaml:"minutes"`
+}
+
+type file struct {
+	WalkingSpeed float64    `yaml:"walking_speed"`
+	Buildings    []Building `yaml:"buildings"`
+	Edges        []Edge     `yaml:"edges"`
+}
+
+type neighbour struct {
+	code    string
+	minutes float64
+}
+
+// Buildings of the campus and the walking times between them
+type Campus struct {
+	buildings map[string]Building
+	// Venue prefix to building code
+	prefixes map[string]string
+	edges    map[string][]neighbour
+	speed    float64
+}
+
+// Reads a campus file, see docs/campus.yaml
+func Load(path string) (*Campus, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCampus, path, err)
+	}
+	c, err := New(f.Buildings, f.Edges, f.WalkingSpeed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Builds a campus, speed is DefaultWalkingSpeed when 0
+func New(buildings []Building, edges []Edge, speed float64) (*Campus, error) {
+	if speed == 0 {
+		speed = DefaultWalkingSpeed
+	}
+	if speed < 0 {
+		return nil, fmt.Errorf("%w: walking speed %g", ErrInvalidCampus, speed)
+	}
+	c := &Campus{
+		buildings: make(map[string]Building),
+		prefixes:  make(map[string]string),
+		edges:     make(map[string][]neighbour),
+		speed:     speed,
+	}
+	for _, b := range buildings {
+		b.Code = strings.ToUpper(strings.TrimSpace(b.Code))
+		if b.Code == "" {
+			return nil, fmt.Errorf("%w: building without a code", ErrInvalidCampus)
+		}
+		if _, ok := c.buildings[b.Code]; ok {
+			return nil, fmt.Errorf("%w: building %s is listed twice", ErrInvalidCampus, b.Code)
+		}
+		c.buildings[b.Code] = b
+		for _, prefix := range b.Venues {
+			c.prefixes[strings.ToUpper(prefix)] = b.Code
+		}
+	}
+	for _, e := range edges {
+		from, to := strings.ToUpper(e.From), strings.ToUpper(e.To)
+		for _, code := range []string{from, to} {
+			if _, ok := c.buildings[code]; !ok {
+				return nil, fmt.Errorf("%w: edge %s-%s has unknown building %s", ErrInvalidCampus, e.From, e.To, code)
+			}
+		}
+		if e.Minutes < 0 {
+			return nil, fmt.Errorf("%w: edge %s-%s takes %g minutes", ErrInvalidCampus, e.From, e.To, e.Minutes)
+		}
+		c.edges[from] = append(c.edges[from], neighbour{to, e.Minutes})
+		c.edges[to] = append(c.edges[to], neighbour{from, e.Minutes})
+	}
+	return c, nil
+}
+
+// Returns the building a venue or building code is in, venue prefixes
+// are only tried when parser.Building is not a known code
+func (c *Campus) BuildingOf(venue string) (Building, bool) {
+	if b, ok := c.buildings[parser.Building(venue)]; ok {
+		return b, true
+	}
+	venue = strings.ToUpper(strings.TrimSpace(venue))
+	code, longest := "", 0
+	for prefix, building := range c.prefixes {
+		if strings.HasPrefix(venue, prefix) && len(prefix) > longest {
+			code, longest = building, len(prefix)
+		}
+	}
+	b, ok := c.buildings[code]
+	return b, ok
+}
+
+// Returns the minutes it takes to walk between two venues or buildings,
+// along edges where there is a path or in a straight line otherwise
+func (c *Campus) Walk(from, to string) (float64, error) {
+	start, ok := c.BuildingOf(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownBuilding, from)
+	}
+	end, ok := c.BuildingOf(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownBuilding, to)
+	}
+	minutes, ok := c.walk(start, c.shortestPaths(start.Code), end)
+	if !ok {
+		return 0, fmt.Errorf("%w: no way from %s to %s", ErrUnknownBuilding, start.Code, end.Code)
+	}
+	return minutes, nil
+}
+
+func (c *Campus) walk(start Building, paths map[string]float64, end Building) (float64, bool) {
+	if minutes, ok := paths[end.Code]; ok {
+		return minutes, true
+	}
+	if start.located() && end.located() {
+		return distance(start, end) / c.speed, true
+	}
+	return 0, false
+}
+
+// Dijkstra over the edges, the campus is small enough for a linear scan
+// instead of a heap
+func (c *Campus) shortestPaths(from string) map[string]float64 {
+	paths := map[string]float64{from: 0}
+	done := make(map[string]bool)
+	for {
+		next, best := "", math.Inf(1)
+		for code, minutes := range paths {
+			if !done[code] && (minutes < best || minutes == best && code < next) {
+				next, best = code, minutes
+			}
+		}
+		if next == "" {
+			return paths
+		}
+		done[next] = true
+		for _, n := range c.edges[next] {
+			if minutes, ok := paths[n.code]; !ok || best+n.minutes < minutes {
+				paths[n.code] = best + n.minutes
+			}
+		}
+	}
+}
+
+const earthRadius = 6371000
+
+// Metres between two buildings on the surface of the earth
+func distance(a, b Building) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLng := lat2-lat1, (b.Lng-a.Lng)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// A venue and how far it is
+type Distance struct {
+	Venue    string
+	Building string
+	Minutes  float64
+	// False when the venue is not on the campus or cannot be reached,
+	// Minutes is 0 then
+	Reachable bool
+}
+
+// Orders venues by the minutes it takes to walk to them from a venue or
+// building, closest first. Venues that cannot be reached come last in
+// the order they were given.
+func (c *Campus) Rank(from string, venues []string) ([]Distance, error) {
+	start, ok := c.BuildingOf(from)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBuilding, from)
+	}
+	paths := c.shortestPaths(start.Code)
+
+	distances := make([]Distance, 0, len(venues))
+	for _, venue := range venues {
+		d := Distance{Venue: venue}
+		if b, ok := c.BuildingOf(venue); ok {
+			d.Building = b.Code
+			d.Minutes, d.Reachable = c.walk(start, paths, b)
+		}
+		distances = append(distances, d)
+	}
+	sort.SliceStable(distances, func(i, j int) bool {
+		a, b := distances[i], distances[j]
+		if a.Reachable != b.Reachable {
+			return a.Reachable
+		}
+		return a.Minutes < b.Minutes
+	})
+	return distances, nil
+}
@@ -0,0 +1,106 @@
+package campus_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/campus"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func load(t *testing.T) *campus.Campus {
+	c, err := campus.Load("../../docs/campus.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	c := load(t)
+	cases := []struct {
+		from, to    string
+		expected    float64
+		expectedErr error
+	}{
+		{"NS", "LT26", 9, nil},
+		{"LT2A", "SPMS-TR+5", 15, nil},
+		{"NS4-05-37", "LT26", 3, nil},
+		// Tutorial rooms are in the North Spine
+		{"TR+15", "LT2A", 2, nil},
+		{"NORTH SPINE HALL", "ns", 0, nil},
+		{"LT2A", "HALL", 0, campus.ErrUnknownBuilding},
+		{"S4-CL1", "NS", 0, campus.ErrUnknownBuilding},
+	}
+
+	for i, test := range cases {
+		result, err := c.Walk(test.from, test.to)
+		if !errors.Is(err, test.expectedErr) || result != test.expected {
+			t.Errorf("id=%d expected=%g,%v got=%g,%v", i, test.expected, test.expectedErr, result, err)
+		}
+	}
+}
+
+func TestWalkStraightLine(t *testing.T) {
+	// Two buildings 0.01 degrees of latitude apart and no edges
+	c, err := campus.New([]campus.Building{
+		{Code: "A", Lat: 1.34, Lng: 103.68},
+		{Code: "B", Lat: 1.35, Lng: 103.68},
+		{Code: "C"},
+	}, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.Walk("A", "B")
+	if err != nil || math.Abs(result-11.12) > 0.01 {
+		t.Errorf("expected=11.12 got=%g,%v", result, err)
+	}
+	if _, err := c.Walk("A", "C"); !errors.Is(err, campus.ErrUnknownBuilding) {
+		t.Errorf("expected=%v got=%v", campus.ErrUnknownBuilding, err)
+	}
+}
+
+func TestRank(t *testing.T) {
+	c := load(t)
+	distances, err := c.Rank("NS4-01-01", []string{"LT2A", "ONLINE", "SPMS-TR+5", "LT26", "NS4-05-37"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []campus.Distance{
+		{Venue: "NS4-05-37", Building: "NS4", Minutes: 0, Reachable: true},
+		{Venue: "LT26", Building: "LT26", Minutes: 3, Reachable: true},
+		{Venue: "SPMS-TR+5", Building: "SPMS", Minutes: 7, Reachable: true},
+		{Venue: "LT2A", Building: "LT2A", Minutes: 8, Reachable: true},
+		{Venue: "ONLINE"},
+	}
+	if !reflect.DeepEqual(distances, expected) {
+		t.Errorf("expected=%+v got=%+v", expected, distances)
+	}
+
+	if _, err := c.Rank("ONLINE", nil); !errors.Is(err, campus.ErrUnknownBuilding) {
+		t.Errorf("expected=%v got=%v", campus.ErrUnknownBuilding, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []string{
+		"buildings:\n  - code: NS\n  - code: ns\n",
+		"buildings:\n  - code: NS\nedges:\n  - from: NS\n    to: LT1\n    minutes: 2\n",
+		"buildings:\n  - code: NS\n  - code: LT1\nedges:\n  - from: NS\n    to: LT1\n    minutes: -2\n",
+		"buildings:\n  - name: North Spine\n",
+		"walking_speed: -1\n",
+		"building:\n  - code: NS\n",
+	}
+
+	for i, body := range cases {
+		path := filepath.Join(t.TempDir(), "campus.yaml")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := campus.Load(path); !errors.Is(err, campus.ErrInvalidCampus) {
+			t.Errorf("id=%d expected=%v got=%v", i, campus.ErrInvalidCampus, err)
+		}
+	}
+}
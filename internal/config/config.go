@@ -0,0 +1,496 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Used when no path is given and the file exists
+	DefaultPath = "ntu-room-finder.yaml"
+	// Names the config file when no path is given
+	PathEnv = "NTU_ROOM_FINDER_CONFIG"
+	// Prefix of the variables overriding config keys, crawl.delay is
+	// overridden by NTU_ROOM_FINDER_CRAWL_DELAY
+	EnvPrefix = "NTU_ROOM_FINDER_"
+)
+
+var ErrInvalid = errors.New("config: invalid configuration")
+
+type Config struct {
+	DataDir   string   `yaml:"data_dir"`
+	LogLevel  string   `yaml:"log_level"`
+	Semesters []string `yaml:"semesters"`
+	Crawl     Crawl    `yaml:"crawl"`
+	Parse     Parse    `yaml:"parse"`
+	Output    Output   `yaml:"output"`
+	Server    Server   `yaml:"server"`
+	Rooms     Rooms    `yaml:"rooms"`
+	Planner   Planner  `yaml:"planner"`
+	Swap      Swap     `yaml:"swap"`
+	Bot       Bot      `yaml:"bot"`
+}
+
+type Crawl struct {
+	Concurrency int           `yaml:"concurrency"`
+	Delay       time.Duration `yaml:"delay"`
+}
+
+type Parse struct {
+	// 0 uses one worker per CPU
+	Concurrency int `yaml:"concurrency"`
+	// Exit with an error when any course fails to parse, meant for CI
+	FailOnError bool `yaml:"fail_on_error"`
+	// Skip schedule rows that cannot be parsed instead of failing the course
+	Lenient bool `yaml:"lenient"`
+}
+
+// Where generated files are written, empty paths are skipped
+type Output struct {
+	SQL     string `yaml:"sql"`
+	InitSQL string `yaml:"init_sql"`
+	JSON    string `yaml:"json"`
+	CSV     string `yaml:"csv"`
+}
+
+type Server struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+func (s Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+type Rooms struct {
+	Registry string `yaml:"registry"`
+	// Campus file used to rank free rooms by walking distance, see
+	// docs/campus.yaml
+	Campus string `yaml:"campus"`
+	// Opening hours of buildings such as 0800 and 2200, free blocks and
+	// slots are limited to them
+	Open  string `yaml:"open"`
+	Close string `yaml:"close"`
+	// Hours of buildings that differ, keyed by venue prefix such as LT
+	Buildings map[string]BuildingHours `yaml:"buildings"`
+}
+
+type BuildingHours struct {
+	Open  string `yaml:"open"`
+	Close string `yaml:"close"`
+}
+
+// How timetables are ranked by the planner, the cheapest plans come first
+type Planner struct {
+	// Sessions starting before this time, e.g. 0930, count as early,
+	// empty counts none
+	NotBefore string `yaml:"not_before"`
+	// Cost of each early session
+	Early float64 `yaml:"early"`
+	// Cost of each hour between sessions on the same day
+	Gap float64 `yaml:"gap"`
+	// Taken off the cost for each weekday without sessions
+	FreeDay float64 `yaml:"free_day"`
+	// Number of plans listed, 0 lists every plan
+	Limit int `yaml:"limit"`
+	// Partial timetables tried before giving up, 0 uses the default of
+	// the planner
+	MaxWork int `yaml:"max_work"`
+}
+
+// Index swap requests taken by the server
+type Swap struct {
+	// SQLite database the requests are kept in, empty turns swaps off
+	Database string `yaml:"database"`
+	// Callers send it as a bearer token, they are trusted to name the
+	// user a request is for. Needed with Database.
+	Secret string `yaml:"secret"`
+	// Largest number of students in one swap cycle
+	MaxCycle int `yaml:"max_cycle"`
+}
+
+// Chat bots answering room queries
+type Bot struct {
+	Telegram Telegram `yaml:"telegram"`
+}
+
+// Telegram bot taking updates on a webhook of the server
+type Telegram struct {
+	// Token from BotFather, empty turns the bot off
+	Token string `yaml:"token"`
+	// Public https URL of /bot/telegram registered with setWebhook when
+	// the server starts, empty leaves the registered webhook as it is
+	WebhookURL string `yaml:"webhook_url"`
+	// Telegram sends it back with every update so others cannot post them,
+	// needed with Token
+	Secret string `yaml:"secret"`
+}
+
+func Default() *Config {
+	return &Config{
+		DataDir:  ".",
+		LogLevel: "info",
+		Crawl: Crawl{
+			Concurrency: 1,
+			Delay:       time.Second,
+		},
+		Output: Output{
+			SQL:     "out.sql",
+			InitSQL: "sql/init.sql",
+		},
+		Server: Server{Port: 8080},
+		Rooms: Rooms{
+			Open:  "0000",
+			Close: "2400",
+		},
+		Planner: Planner{
+			NotBefore: "0930",
+			Early:     1,
+			Gap:       0.5,
+			FreeDay:   2,
+			Limit:     10,
+		},
+		Swap: Swap{
+			MaxCycle: 4,
+		},
+	}
+}
+
+// A problem with a single key, Source is the file position or the
+// environment variable the value came from
+type FieldError struct {
+	Key    string
+	Source string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config: %s: %s: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return ErrInvalid
+}
+
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() error {
+	return ErrInvalid
+}
+
+// Reads the config at path on top of the defaults, applies environment
+// overrides and validates the result. An empty path falls back to
+// $NTU_ROOM_FINDER_CONFIG and then DefaultPath when it exists.
+func Load(path string) (*Config, error) {
+	return load(path, os.Getenv)
+}
+
+func load(path string, getenv func(string) string) (*Config, error) {
+	c := Default()
+	sources := make(map[string]string)
+
+	if path == "" {
+		path = getenv(PathEnv)
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); err == nil {
+			path = DefaultPath
+		}
+	}
+	if path != "" {
+		logging.Debugf("reading config %s", path)
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.decode(path, body, sources); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.applyEnv(getenv, sources); err != nil {
+		return nil, err
+	}
+	if errs := c.validate(sources); len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+func (c *Config) decode(path string, body []byte, sources map[string]string) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(body, &root); err != nil {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+	recordLines(path, "", &root, sources)
+
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+	return nil
+}
+
+// Remembers the line every key was set on so errors can point at it
+func recordLines(path, prefix string, n *yaml.Node, sources map[string]string) {
+	if n.Kind == yaml.DocumentNode {
+		for _, child := range n.Content {
+			recordLines(path, prefix, child, sources)
+		}
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		sources[key] = fmt.Sprintf("%s:%d", path, n.Content[i].Line)
+		recordLines(path, key, n.Content[i+1], sources)
+	}
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"data_dir", &c.DataDir},
+		{"log_level", &c.LogLevel},
+		{"semesters", &c.Semesters},
+		{"crawl.concurrency", &c.Crawl.Concurrency},
+		{"crawl.delay", &c.Crawl.Delay},
+		{"parse.concurrency", &c.Parse.Concurrency},
+		{"parse.fail_on_error", &c.Parse.FailOnError},
+		{"parse.lenient", &c.Parse.Lenient},
+		{"output.sql", &c.Output.SQL},
+		{"output.init_sql", &c.Output.InitSQL},
+		{"output.json", &c.Output.JSON},
+		{"output.csv", &c.Output.CSV},
+		{"server.host", &c.Server.Host},
+		{"server.port", &c.Server.Port},
+		{"rooms.registry", &c.Rooms.Registry},
+		{"rooms.campus", &c.Rooms.Campus},
+		{"rooms.open", &c.Rooms.Open},
+		{"rooms.close", &c.Rooms.Close},
+		{"planner.not_before", &c.Planner.NotBefore},
+		{"planner.early", &c.Planner.Early},
+		{"planner.gap", &c.Planner.Gap},
+		{"planner.free_day", &c.Planner.FreeDay},
+		{"planner.limit", &c.Planner.Limit},
+		{"planner.max_work", &c.Planner.MaxWork},
+		{"swap.database", &c.Swap.Database},
+		{"swap.secret", &c.Swap.Secret},
+		{"swap.max_cycle", &c.Swap.MaxCycle},
+		{"bot.telegram.token", &c.Bot.Telegram.Token},
+		{"bot.telegram.webhook_url", &c.Bot.Telegram.WebhookURL},
+		{"bot.telegram.secret", &c.Bot.Telegram.Secret},
+	}
+}
+
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func (c *Config) applyEnv(getenv func(string) string, sources map[string]string) error {
+	var errs Errors
+	for _, f := range c.fields() {
+		name := EnvName(f.key)
+		raw := getenv(name)
+		if raw == "" {
+			continue
+		}
+		source := "$" + name
+		sources[f.key] = source
+
+		var err error
+		switch v := f.value.(type) {
+		case *string:
+			*v = raw
+		case *[]string:
+			*v = splitList(raw)
+		case *int:
+			*v, err = strconv.Atoi(raw)
+		case *float64:
+			*v, err = strconv.ParseFloat(raw, 64)
+		case *bool:
+			*v, err = strconv.ParseBool(raw)
+		case *time.Duration:
+			*v, err = time.ParseDuration(raw)
+		}
+		if err != nil {
+			errs = append(errs, &FieldError{Key: f.key, Source: source, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+var telegramTokenPattern = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]+$`)
+
+// Characters Telegram allows in a secret token
+var telegramSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+var semesterPattern = regexp.MustCompile(`^\d{4};[12ST]$`)
+
+var clockPattern = regexp.MustCompile(`^([01]\d|2[0-3])[0-5]\d$`)
+
+// Closing times may be 2400
+var closingPattern = regexp.MustCompile(`^(([01]\d|2[0-3])[0-5]\d|2400)$`)
+
+// Returns the field that is wrong, open or close, along with the problem
+func validHours(open, close string) (string, error) {
+	if !clockPattern.MatchString(open) {
+		return "open", fmt.Errorf("opening time %q is not a time such as 0800", open)
+	}
+	if !closingPattern.MatchString(close) {
+		return "close", fmt.Errorf("closing time %q is not a time such as 2200", close)
+	}
+	if open >= close {
+		return "open", fmt.Errorf("opens at %s after closing at %s", open, close)
+	}
+	return "", nil
+}
+
+func (c *Config) validate(sources map[string]string) Errors {
+	var errs Errors
+	fail := func(key, format string, v ...interface{}) {
+		errs = append(errs, &FieldError{
+			Key:    key,
+			Source: sources[key],
+			Err:    fmt.Errorf(format, v...),
+		})
+	}
+
+	if c.DataDir == "" {
+		fail("data_dir", "must not be empty")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		fail("log_level", "must be one of debug, info, warn or error, got %q", c.LogLevel)
+	}
+	for _, sem := range c.Semesters {
+		if !semesterPattern.MatchString(sem) {
+			fail("semesters", "%q is not an acadsem key such as \"2018;1\"", sem)
+		}
+	}
+	if c.Crawl.Concurrency < 1 {
+		fail("crawl.concurrency", "must be at least 1, got %d", c.Crawl.Concurrency)
+	}
+	if c.Crawl.Delay < 0 {
+		fail("crawl.delay", "must not be negative, got %s", c.Crawl.Delay)
+	}
+	if c.Parse.Concurrency < 0 {
+		fail("parse.concurrency", "must not be negative, got %d", c.Parse.Concurrency)
+	}
+	if c.Output.SQL == "" {
+		fail("output.sql", "must not be empty")
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Rooms.Registry != "" {
+		if _, err := os.Stat(c.Rooms.Registry); err != nil {
+			fail("rooms.registry", "%v", err)
+		}
+	}
+	if c.Rooms.Campus != "" {
+		if _, err := os.Stat(c.Rooms.Campus); err != nil {
+			fail("rooms.campus", "%v", err)
+		}
+	}
+	if field, err := validHours(c.Rooms.Open, c.Rooms.Close); err != nil {
+		fail("rooms."+field, "%v", err)
+	}
+	for prefix, hours := range c.Rooms.Buildings {
+		if field, err := validHours(hours.Open, hours.Close); err != nil {
+			fail("rooms.buildings."+prefix+"."+field, "%v", err)
+		}
+	}
+	if c.Planner.NotBefore != "" && !clockPattern.MatchString(c.Planner.NotBefore) {
+		fail("planner.not_before", "%q is not a time such as 0930", c.Planner.NotBefore)
+	}
+	weights := []struct {
+		key   string
+		value float64
+	}{
+		{"planner.early", c.Planner.Early},
+		{"planner.gap", c.Planner.Gap},
+		{"planner.free_day", c.Planner.FreeDay},
+	}
+	for _, w := range weights {
+		if w.value < 0 {
+			fail(w.key, "must not be negative, got %g", w.value)
+		}
+	}
+	if c.Planner.Limit < 0 {
+		fail("planner.limit", "must not be negative, got %d", c.Planner.Limit)
+	}
+	if c.Planner.MaxWork < 0 {
+		fail("planner.max_work", "must not be negative, got %d", c.Planner.MaxWork)
+	}
+	if c.Swap.MaxCycle < 2 {
+		fail("swap.max_cycle", "must be at least 2, got %d", c.Swap.MaxCycle)
+	}
+	if c.Swap.Database != "" && c.Swap.Secret == "" {
+		// Anyone could post and cancel requests for any user otherwise
+		fail("swap.secret", "is needed with swap.database")
+	}
+	telegram := c.Bot.Telegram
+	if telegram.Token != "" && !telegramTokenPattern.MatchString(telegram.Token) {
+		// The token itself is left out as errors end up in logs
+		fail("bot.telegram.token", "is not a token such as 123456:ABC-DEF")
+	}
+	if telegram.WebhookURL != "" {
+		if u, err := url.Parse(telegram.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			fail("bot.telegram.webhook_url", "%q is not an https URL", telegram.WebhookURL)
+		} else if telegram.Token == "" {
+			fail("bot.telegram.webhook_url", "needs bot.telegram.token")
+		}
+	}
+	if telegram.Secret != "" && !telegramSecretPattern.MatchString(telegram.Secret) {
+		fail("bot.telegram.secret", "must be 1 to 256 letters, digits, _ or -")
+	} else if telegram.Secret == "" && telegram.Token != "" {
+		// Anyone could post updates to the webhook otherwise
+		fail("bot.telegram.secret", "is needed with bot.telegram.token")
+	}
+	return errs
+}
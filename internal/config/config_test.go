@@ -0,0 +1,111 @@
+package config_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/config"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+data_dir: /srv/snapshots
+semesters: ["2018;1", "2018;2"]
+crawl:
+  delay: 2s
+server:
+  port: 9000
+`)
+	t.Setenv("NTU_ROOM_FINDER_SERVER_PORT", "9001")
+	t.Setenv("NTU_ROOM_FINDER_CRAWL_CONCURRENCY", "4")
+
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DataDir != "/srv/snapshots" {
+		t.Errorf("data_dir expected=%q got=%q", "/srv/snapshots", c.DataDir)
+	}
+	if len(c.Semesters) != 2 {
+		t.Errorf("semesters expected=2 got=%d", len(c.Semesters))
+	}
+	if c.Crawl.Delay != 2*time.Second {
+		t.Errorf("crawl.delay expected=2s got=%s", c.Crawl.Delay)
+	}
+	if c.Crawl.Concurrency != 4 {
+		t.Errorf("crawl.concurrency expected=4 got=%d", c.Crawl.Concurrency)
+	}
+	if c.Server.Port != 9001 {
+		t.Errorf("server.port expected=9001 got=%d", c.Server.Port)
+	}
+	if c.Output.SQL != "out.sql" {
+		t.Errorf("output.sql expected default got=%q", c.Output.SQL)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		body     string
+		env      map[string]string
+		contains string
+	}{
+		{"crawl:\n  concurrency: 0\n", nil, "config.yaml:2: crawl.concurrency"},
+		{"semesters:\n  - 2018\n", nil, "config.yaml:1: semesters"},
+		{"log_level: loud\n", nil, "config.yaml:1: log_level"},
+		{"", map[string]string{"NTU_ROOM_FINDER_SERVER_PORT": "http"}, "$NTU_ROOM_FINDER_SERVER_PORT: server.port"},
+		{"", map[string]string{"NTU_ROOM_FINDER_SERVER_PORT": "0"}, "$NTU_ROOM_FINDER_SERVER_PORT: server.port"},
+		{"rooms:\n  registry: /does/not/exist\n", nil, "config.yaml:2: rooms.registry"},
+		{"serve:\n  port: 1\n", nil, "field serve not found"},
+		{"planner:\n  not_before: 930\n", nil, "config.yaml:2: planner.not_before"},
+		{"planner:\n  gap: -1\n", nil, "config.yaml:2: planner.gap"},
+		{"swap:\n  max_cycle: 1\n", nil, "config.yaml:2: swap.max_cycle"},
+		{"swap:\n  database: swaps.db\n", nil, "swap.secret: is needed with swap.database"},
+		{"rooms:\n  campus: /does/not/exist\n", nil, "config.yaml:2: rooms.campus"},
+		{"rooms:\n  open: \"2200\"\n  close: \"0800\"\n", nil, "config.yaml:2: rooms.open"},
+		{"rooms:\n  buildings:\n    LT:\n      open: \"0800\"\n      close: \"2500\"\n", nil,
+			"config.yaml:5: rooms.buildings.LT.close"},
+		{"rooms:\n  buildings:\n    LT:\n      open: \"8am\"\n", nil, "config.yaml:4: rooms.buildings.LT.open"},
+		{"rooms:\n  close: \"2401\"\n", nil, "config.yaml:2: rooms.close"},
+		{"", map[string]string{"NTU_ROOM_FINDER_ROOMS_CLOSE": "late"}, "$NTU_ROOM_FINDER_ROOMS_CLOSE: rooms.close"},
+		{"", map[string]string{"NTU_ROOM_FINDER_PLANNER_EARLY": "often"}, "$NTU_ROOM_FINDER_PLANNER_EARLY: planner.early"},
+		{"planner:\n  max_work: -1\n", nil, "config.yaml:2: planner.max_work"},
+		{"", map[string]string{"NTU_ROOM_FINDER_BOT_TELEGRAM_TOKEN": "secret"},
+			"$NTU_ROOM_FINDER_BOT_TELEGRAM_TOKEN: bot.telegram.token"},
+		{"bot:\n  telegram:\n    webhook_url: http://example.com/bot/telegram\n", nil,
+			"config.yaml:3: bot.telegram.webhook_url"},
+		{"bot:\n  telegram:\n    webhook_url: https://example.com/bot/telegram\n", nil, "needs bot.telegram.token"},
+		{"bot:\n  telegram:\n    secret: not secret\n", nil, "config.yaml:3: bot.telegram.secret"},
+		{"bot:\n  telegram:\n    token: 123:abc\n", nil, "bot.telegram.secret: is needed with bot.telegram.token"},
+	}
+
+	for i, test := range cases {
+		for k, v := range test.env {
+			t.Setenv(k, v)
+		}
+		_, err := config.Load(writeConfig(t, test.body))
+		if err == nil || !strings.Contains(err.Error(), test.contains) {
+			t.Errorf("id=%d expected error containing %q got=%v", i, test.contains, err)
+		}
+		for k := range test.env {
+			t.Setenv(k, "")
+		}
+	}
+}
+
+func TestFieldErrorIsInvalid(t *testing.T) {
+	_, err := config.Load(writeConfig(t, "data_dir: \"\"\n"))
+	if !errors.Is(err, config.ErrInvalid) {
+		t.Errorf("expected ErrInvalid got=%v", err)
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
 	"github.com/jaxsax/ntu-room-finder/pkg/parser"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -44,7 +47,7 @@ func DownloadMainBody(url string) ([]byte, error) {
 }
 
 func store(path string, body []byte) error {
-	log.Printf("storing %s", path)
+	logging.Debugf("storing %s", path)
 	err := ioutil.WriteFile(path, body, 0755)
 	if err != nil {
 		return err
@@ -52,15 +55,80 @@ func store(path string, body []byte) error {
 	return nil
 }
 
-func folderCachePath() string {
+// Snapshot folders are named after the date, suffixed with the semester
+// when crawling a specific one, e.g. 2018-09-13 or 2018-09-13_2018-1
+func folderCachePath(dataDir, semester string) string {
 	year, month, day := time.Now().UTC().Date()
-	return fmt.Sprintf("%4d-%02d-%02d", year, month, day)
+	name := fmt.Sprintf("%4d-%02d-%02d", year, month, day)
+	if semester != "" {
+		name += "_" + strings.Replace(semester, ";", "-", -1)
+	}
+	return filepath.Join(dataDir, name)
+}
+
+// File in a snapshot folder naming the semester it was crawled for, as
+// JSON of parser.AcademicSemester
+const semesterFile = "semester.json"
+
+func storeSemester(folder string, sem parser.AcademicSemester) error {
+	body, err := json.Marshal(sem)
+	if err != nil {
+		return err
+	}
+	return store(filepath.Join(folder, semesterFile), body)
+}
+
+// Returns the semester a snapshot folder was crawled for. Snapshots
+// crawled before it was stored in semester.json go by the suffix of their
+// name or else the latest semester of their main page.
+func SnapshotSemester(folder string) (parser.AcademicSemester, error) {
+	var sem parser.AcademicSemester
+	body, err := ioutil.ReadFile(filepath.Join(folder, semesterFile))
+	if err == nil {
+		if err := json.Unmarshal(body, &sem); err != nil || sem.Key == "" {
+			return sem, fmt.Errorf("%w: %s: %v", ErrParsingAcademicSemester, semesterFile, err)
+		}
+		return sem, nil
+	}
+	if !os.IsNotExist(err) {
+		return sem, fmt.Errorf("%w: %v", ErrParsingAcademicSemester, err)
+	}
+
+	name := filepath.Base(folder)
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		if year, sem := splitSemesterSuffix(name[i+1:]); year != "" {
+			key := year + ";" + sem
+			return parser.AcademicSemester{Key: key, Text: key}, nil
+		}
+	}
+
+	body, err = ioutil.ReadFile(filepath.Join(folder, "main.html"))
+	if err != nil {
+		return sem, fmt.Errorf("%w: %v", ErrParsingAcademicSemester, err)
+	}
+	latest, err := parseLatestAcademicSemester(&body)
+	if err != nil {
+		return sem, fmt.Errorf("%w: %v", ErrParsingAcademicSemester, err)
+	}
+	return *latest, nil
+}
+
+// Splits the 2018-1 of a folder name back into 2018 and 1
+func splitSemesterSuffix(suffix string) (string, string) {
+	parts := strings.Split(suffix, "-")
+	if len(parts) != 2 || len(parts[0]) != 4 || parts[1] == "" {
+		return "", ""
+	}
+	if _, err := strconv.Atoi(parts[0]); err != nil {
+		return "", ""
+	}
+	return parts[0], parts[1]
 }
 
 func ensureFolderExists(path string) error {
 	pathStat, err := os.Stat(path)
 	if os.IsNotExist(err) {
-		err = os.Mkdir(path, 0755)
+		err = os.MkdirAll(path, 0755)
 		if err != nil {
 			return err
 		}
@@ -91,31 +159,77 @@ func buildCourseLink(academicSemester parser.AcademicSemester,
 	return courseLinks
 }
 
+// Downloads every course using a fixed number of workers, each of which
+// waits for delay between requests. Returns the links that failed.
 func DownloadAndStoreCourses(courses []*courseLink, delay time.Duration,
-	errChan chan courseLink,
-	folderPath string) {
+	concurrency int,
+	folderPath string) []courseLink {
 
-	for i, link := range courses {
-		courseId := link.course.Id()
-		log.Printf("%d/%d: %s\n", i+1, len(courses), link.course.Text)
-		body, err := DownloadCourse(*link, coursePageURL)
-		if err != nil {
-			errChan <- *link
-			continue
-		}
+	return downloadAndStore(courses, delay, concurrency, folderPath,
+		func(link courseLink) ([]byte, error) {
+			return DownloadCourse(link, coursePageURL)
+		})
+}
 
-		courseBodyPath := fmt.Sprintf("%s/%s.html",
-			folderPath,
-			strconv.FormatUint(courseId, 10))
+// Stores the page fetched for every link as <course id>.html
+func downloadAndStore(courses []*courseLink, delay time.Duration,
+	concurrency int,
+	folderPath string,
+	fetch func(courseLink) ([]byte, error)) []courseLink {
 
-		err = store(courseBodyPath, body)
-		if err != nil {
-			errChan <- *link
-			continue
-		}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []courseLink
+	)
+	fail := func(link courseLink) {
+		mu.Lock()
+		failed = append(failed, link)
+		mu.Unlock()
+	}
+
+	work := make(chan int)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				link := courses[i]
+				courseId := link.course.Id()
+				logging.Infof("%d/%d: %s", i+1, len(courses), link.course.Text)
+				body, err := fetch(*link)
+				if err != nil {
+					logging.Debugf("%s: %v", link.course.Text, err)
+					fail(*link)
+					continue
+				}
+
+				courseBodyPath := fmt.Sprintf("%s/%s.html",
+					folderPath,
+					strconv.FormatUint(courseId, 10))
+
+				err = store(courseBodyPath, body)
+				if err != nil {
+					fail(*link)
+					continue
+				}
+
+				time.Sleep(delay)
+			}
+		}()
+	}
 
-		time.Sleep(delay)
+	for i := range courses {
+		work <- i
 	}
+	close(work)
+	wg.Wait()
+
+	return failed
 }
 
 func DownloadCourse(c courseLink, coursePageURL string) ([]byte, error) {
@@ -130,21 +244,21 @@ func DownloadCourse(c courseLink, coursePageURL string) ([]byte, error) {
 	body := bytes.NewBufferString(form.Encode())
 	req, err := http.NewRequest("POST", coursePageURL, body)
 	if err != nil {
-		log.Printf("failed to build request for %v (%s)\n", c, err)
+		logging.Errorf("failed to build request for %v (%s)", c, err)
 		return nil, err
 	}
 	req.Header.Set("User-Agent", userAgent)
-	log.Printf("Sending request for %s\n", c.course.Text)
+	logging.Debugf("Sending request for %s", c.course.Text)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Printf("failed to send request for %v (%s)\n", c, err)
+		logging.Errorf("failed to send request for %v (%s)", c, err)
 		return nil, err
 	}
-	log.Println("Request complete")
+	logging.Debugf("Request complete")
 	defer res.Body.Close()
 
 	if res.StatusCode == http.StatusOK {
-		log.Println("Copying response into body")
+		logging.Debugf("Copying response into body")
 		bodyBytes, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return nil, err
@@ -160,7 +274,7 @@ type CourseMapping struct {
 }
 
 func CreateCourseMapping(path string, links []*courseLink) error {
-	log.Println("creating course mapping")
+	logging.Infof("creating course mapping")
 	mappings := make([]CourseMapping, len(links))
 	for i, link := range links {
 		mappings[i] = CourseMapping{Course: link.course, Index: link.course.Id()}
@@ -187,6 +301,21 @@ func CreateCourseMapping(path string, links []*courseLink) error {
 	return nil
 }
 
+func ReadCourseMapping(path string) ([]CourseMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mappings []CourseMapping
+	err = json.NewDecoder(f).Decode(&mappings)
+	if err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
 func parseLatestAcademicSemester(mainBody *[]byte) (*parser.AcademicSemester, error) {
 	sem, err := parser.FindLatestAcadSem(bytes.NewReader(*mainBody))
 	if err != nil {
@@ -204,19 +333,36 @@ func parseCourses(mainBody *[]byte) ([]parser.Course, error) {
 	return courses, nil
 }
 
-func Download() {
-	cachedFolderPath := folderCachePath()
+type Options struct {
+	// Folder in which dated snapshot folders are created
+	DataDir string
+	// Overrides the dated snapshot folder
+	Snapshot string
+	// acadsem key such as "2018;1", defaults to the latest semester
+	Semester    string
+	Concurrency int
+	Delay       time.Duration
+}
+
+// The snapshot folder Download writes to
+func (o Options) Folder() string {
+	if o.Snapshot != "" {
+		return o.Snapshot
+	}
+	return folderCachePath(o.DataDir, o.Semester)
+}
+
+func Download(opts Options) error {
+	cachedFolderPath := opts.Folder()
 
 	err := ensureFolderExists(cachedFolderPath)
 	if err != nil {
-		log.Fatalf("%v: %v", ErrEnsureCacheFolderExist, err)
-		return
+		return fmt.Errorf("%w: %v", ErrEnsureCacheFolderExist, err)
 	}
 
 	mainBody, err := DownloadMainBody(mainPageURL)
 	if err != nil {
-		log.Fatalf("%v: %v", ErrFailedToDownloadBody, err)
-		return
+		return fmt.Errorf("%w: %v", ErrFailedToDownloadBody, err)
 	}
 
 	cachedMainBody := fmt.Sprintf("%s/%s", cachedFolderPath, "main.html")
@@ -224,29 +370,35 @@ func Download() {
 
 	latestAcademicSemester, err := parseLatestAcademicSemester(&mainBody)
 	if err != nil {
-		log.Fatalf("%v: %v", ErrParsingAcademicSemester, err)
-		return
+		return fmt.Errorf("%w: %v", ErrParsingAcademicSemester, err)
+	}
+	if opts.Semester != "" {
+		latestAcademicSemester = &parser.AcademicSemester{Key: opts.Semester, Text: opts.Semester}
+	}
+	// Snapshots named with -snapshot have no semester suffix, the file
+	// keeps parse from falling back to the latest semester
+	if err := storeSemester(cachedFolderPath, *latestAcademicSemester); err != nil {
+		return fmt.Errorf("%w: %v", ErrEnsureCacheFolderExist, err)
 	}
 
 	courses, err := parseCourses(&mainBody)
 	if err != nil {
-		log.Fatalf("%v: %v", ErrParsingCourses, err)
-		return
+		return fmt.Errorf("%w: %v", ErrParsingCourses, err)
 	}
 	courseLinks := buildCourseLink(*latestAcademicSemester, courses)
-	delay := 1 * time.Second
-	errChan := make(chan courseLink)
 	jsonFileName := fmt.Sprintf("%s/%s", cachedFolderPath, "mapping.json")
 
 	err = CreateCourseMapping(jsonFileName, courseLinks)
 	if err != nil {
-		log.Fatalf("failed to create mapping: %v", err)
-		return
+		return fmt.Errorf("failed to create mapping: %v", err)
 	}
-	DownloadAndStoreCourses(courseLinks, delay, errChan, cachedFolderPath)
+	failed := DownloadAndStoreCourses(courseLinks, opts.Delay, opts.Concurrency, cachedFolderPath)
 
-	for len(errChan) > 0 {
-		course := <-errChan
-		log.Printf("encountered errors on: %v", course)
+	for _, course := range failed {
+		logging.Warnf("encountered errors on: %v", course.course.Text)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrDownloadingCourses, len(failed), len(courseLinks))
 	}
+	return nil
 }
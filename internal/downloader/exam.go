@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"net/url"
+	"path/filepath"
+)
+
+const (
+	examMainURL   string = "https://wis.ntu.edu.sg/webexe/owa/exam_timetable_und.main"
+	examDetailURL string = "https://wis.ntu.edu.sg/webexe/owa/exam_timetable_und.Get_detail"
+)
+
+// Files the exam timetable is stored as inside a snapshot folder
+const (
+	ExamMainFile = "exam-main.html"
+	ExamsFile    = "exams.html"
+)
+
+var (
+	ErrDownloadingExams = errors.New("downloader: failed to download exam timetable")
+)
+
+type ExamOptions struct {
+	// Snapshot folder the timetable is stored in
+	Snapshot string
+	// Default to the university exam timetable pages
+	MainURL   string
+	DetailURL string
+}
+
+// Downloads the exam timetable of every paper into the snapshot. The main
+// page carries the exam plan as hidden form inputs, which are sent back
+// with empty search fields to list every paper.
+func DownloadExams(opts ExamOptions) error {
+	mainURL, detailURL := opts.MainURL, opts.DetailURL
+	if mainURL == "" {
+		mainURL = examMainURL
+	}
+	if detailURL == "" {
+		detailURL = examDetailURL
+	}
+
+	mainBody, err := DownloadMainBody(mainURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadingExams, err)
+	}
+	if err := store(filepath.Join(opts.Snapshot, ExamMainFile), mainBody); err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadingExams, err)
+	}
+
+	inputs, err := parser.FindHiddenInputs(bytes.NewReader(mainBody))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadingExams, err)
+	}
+	form := url.Values{
+		"p_exam_dt":    {""},
+		"p_start_time": {""},
+		"p_dept":       {""},
+		"p_subj":       {""},
+		"p_venue":      {""},
+		"p_matric":     {""},
+	}
+	for name, value := range inputs {
+		form.Set(name, value)
+	}
+
+	logging.Infof("downloading exam timetable")
+	body, err := postForm(detailURL, form)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadingExams, err)
+	}
+	if err := store(filepath.Join(opts.Snapshot, ExamsFile), body); err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadingExams, err)
+	}
+	return nil
+}
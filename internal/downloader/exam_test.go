@@ -0,0 +1,51 @@
+package downloader_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadExams(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/main", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../../testdata/exam-main.html")
+	})
+	mux.HandleFunc("/detail", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("p_plan_no") != "73" || r.PostForm.Get("p_exam_yr") != "2018" ||
+			r.PostForm["p_subj"] == nil {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		http.ServeFile(w, r, "../../testdata/exam-timetable.html")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = downloader.DownloadExams(downloader.ExamOptions{
+		Snapshot:  dir,
+		MainURL:   server.URL + "/main",
+		DetailURL: server.URL + "/detail",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{downloader.ExamMainFile, downloader.ExamsFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
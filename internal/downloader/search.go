@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSearchingSubjects = errors.New("downloader: failed to search subjects")
+)
+
+// Course key prefix of the pages fetched by subject code
+const SearchKeyPrefix = "SEARCH"
+
+// Key under which the search results for a subject code are stored in
+// the course mapping, e.g. SEARCH;CZ2001;X;F
+func SearchKey(code string) string {
+	return fmt.Sprintf("%s;%s;X;F", SearchKeyPrefix, code)
+}
+
+type SearchOptions struct {
+	// Existing snapshot folder the results are merged into
+	Snapshot string
+	// acadsem key, defaults to the semester in the snapshot's main.html
+	Semester string
+	// Subject codes to look up
+	Codes []string
+	// Subjects already in the snapshot, they are removed from the result
+	// pages and pages without any other subject are dropped
+	Known       map[string]bool
+	Concurrency int
+	Delay       time.Duration
+	// Defaults to the class schedule page
+	URL string
+}
+
+// Looks up subjects by code on the class schedule page and stores the
+// subjects the snapshot is missing from every result page. The pages are
+// added to mapping.json as courses keyed by SearchKey, so they are
+// parsed along with the rest of the snapshot. Codes that were searched
+// before are replaced rather than added twice.
+func Search(opts SearchOptions) error {
+	semester, err := snapshotSemester(opts.Snapshot, opts.Semester)
+	if err != nil {
+		return err
+	}
+	pageURL := opts.URL
+	if pageURL == "" {
+		pageURL = coursePageURL
+	}
+
+	seen := make(map[string]bool)
+	var links []*courseLink
+	for _, code := range opts.Codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" || seen[code] || opts.Known[code] {
+			continue
+		}
+		seen[code] = true
+		links = append(links, &courseLink{
+			semester: semester,
+			course:   parser.Course{Key: SearchKey(code), Text: "Subject search " + code},
+		})
+	}
+	if len(links) == 0 {
+		logging.Infof("no subjects to search for")
+		return nil
+	}
+
+	folder, err := ioutil.TempDir(opts.Snapshot, "search")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSearchingSubjects, err)
+	}
+	defer os.RemoveAll(folder)
+
+	failed := downloadAndStore(links, opts.Delay, opts.Concurrency, folder,
+		func(link courseLink) ([]byte, error) {
+			return SearchSubject(link.semester, strings.Split(link.course.Key, ";")[1], pageURL)
+		})
+	for _, link := range failed {
+		logging.Warnf("encountered errors on: %v", link.course.Text)
+	}
+
+	var found []*courseLink
+	for _, link := range links {
+		name := strconv.FormatUint(link.course.Id(), 10) + ".html"
+		body, err := ioutil.ReadFile(filepath.Join(folder, name))
+		if err != nil {
+			continue
+		}
+		page, ok := newSubjects(body, opts.Known)
+		if !ok {
+			logging.Infof("%s: nothing new", link.course.Text)
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(opts.Snapshot, name), page, 0644); err != nil {
+			return fmt.Errorf("%w: %v", ErrSearchingSubjects, err)
+		}
+		found = append(found, link)
+	}
+
+	if err := mergeCourseMapping(filepath.Join(opts.Snapshot, "mapping.json"), found); err != nil {
+		return fmt.Errorf("%w: %v", ErrSearchingSubjects, err)
+	}
+	logging.Infof("added %d of %d searched subject codes", len(found), len(links))
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrSearchingSubjects, len(failed), len(links))
+	}
+	return nil
+}
+
+// Reads the semester of a snapshot unless one is given
+func snapshotSemester(snapshot, key string) (parser.AcademicSemester, error) {
+	if key != "" {
+		return parser.AcademicSemester{Key: key, Text: key}, nil
+	}
+	return SnapshotSemester(snapshot)
+}
+
+// Removes the known subjects from a result page, false when no other
+// subject is left
+func newSubjects(body []byte, known map[string]bool) ([]byte, bool) {
+	page, err := parser.DropSubjects(bytes.NewReader(body), func(id string) bool {
+		return known[id]
+	})
+	if err != nil {
+		return nil, false
+	}
+	subjects, _, err := parser.FindScheduleWithOptions(bytes.NewReader(page),
+		parser.ScheduleOptions{Lenient: true})
+	if err != nil {
+		return nil, false
+	}
+	for _, subject := range subjects {
+		if subject.Id != "" {
+			return page, true
+		}
+	}
+	return nil, false
+}
+
+// Adds links to the mapping at path, replacing courses with the same key
+func mergeCourseMapping(path string, links []*courseLink) error {
+	mappings, err := ReadCourseMapping(path)
+	if err != nil {
+		return err
+	}
+	merged := make([]*courseLink, 0, len(mappings)+len(links))
+	replaced := make(map[string]bool)
+	for _, link := range links {
+		replaced[link.course.Key] = true
+	}
+	for _, mapping := range mappings {
+		if !replaced[mapping.Key] {
+			merged = append(merged, &courseLink{course: mapping.Course})
+		}
+	}
+	return CreateCourseMapping(path, append(merged, links...))
+}
+
+// Fetches the results of looking up a subject code
+func SearchSubject(semester parser.AcademicSemester, code, coursePageURL string) ([]byte, error) {
+	form := url.Values{
+		"acadsem":       {semester.Key},
+		"r_course_yr":   {""},
+		"r_subj_code":   {code},
+		"r_search_type": {"F"},
+		"boption":       {"Search"},
+		"staff_access":  {"false"},
+	}
+	return postForm(coursePageURL, form)
+}
+
+func postForm(pageURL string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequest("POST", pageURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error: %s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
@@ -0,0 +1,203 @@
+package downloader_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Answers subject searches with fixtures, any other code finds nothing
+func fakeSchedulePage(t *testing.T, requests *[]string) *httptest.Server {
+	pages := map[string][]string{
+		"CZ2001": {"subject-with-details.html"},
+		"AB06":   {"schedule-with-subject.html"},
+		// Finds CZ2001 and AB0601
+		"MIX": {"subject-with-details.html", "schedule-with-subject.html"},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("boption") != "Search" || r.PostForm.Get("acadsem") != "2018;1" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		code := r.PostForm.Get("r_subj_code")
+		*requests = append(*requests, code)
+		names, ok := pages[code]
+		if !ok {
+			w.Write([]byte("<html><body>No courses found</body></html>"))
+			return
+		}
+		for _, name := range names {
+			body, err := ioutil.ReadFile(filepath.Join("../../testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(body)
+		}
+	}))
+}
+
+// Creates a snapshot folder with one course, removed with the test
+func searchSnapshot(t *testing.T) string {
+	dir := t.TempDir()
+	main, err := ioutil.ReadFile("../../testdata/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.html"), main, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping := `[{"Key": "ACC;GA;1;F", "Text": "Accountancy (GA) Year 1", "Index": 1}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, "mapping.json"), []byte(mapping), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSearch(t *testing.T) {
+	var requests []string
+	server := fakeSchedulePage(t, &requests)
+	defer server.Close()
+	dir := searchSnapshot(t)
+
+	opts := downloader.SearchOptions{
+		Snapshot: dir,
+		// XX0000 finds nothing and AB06 only finds AB0601, which is known
+		Codes: []string{"cz2001", "XX0000", "AB06", "CZ2001", "AB0601"},
+		Known: map[string]bool{"AB0601": true},
+		URL:   server.URL,
+	}
+	if err := downloader.Search(opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 3 {
+		t.Errorf("expected one request per unknown code got=%v", requests)
+	}
+
+	// Searching again replaces the results instead of adding them twice
+	if err := downloader.Search(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings, err := downloader.ReadCourseMapping(filepath.Join(dir, "mapping.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 2 || mappings[0].Key != "ACC;GA;1;F" || mappings[1].Key != "SEARCH;CZ2001;X;F" {
+		t.Fatalf("unexpected mapping %+v", mappings)
+	}
+	path := filepath.Join(dir, strconv.FormatUint(mappings[1].Id(), 10)+".html")
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected main.html, mapping.json and one page got=%d entries", len(entries))
+	}
+}
+
+func TestSearchDropsKnownSubjects(t *testing.T) {
+	var requests []string
+	server := fakeSchedulePage(t, &requests)
+	defer server.Close()
+	dir := searchSnapshot(t)
+
+	opts := downloader.SearchOptions{
+		Snapshot: dir,
+		Codes:    []string{"MIX"},
+		Known:    map[string]bool{"AB0601": true},
+		URL:      server.URL,
+	}
+	if err := downloader.Search(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	course := parser.Course{Key: downloader.SearchKey("MIX")}
+	path := filepath.Join(dir, strconv.FormatUint(course.Id(), 10)+".html")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	subjects, err := parser.FindSchedule(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subjects) != 1 || subjects[0].Id != "CZ2001" || len(subjects[0].Schedules) != 2 {
+		t.Errorf("expected only CZ2001 and its 2 sessions got=%+v", subjects)
+	}
+}
+
+func TestSnapshotSemester(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	latest := filepath.Join(dir, "2018-09-13")
+	if err := os.Mkdir(latest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile("../../testdata/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(latest, "main.html"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Crawled for an older semester into a folder named with -snapshot
+	older := filepath.Join(dir, "older")
+	if err := os.Mkdir(older, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string][]byte{
+		"main.html":     body,
+		"semester.json": []byte(`{"Key": "2017;2", "Text": "2017;2"}`),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(older, name), body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	broken := filepath.Join(dir, "broken_2017-2")
+	if err := os.Mkdir(broken, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(broken, "semester.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		folder   string
+		expected string
+		fails    bool
+	}{
+		{latest, "2018;1", false},
+		{older, "2017;2", false},
+		{broken, "", true},
+		{filepath.Join(dir, "2018-09-13_2017-2"), "2017;2", false},
+		{filepath.Join(dir, "2018-09-13_2017-S"), "2017;S", false},
+		{filepath.Join(dir, "missing"), "", true},
+	}
+	for i, test := range cases {
+		sem, err := downloader.SnapshotSemester(test.folder)
+		if sem.Key != test.expected {
+			t.Errorf("id=%d expected=%s got=%s", i, test.expected, sem.Key)
+		}
+		if (err != nil) != test.fails {
+			t.Errorf("id=%d expected fails=%v got=%v", i, test.fails, err)
+		}
+	}
+}
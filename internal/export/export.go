@@ -0,0 +1,78 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownFormat = errors.New("export: unknown format")
+)
+
+var Formats = []string{"json", "csv"}
+
+func Write(w io.Writer, format string, s *snapshot.Snapshot) error {
+	switch format {
+	case "json":
+		return JSON(w, s)
+	case "csv":
+		return CSV(w, s)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func JSON(w io.Writer, s *snapshot.Snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(s)
+}
+
+var csvHeader = []string{
+	"course_uid", "course_key", "course", "programme", "year", "part_time", "general_elective", "subject_uid", "subject", "title", "au", "au_min", "au_max",
+	"prerequisite", "mutually_exclusive", "not_available", "grade_type", "subject_remarks",
+	"session_uid", "index_uid", "index", "type", "group", "day", "time", "venue", "remark",
+}
+
+// Writes one row per session
+func CSV(w io.Writer, s *snapshot.Snapshot) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, course := range s.Courses {
+		for _, subject := range course.Subjects {
+			auMin, auMax := "", ""
+			if subject.AU.Valid {
+				auMin = strconv.FormatFloat(subject.AU.Min, 'f', -1, 64)
+				auMax = strconv.FormatFloat(subject.AU.Max, 'f', -1, 64)
+			}
+			var exclusions []string
+			for _, exclusion := range subject.Exclusions {
+				exclusions = append(exclusions, exclusion.String())
+			}
+			for _, schedule := range subject.Schedules {
+				err := writer.Write([]string{
+					course.UID, course.Key, course.Text,
+					course.Info.Programme, strconv.Itoa(course.Info.Year),
+					strconv.FormatBool(course.Info.PartTime), strconv.FormatBool(course.Info.GeneralElective),
+					subject.UID, subject.Id, subject.Title, subject.AuRaw, auMin, auMax,
+					subject.Prerequisite, strings.Join(subject.MutuallyExclusive, ", "),
+					strings.Join(exclusions, "; "), subject.GradeType, strings.Join(subject.Remarks, "; "),
+					schedule.UID, schedule.IndexUID, schedule.Index, string(schedule.Type), schedule.Group,
+					string(schedule.Day), schedule.TimeText, schedule.Venue, schedule.Remark,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,462 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidArgument = errors.New("gql: invalid argument")
+)
+
+// A session along with the subject it belongs to, what the Session type
+// resolves from
+type session struct {
+	SubjectId string
+	parser.Schedule
+}
+
+// Name of a venue, what the Venue type resolves from
+type venue string
+
+// GraphQL schema over a snapshot and its occupancy
+type Schema struct {
+	schema    graphql.Schema
+	snapshot  *snapshot.Snapshot
+	occupancy *occupancy.Index
+	subjects  map[string]parser.Subject
+	indexes   map[string]planner.Index
+}
+
+// Panics when the schema does not build, which only a change to the
+// types here can cause
+func New(s *snapshot.Snapshot, idx *occupancy.Index) *Schema {
+	sch := &Schema{
+		snapshot:  s,
+		occupancy: idx,
+		subjects:  s.Subjects(),
+		indexes:   make(map[string]planner.Index),
+	}
+	for _, subject := range sch.subjects {
+		for _, index := range planner.Indexes(subject) {
+			sch.indexes[index.Index] = index
+		}
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: sch.queryType()})
+	if err != nil {
+		panic(fmt.Sprintf("gql: %v", err))
+	}
+	sch.schema = schema
+	return sch
+}
+
+// Runs a query, errors are part of the result as GraphQL has them
+func (sch *Schema) Do(ctx context.Context, query string, variables map[string]interface{}, operation string) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         sch.schema,
+		RequestString:  query,
+		VariableValues: variables,
+		OperationName:  operation,
+		Context:        ctx,
+	})
+}
+
+func list(t graphql.Type) *graphql.List {
+	return graphql.NewList(graphql.NewNonNull(t))
+}
+
+func stringArg(p graphql.ResolveParams, name string) string {
+	v, _ := p.Args[name].(string)
+	return v
+}
+
+func (sch *Schema) queryType() *graphql.Object {
+	types := sch.types()
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"semesters": &graphql.Field{
+				Type:        graphql.NewNonNull(list(types.semester)),
+				Description: "Semesters of the served snapshots",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return []parser.AcademicSemester{sch.snapshot.Semester}, nil
+				},
+			},
+			"semester": &graphql.Field{
+				Type:        graphql.NewNonNull(types.semester),
+				Description: "Semester of the served snapshot",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return sch.snapshot.Semester, nil
+				},
+			},
+			"courses": &graphql.Field{
+				Type: graphql.NewNonNull(list(types.course)),
+				Args: graphql.FieldConfigArgument{
+					"programme": {Type: graphql.String},
+					"year":      {Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return sch.courses(p), nil
+				},
+			},
+			"course": &graphql.Field{
+				Type:        types.course,
+				Description: "Course by its uid or key",
+				Args: graphql.FieldConfigArgument{
+					"uid": {Type: graphql.String},
+					"key": {Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					uid, key := stringArg(p, "uid"), stringArg(p, "key")
+					for _, course := range sch.snapshot.Courses {
+						if uid != "" && course.UID == uid || key != "" && course.Key == key {
+							return course, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"subject": &graphql.Field{
+				Type: types.subject,
+				Args: graphql.FieldConfigArgument{
+					"code": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if subject, ok := sch.subjects[strings.ToUpper(stringArg(p, "code"))]; ok {
+						return subject, nil
+					}
+					return nil, nil
+				},
+			},
+			"index": &graphql.Field{
+				Type: types.index,
+				Args: graphql.FieldConfigArgument{
+					"number": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if index, ok := sch.indexes[stringArg(p, "number")]; ok {
+						return index, nil
+					}
+					return nil, nil
+				},
+			},
+			"venue": &graphql.Field{
+				Type: types.venue,
+				Args: graphql.FieldConfigArgument{
+					"name": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name := stringArg(p, "name")
+					if len(sch.occupancy.Bookings(name)) == 0 && len(sch.occupancy.Exams(name)) == 0 {
+						return nil, nil
+					}
+					return venue(name), nil
+				},
+			},
+			"venues": &graphql.Field{
+				Type: graphql.NewNonNull(list(types.venue)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return venues(sch.occupancy.Venues()), nil
+				},
+			},
+			"freeRooms": &graphql.Field{
+				Type: graphql.NewNonNull(list(types.venue)),
+				Description: "Venues free for the whole of from to to on a day, or on a date which also " +
+					"takes the exam timetable into account",
+				Args: graphql.FieldConfigArgument{
+					"day":  {Type: graphql.String, Description: "e.g. WED"},
+					"date": {Type: graphql.String, Description: "e.g. 2018-11-19, instead of day"},
+					"from": {Type: graphql.NewNonNull(graphql.String), Description: "e.g. 0830"},
+					"to":   {Type: graphql.String, Description: "e.g. 1030, an hour after from by default"},
+				},
+				Resolve: sch.freeRooms,
+			},
+		},
+	})
+}
+
+func venues(names []string) []venue {
+	result := make([]venue, 0, len(names))
+	for _, name := range names {
+		result = append(result, venue(name))
+	}
+	return result
+}
+
+func (sch *Schema) courses(p graphql.ResolveParams) []snapshot.Course {
+	programme := stringArg(p, "programme")
+	year, _ := p.Args["year"].(int)
+	var courses []snapshot.Course
+	for _, course := range sch.snapshot.Courses {
+		if programme != "" && !strings.EqualFold(course.Info.Programme, programme) {
+			continue
+		}
+		if year != 0 && course.Info.Year != year {
+			continue
+		}
+		courses = append(courses, course)
+	}
+	return courses
+}
+
+func (sch *Schema) freeRooms(p graphql.ResolveParams) (interface{}, error) {
+	from, err := occupancy.ParseClock(stringArg(p, "from"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: from: %v", ErrInvalidArgument, err)
+	}
+	to := from + 60
+	if v := stringArg(p, "to"); v != "" {
+		if to, err = occupancy.ParseClock(v); err != nil {
+			return nil, fmt.Errorf("%w: to: %v", ErrInvalidArgument, err)
+		}
+	} else if to > 24*60 {
+		to = 24 * 60
+	}
+	if to <= from {
+		return nil, fmt.Errorf("%w: to: %s is not after %s", ErrInvalidArgument, to, from)
+	}
+	if v := stringArg(p, "date"); v != "" {
+		date, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date: %v", ErrInvalidArgument, err)
+		}
+		return venues(sch.occupancy.FreeOn(date, from, to)), nil
+	}
+	day, err := parser.ParseWeekday(stringArg(p, "day"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: day: %v", ErrInvalidArgument, err)
+	}
+	return venues(sch.occupancy.Free(day, from, to)), nil
+}
+
+type objectTypes struct {
+	semester, course, subject, index, session, venue *graphql.Object
+}
+
+// The object types, fields are thunks as the types refer to each other
+func (sch *Schema) types() *objectTypes {
+	t := &objectTypes{}
+	t.semester = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Semester",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"key":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "acadsem key, e.g. 2018;1"},
+				"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: semesterName},
+				"courses": &graphql.Field{
+					Type: graphql.NewNonNull(list(t.course)),
+					Args: graphql.FieldConfigArgument{
+						"programme": {Type: graphql.String},
+						"year":      {Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return sch.courses(p), nil
+					},
+				},
+			}
+		}),
+	})
+
+	t.course = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Course",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"uid":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"key":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: courseField},
+				"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: courseField},
+				"programme": &graphql.Field{Type: graphql.String, Resolve: courseField},
+				"year":      &graphql.Field{Type: graphql.Int, Resolve: courseField},
+				"partTime":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: courseField},
+				"subjects": &graphql.Field{
+					Type: graphql.NewNonNull(list(t.subject)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(snapshot.Course).Subjects, nil
+					},
+				},
+			}
+		}),
+	})
+
+	t.subject = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subject",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"uid":          &graphql.Field{Type: graphql.String},
+				"code":         &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: subjectField},
+				"title":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"au":           &graphql.Field{Type: graphql.Float, Description: "null when not a number", Resolve: subjectField},
+				"prerequisite": &graphql.Field{Type: graphql.String},
+				"indexes": &graphql.Field{
+					Type: graphql.NewNonNull(list(t.index)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return planner.Indexes(p.Source.(parser.Subject)), nil
+					},
+				},
+			}
+		}),
+	})
+
+	t.index = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Index",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"number": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(planner.Index).Index, nil
+				}},
+				"uid": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if sessions := p.Source.(planner.Index).Sessions; len(sessions) > 0 {
+						return sessions[0].IndexUID, nil
+					}
+					return nil, nil
+				}},
+				"subject": &graphql.Field{Type: graphql.NewNonNull(t.subject), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return sch.subjects[p.Source.(planner.Index).SubjectId], nil
+				}},
+				"sessions": &graphql.Field{Type: graphql.NewNonNull(list(t.session)), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					index := p.Source.(planner.Index)
+					sessions := make([]session, 0, len(index.Sessions))
+					for _, s := range index.Sessions {
+						sessions = append(sessions, session{SubjectId: index.SubjectId, Schedule: s})
+					}
+					return sessions, nil
+				}},
+			}
+		}),
+	})
+
+	t.session = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Session",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"uid":    &graphql.Field{Type: graphql.String, Resolve: sessionField},
+				"type":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: sessionField},
+				"group":  &graphql.Field{Type: graphql.String, Resolve: sessionField},
+				"day":    &graphql.Field{Type: graphql.String, Resolve: sessionField},
+				"time":   &graphql.Field{Type: graphql.String, Description: "e.g. 0830-0930", Resolve: sessionField},
+				"remark": &graphql.Field{Type: graphql.String, Resolve: sessionField},
+				"weeks":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "Teaching weeks, e.g. 2-13", Resolve: sessionField},
+				"subject": &graphql.Field{Type: graphql.NewNonNull(t.subject), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return sch.subjects[p.Source.(session).SubjectId], nil
+				}},
+				"index": &graphql.Field{Type: t.index, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if index, ok := sch.indexes[p.Source.(session).Index]; ok {
+						return index, nil
+					}
+					return nil, nil
+				}},
+				"venue": &graphql.Field{Type: t.venue, Description: "null for sessions without a venue", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if name := p.Source.(session).Venue; name != "" {
+						return venue(name), nil
+					}
+					return nil, nil
+				}},
+			}
+		}),
+	})
+
+	t.venue = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Venue",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return string(p.Source.(venue)), nil
+				}},
+				"building": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return parser.Building(string(p.Source.(venue))), nil
+				}},
+				"sessions": &graphql.Field{
+					Type:        graphql.NewNonNull(list(t.session)),
+					Description: "Weekly bookings ordered by day and time",
+					Args:        graphql.FieldConfigArgument{"day": {Type: graphql.String}},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						var day parser.Weekday
+						if v := stringArg(p, "day"); v != "" {
+							var err error
+							if day, err = parser.ParseWeekday(v); err != nil {
+								return nil, fmt.Errorf("%w: day: %v", ErrInvalidArgument, err)
+							}
+						}
+						sessions := make([]session, 0)
+						for _, b := range sch.occupancy.Bookings(string(p.Source.(venue))) {
+							if day == "" || b.Day == day {
+								sessions = append(sessions, session{SubjectId: b.SubjectId, Schedule: b.Schedule})
+							}
+						}
+						return sessions, nil
+					},
+				},
+			}
+		}),
+	})
+	return t
+}
+
+func semesterName(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source.(parser.AcademicSemester).Text, nil
+}
+
+func courseField(p graphql.ResolveParams) (interface{}, error) {
+	course := p.Source.(snapshot.Course)
+	switch p.Info.FieldName {
+	case "key":
+		return course.Key, nil
+	case "name":
+		return course.Text, nil
+	case "partTime":
+		return course.Info.PartTime, nil
+	case "programme":
+		if course.Info.Programme == "" {
+			return nil, nil
+		}
+		return course.Info.Programme, nil
+	case "year":
+		if course.Info.Year == 0 {
+			return nil, nil
+		}
+		return course.Info.Year, nil
+	}
+	return nil, nil
+}
+
+func subjectField(p graphql.ResolveParams) (interface{}, error) {
+	subject := p.Source.(parser.Subject)
+	switch p.Info.FieldName {
+	case "code":
+		return subject.Id, nil
+	case "au":
+		if !subject.AU.Valid {
+			return nil, nil
+		}
+		return subject.AU.Min, nil
+	}
+	return nil, nil
+}
+
+func sessionField(p graphql.ResolveParams) (interface{}, error) {
+	s := p.Source.(session)
+	switch p.Info.FieldName {
+	case "uid":
+		return s.UID, nil
+	case "type":
+		return string(s.Type), nil
+	case "group":
+		return s.Group, nil
+	case "day":
+		return string(s.Day), nil
+	case "time":
+		return s.TimeText, nil
+	case "remark":
+		return s.Remark, nil
+	case "weeks":
+		return s.Weeks().String(), nil
+	}
+	return nil, nil
+}
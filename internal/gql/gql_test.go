@@ -0,0 +1,190 @@
+package gql_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/gql"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(index string, t parser.SessionType, day parser.Weekday, venue string, from, to time.Time) parser.Schedule {
+	return parser.Schedule{Index: index, Type: t, Day: day, Venue: venue,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+func newSchema() *gql.Schema {
+	cz2001 := parser.Subject{
+		Id:    "CZ2001",
+		Title: "ALGORITHMS",
+		AU:    parser.AcademicUnits{Min: 3, Max: 3, Valid: true},
+		Schedules: []parser.Schedule{
+			session("10105", parser.Lecture, parser.Monday, "LT2A", at(13, 30), at(14, 30)),
+			session("10105", parser.Tutorial, parser.Tuesday, "TR+15", at(9, 30), at(10, 30)),
+			session("10106", parser.Lecture, parser.Monday, "LT2A", at(13, 30), at(14, 30)),
+			session("10106", parser.Tutorial, parser.Wednesday, "TR+15", at(8, 30), at(9, 30)),
+		},
+	}
+	s := &snapshot.Snapshot{
+		Path:     "data/2018-09-13",
+		Semester: parser.AcademicSemester{Key: "2018;1", Text: "Acad Yr 2018 Semester 1"},
+		Courses: []snapshot.Course{
+			{
+				CourseMapping: downloader.CourseMapping{Course: parser.Course{Key: "CSC;;2;F", Text: "CSC Year 2"}},
+				UID:           "2018;1:course:CSC;;2;F",
+				Info:          parser.CourseInfo{Programme: "CSC", Year: 2},
+				Subjects:      []parser.Subject{cz2001},
+			},
+			{
+				CourseMapping: downloader.CourseMapping{Course: parser.Course{Key: "CE;;1;F", Text: "CE Year 1"}},
+				UID:           "2018;1:course:CE;;1;F",
+				Info:          parser.CourseInfo{Programme: "CE", Year: 1},
+				Subjects:      []parser.Subject{cz2001},
+			},
+		},
+	}
+	return gql.New(s, occupancy.New(s))
+}
+
+func TestDo(t *testing.T) {
+	schema := newSchema()
+	cases := []struct {
+		query     string
+		variables map[string]interface{}
+		expected  string
+	}{
+		{`{semester{key name}}`, nil,
+			`{"semester":{"key":"2018;1","name":"Acad Yr 2018 Semester 1"}}`},
+		{`{courses(programme: "csc"){key programme year partTime}}`, nil,
+			`{"courses":[{"key":"CSC;;2;F","partTime":false,"programme":"CSC","year":2}]}`},
+		{`{semesters{courses(year: 1){name}}}`, nil,
+			`{"semesters":[{"courses":[{"name":"CE Year 1"}]}]}`},
+		{`{course(key: "CSC;;2;F"){subjects{code au indexes{number sessions{type day time venue{name building}}}}}}`, nil,
+			`{"course":{"subjects":[{"au":3,"code":"CZ2001","indexes":[` +
+				`{"number":"10105","sessions":[` +
+				`{"day":"MON","time":"1330-1430","type":"LEC/STUDIO","venue":{"building":"LT2A","name":"LT2A"}},` +
+				`{"day":"TUE","time":"0930-1030","type":"TUT","venue":{"building":"TR+15","name":"TR+15"}}]},` +
+				`{"number":"10106","sessions":[` +
+				`{"day":"MON","time":"1330-1430","type":"LEC/STUDIO","venue":{"building":"LT2A","name":"LT2A"}},` +
+				`{"day":"WED","time":"0830-0930","type":"TUT","venue":{"building":"TR+15","name":"TR+15"}}]}]}]}}`},
+		{`{course(uid: "nope"){key}}`, nil, `{"course":null}`},
+		{`query($n: String!){index(number: $n){subject{title} sessions{weeks index{number}}}}`,
+			map[string]interface{}{"n": "10106"},
+			`{"index":{"sessions":[{"index":{"number":"10106"},"weeks":"1-13"},{"index":{"number":"10106"},"weeks":"1-13"}],` +
+				`"subject":{"title":"ALGORITHMS"}}}`},
+		{`{subject(code: "cz2001"){indexes{number}}}`, nil,
+			`{"subject":{"indexes":[{"number":"10105"},{"number":"10106"}]}}`},
+		{`{venue(name: "TR+15"){sessions(day: "WED"){subject{code} time}}}`, nil,
+			`{"venue":{"sessions":[{"subject":{"code":"CZ2001"},"time":"0830-0930"}]}}`},
+		{`{venue(name: "LT99"){name}}`, nil, `{"venue":null}`},
+		{`{venues{name}}`, nil, `{"venues":[{"name":"LT2A"},{"name":"TR+15"}]}`},
+		{`{freeRooms(day: "MON", from: "1400"){name}}`, nil, `{"freeRooms":[{"name":"TR+15"}]}`},
+		{`{freeRooms(day: "TUE", from: "0900", to: "1000"){name}}`, nil, `{"freeRooms":[{"name":"LT2A"}]}`},
+		{`{freeRooms(date: "2018-08-20", from: "0800", to: "0900"){name}}`, nil,
+			`{"freeRooms":[{"name":"LT2A"},{"name":"TR+15"}]}`},
+	}
+
+	for id, c := range cases {
+		result := schema.Do(context.Background(), c.query, c.variables, "")
+		if result.HasErrors() {
+			t.Errorf("id=%d unexpected errors %v", id, result.Errors)
+			continue
+		}
+		got, err := json.Marshal(result.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.expected {
+			t.Errorf("id=%d expected=%s got=%s", id, c.expected, got)
+		}
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	schema := newSchema()
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{`{freeRooms(day: "FUNDAY", from: "1000"){name}}`, "gql: invalid argument: day"},
+		{`{freeRooms(day: "MON", from: "10am"){name}}`, "gql: invalid argument: from"},
+		{`{freeRooms(date: "19/11/2018", from: "1000"){name}}`, "gql: invalid argument: date"},
+		{`{freeRooms(day: "MON", from: "1000", to: "1000"){name}}`, "gql: invalid argument: to: 1000 is not after 1000"},
+		{`{freeRooms(day: "MON", from: "1400", to: "0900"){name}}`, "gql: invalid argument: to: 0900 is not after 1400"},
+		{`{freeRooms(day: "MON", from: "2400"){name}}`, "gql: invalid argument: to: 2400 is not after 2400"},
+		{`{venue(name: "LT2A"){sessions(day: "X"){time}}}`, "gql: invalid argument: day"},
+		{`{semester{nope}}`, `Cannot query field "nope"`},
+		{`{subject{code}}`, `argument "code" of type "String!" is required`},
+	}
+
+	for id, c := range cases {
+		result := schema.Do(context.Background(), c.query, nil, "")
+		if !result.HasErrors() {
+			t.Errorf("id=%d expected=%q got no error", id, c.expected)
+			continue
+		}
+		if got := result.Errors[0].Message; !strings.Contains(got, c.expected) {
+			t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := httptest.NewServer(newSchema())
+	defer server.Close()
+
+	cases := []struct {
+		method   string
+		query    string
+		body     string
+		status   int
+		expected string
+	}{
+		{http.MethodPost, "", `{"query": "query Q($v: String!){venue(name: $v){name}}", "variables": {"v": "LT2A"}}`,
+			http.StatusOK, `{"data":{"venue":{"name":"LT2A"}}}`},
+		{http.MethodGet, "?query=" + url.QueryEscape(`{semester{key}}`), "",
+			http.StatusOK, `{"data":{"semester":{"key":"2018;1"}}}`},
+		{http.MethodGet, "?query=" + url.QueryEscape(`query($d: String){freeRooms(day: $d, from: "1400"){name}}`) +
+			"&variables=" + url.QueryEscape(`{"d": "MON"}`), "",
+			http.StatusOK, `{"data":{"freeRooms":[{"name":"TR+15"}]}}`},
+		{http.MethodGet, "?query=" + url.QueryEscape(`{semester{`), "", http.StatusBadRequest, `"errors"`},
+		{http.MethodGet, "", "", http.StatusBadRequest, "missing query"},
+		{http.MethodPost, "", `{"query":`, http.StatusBadRequest, "unexpected EOF"},
+		{http.MethodPut, "", "", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+
+	for id, c := range cases {
+		request, err := http.NewRequest(c.method, server.URL+c.query, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != c.status {
+			t.Errorf("id=%d expected=%d got=%d", id, c.status, response.StatusCode)
+		}
+		if !strings.Contains(string(body), c.expected) {
+			t.Errorf("id=%d expected=%s got=%s", id, c.expected, body)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gql
+
+import (
+	"encoding/json"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"net/http"
+	"strings"
+)
+
+// Body of a POST request, GET requests carry the same in the query string
+// with variables as JSON
+type request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// POST /api/graphql with {"query", "variables", "operationName"}, or
+// GET /api/graphql?query={semester{name}}
+func (sch *Schema) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		http.Error(w, "missing query", http.StatusBadRequest)
+		return
+	}
+
+	result := sch.Do(r.Context(), req.Query, req.Variables, req.OperationName)
+	// Errors in resolvers still answer 200 along with the data that did
+	// resolve, as is usual for GraphQL
+	status := http.StatusOK
+	if result.Data == nil && result.HasErrors() {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logging.Errorf("failed to encode response %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package grid
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+)
+
+// Minutes in a row of the grid
+const SlotMinutes = 30
+
+// A session placed on the grid
+type Item struct {
+	// e.g. the subject and type
+	Label string
+	// e.g. the index or venue
+	Detail string
+	Day    parser.Weekday
+	Start  occupancy.Clock
+	End    occupancy.Clock
+	Weeks  parser.Weeks
+	// Column within the day, sessions that overlap get different lanes
+	Lane int
+}
+
+// Teaching weeks of the item, empty when it is held every week
+func (i *Item) WeekNote() string {
+	if i.Weeks == parser.AllWeeks {
+		return ""
+	}
+	return "Wk" + i.Weeks.String()
+}
+
+// A week of sessions, days by half hour slots
+type Grid struct {
+	Title string
+	// Monday to Friday and any weekend day with sessions
+	Days []parser.Weekday
+	// At least 0800 to 1800, wider when sessions start earlier or end later
+	Start, End occupancy.Clock
+	// Lanes of each day in the order of Days
+	Lanes []int
+	Items []Item
+}
+
+func (g *Grid) Slots() int {
+	return int(g.End-g.Start) / SlotMinutes
+}
+
+func (g *Grid) slotOf(c occupancy.Clock) int {
+	return int(c-g.Start) / SlotMinutes
+}
+
+// First slot of the item and the number of slots it touches
+func (g *Grid) span(item *Item) (int, int) {
+	first := g.slotOf(item.Start)
+	return first, g.slotOf(item.End-1) - first + 1
+}
+
+// Builds a grid out of items, their Lane is set here
+func New(title string, items []Item) *Grid {
+	g := &Grid{Title: title, Start: 8 * 60, End: 18 * 60}
+	booked := make(map[parser.Weekday]bool)
+	for _, item := range items {
+		if item.End <= item.Start || !item.Day.Valid() {
+			continue
+		}
+		if item.Start < g.Start {
+			g.Start = item.Start / SlotMinutes * SlotMinutes
+		}
+		if item.End > g.End {
+			g.End = (item.End + SlotMinutes - 1) / SlotMinutes * SlotMinutes
+		}
+		booked[item.Day] = true
+		g.Items = append(g.Items, item)
+	}
+	for _, day := range parser.Weekdays {
+		if day != parser.Saturday && day != parser.Sunday || booked[day] {
+			g.Days = append(g.Days, day)
+		}
+	}
+
+	sort.SliceStable(g.Items, func(i, j int) bool {
+		a, b := g.Items[i], g.Items[j]
+		if a.Day != b.Day {
+			return a.Day.Before(b.Day)
+		}
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.End > b.End
+	})
+	g.Lanes = make([]int, len(g.Days))
+	for d, day := range g.Days {
+		// Last slot taken in each lane, items sharing a slot cannot share
+		// a lane even when one ends before the other starts
+		var last []int
+		for i := range g.Items {
+			item := &g.Items[i]
+			if item.Day != day {
+				continue
+			}
+			first, slots := g.span(item)
+			item.Lane = len(last)
+			for lane, end := range last {
+				if end < first {
+					item.Lane = lane
+					break
+				}
+			}
+			if item.Lane == len(last) {
+				last = append(last, first+slots-1)
+			} else {
+				last[item.Lane] = first + slots - 1
+			}
+		}
+		g.Lanes[d] = len(last)
+		if g.Lanes[d] == 0 {
+			g.Lanes[d] = 1
+		}
+	}
+	return g
+}
+
+// Item for a session labelled with its subject and type, detail is
+// usually the index or venue
+func FromSchedule(subjectId string, s parser.Schedule, detail string) Item {
+	start, end := occupancy.SessionClocks(s)
+	return Item{
+		Label:  subjectId + " " + string(s.Type),
+		Detail: detail,
+		Day:    s.Day,
+		Start:  start,
+		End:    end,
+		Weeks:  s.Weeks(),
+	}
+}
+
+// Grid of the weekly bookings of a room
+func Room(venue string, bookings []occupancy.Booking) *Grid {
+	var items []Item
+	for _, b := range bookings {
+		item := FromSchedule(b.SubjectId, b.Schedule, b.Schedule.Index)
+		item.Start, item.End = b.Start, b.End
+		items = append(items, item)
+	}
+	return New(venue, items)
+}
+
+// Grid of the sessions of indexes, e.g. a timetable
+func Indexes(title string, indexes ...planner.Index) *Grid {
+	var items []Item
+	for _, index := range indexes {
+		for _, s := range index.Sessions {
+			if s.TimeText == "" {
+				continue
+			}
+			items = append(items, FromSchedule(index.SubjectId, s, index.Index+" "+s.Venue))
+		}
+	}
+	return New(title, items)
+}
+
+// Cell of a lane in a slot
+type cell struct {
+	Item *Item
+	// Slot of the cell within Item, 0 where it starts
+	Offset int
+}
+
+// The cells of every slot, lanes of each day in the order of Days
+func (g *Grid) cells() [][]cell {
+	columns := g.columns()
+	width := 0
+	for _, lanes := range g.Lanes {
+		width += lanes
+	}
+	rows := make([][]cell, g.Slots())
+	for i := range rows {
+		rows[i] = make([]cell, width)
+	}
+	for i := range g.Items {
+		item := &g.Items[i]
+		d := dayColumn(g, item)
+		if d < 0 {
+			continue
+		}
+		first, slots := g.span(item)
+		for n := 0; n < slots; n++ {
+			rows[first+n][columns[d]+item.Lane] = cell{Item: item, Offset: n}
+		}
+	}
+	return rows
+}
+
+func dayColumn(g *Grid, item *Item) int {
+	for d, day := range g.Days {
+		if day == item.Day {
+			return d
+		}
+	}
+	return -1
+}
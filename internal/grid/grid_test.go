@@ -0,0 +1,145 @@
+package grid_test
+
+import (
+	"bytes"
+	"github.com/jaxsax/ntu-room-finder/internal/grid"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(t parser.SessionType, day parser.Weekday, from, to time.Time, venue, remark string) parser.Schedule {
+	return parser.Schedule{Index: "10105", Type: t, Day: day, Venue: venue, Remark: remark,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+func timetable() *grid.Grid {
+	return grid.Indexes("10105 and 20201",
+		planner.Index{SubjectId: "CZ2001", Index: "10105", Sessions: []parser.Schedule{
+			session(parser.Lecture, parser.Monday, at(8, 30), at(10, 30), "LT2A", ""),
+			session(parser.Tutorial, parser.Wednesday, at(9, 30), at(10, 30), "TR+15", "Teaching Wk2-13"),
+		}},
+		planner.Index{SubjectId: "CZ2002", Index: "20201", Sessions: []parser.Schedule{
+			// Overlaps the CZ2001 lecture
+			session(parser.Laboratory, parser.Monday, at(9, 30), at(11, 30), "HWLAB1", "Teaching Wk1,3,5,7,9,11,13"),
+			// Shares the 1000 slot with the lecture although it starts after it ends
+			session(parser.Tutorial, parser.Monday, at(10, 45), at(11, 15), "TR+5", ""),
+			session(parser.Seminar, parser.Saturday, at(18, 0), at(19, 0), "LT1", ""),
+			// Online sessions without a time are left out
+			{Index: "20201", Type: parser.Lecture, Venue: "ONLINE"},
+		}},
+	)
+}
+
+func TestNew(t *testing.T) {
+	g := timetable()
+	days := []parser.Weekday{parser.Monday, parser.Tuesday, parser.Wednesday, parser.Thursday, parser.Friday,
+		parser.Saturday}
+	if len(g.Days) != len(days) || g.Days[5] != parser.Saturday {
+		t.Errorf("expected=%v got=%v", days, g.Days)
+	}
+	if g.Start.String() != "0800" || g.End.String() != "1900" || g.Slots() != 22 {
+		t.Errorf("expected=0800-1900 got=%s-%s", g.Start, g.End)
+	}
+	if g.Lanes[0] != 2 || g.Lanes[1] != 1 {
+		t.Errorf("expected=2,1 got=%v", g.Lanes)
+	}
+
+	cases := []struct {
+		label string
+		lane  int
+		weeks string
+	}{
+		{"CZ2001 LEC/STUDIO", 0, ""},
+		{"CZ2002 LAB", 1, "Wk1,3,5,7,9,11,13"},
+		{"CZ2002 TUT", 0, ""},
+		{"CZ2001 TUT", 0, "Wk2-13"},
+		{"CZ2002 SEM", 0, ""},
+	}
+	if len(g.Items) != len(cases) {
+		t.Fatalf("expected=%d items got=%+v", len(cases), g.Items)
+	}
+	for i, test := range cases {
+		item := g.Items[i]
+		if item.Label != test.label || item.Lane != test.lane || item.WeekNote() != test.weeks {
+			t.Errorf("id=%d expected=%s,%d,%s got=%s,%d,%s", i, test.label, test.lane, test.weeks,
+				item.Label, item.Lane, item.WeekNote())
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := grid.Write(&buf, "text", timetable()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	expected := []string{
+		"10105 and 20201",
+		"     |MON                                |TUE              |WED              |THU              |FRI              |SAT",
+		"0800 |                 |                 |                 |                 |                 |                 |",
+		"0830 |CZ2001 LEC/STUDI |                 |                 |                 |                 |                 |",
+		"0900 |10105 LT2A       |                 |                 |                 |                 |                 |",
+		"0930 |.                |CZ2002 LAB       |                 |CZ2001 TUT       |                 |                 |",
+		"1000 |.                |20201 HWLAB1 Wk1 |                 |10105 TR+15 Wk2- |                 |                 |",
+		"1030 |CZ2002 TUT       |.                |                 |                 |                 |                 |",
+		"1100 |20201 TR+5       |.                |                 |                 |                 |                 |",
+	}
+	for i, line := range expected {
+		if i >= len(lines) || lines[i] != line {
+			t.Errorf("id=%d expected=%q got=%q", i, line, lines[i])
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := grid.Write(&buf, "html", timetable()); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{
+		"<caption>10105 and 20201</caption>",
+		`<th colspan="2">MON</th><th colspan="1">TUE</th>`,
+		`<td class="session" rowspan="4"><b>CZ2001 LEC/STUDIO</b> 0830-1030<br>10105 LT2A</td>`,
+		`<td class="session" rowspan="2"><b>CZ2001 TUT</b> 0930-1030<br>10105 TR+15<br><small>Wk2-13</small></td>`,
+		// A 30 minute session in the middle of two slots spans both
+		`<td class="session" rowspan="2"><b>CZ2002 TUT</b> 1045-1115`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("expected html containing %q got=%s", s, html)
+		}
+	}
+	// The header and a row per slot
+	if rows := strings.Count(html, "<tr><th>"); rows != 23 {
+		t.Errorf("expected=23 rows got=%d", rows)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := grid.Write(&buf, "svg", timetable()); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	for _, s := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="888" height="552"`,
+		// The lab is in the second lane of Monday from 0930 to 1130
+		`<rect x="170" y="97" width="116" height="94" rx="3"`,
+		`>Wk1,3,5,7,9,11,13</text>`,
+		`>10105 TR+15</text>`,
+	} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("expected svg containing %q got=%s", s, svg)
+		}
+	}
+	if err := grid.Write(&buf, "png", timetable()); err == nil {
+		t.Errorf("expected=%v got=nil", grid.ErrUnknownFormat)
+	}
+}
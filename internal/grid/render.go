@@ -0,0 +1,194 @@
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"html"
+	"io"
+	"strings"
+)
+
+var (
+	ErrUnknownFormat = errors.New("grid: unknown format")
+)
+
+var Formats = []string{"text", "html", "svg"}
+
+func Write(w io.Writer, format string, g *Grid) error {
+	switch format {
+	case "text":
+		return Text(w, g)
+	case "html":
+		return HTML(w, g)
+	case "svg":
+		return SVG(w, g)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// Columns of the lanes of each day in the order of Days
+func (g *Grid) columns() []int {
+	columns := make([]int, len(g.Days))
+	width := 0
+	for d, lanes := range g.Lanes {
+		columns[d] = width
+		width += lanes
+	}
+	return columns
+}
+
+// Second line of an item, its detail and teaching weeks
+func (i *Item) note() string {
+	return strings.TrimSpace(i.Detail + " " + i.WeekNote())
+}
+
+// Characters in a lane of the text grid
+const textWidth = 16
+
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
+
+// Writes the grid for a terminal, a row per slot with the label of a
+// session where it starts, its detail and weeks in the next row and dots
+// for as long as it lasts
+func Text(w io.Writer, g *Grid) error {
+	var b strings.Builder
+	if g.Title != "" {
+		fmt.Fprintf(&b, "%s\n", g.Title)
+	}
+	line := "    "
+	for d, day := range g.Days {
+		line += " |" + fit(string(day), g.Lanes[d]*(textWidth+2)-2)
+	}
+	b.WriteString(strings.TrimRight(line, " ") + "\n")
+
+	for slot, row := range g.cells() {
+		line := occupancy.Clock(int(g.Start) + slot*SlotMinutes).String()
+		for _, c := range row {
+			text := ""
+			switch {
+			case c.Item == nil:
+			case c.Offset == 0:
+				text = c.Item.Label
+			case c.Offset == 1 && c.Item.note() != "":
+				text = c.Item.note()
+			default:
+				text = "."
+			}
+			line += " |" + fit(text, textWidth)
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the grid as a table, sessions span the rows of their slots
+func HTML(w io.Writer, g *Grid) error {
+	var b strings.Builder
+	b.WriteString(`<table class="grid">`)
+	if g.Title != "" {
+		fmt.Fprintf(&b, "\n<caption>%s</caption>", html.EscapeString(g.Title))
+	}
+	b.WriteString("\n<tr><th></th>")
+	for d, day := range g.Days {
+		fmt.Fprintf(&b, `<th colspan="%d">%s</th>`, g.Lanes[d], day)
+	}
+	b.WriteString("</tr>\n")
+
+	for slot, row := range g.cells() {
+		start := occupancy.Clock(int(g.Start) + slot*SlotMinutes)
+		fmt.Fprintf(&b, "<tr><th>%s</th>", start)
+		for _, c := range row {
+			switch {
+			case c.Item == nil:
+				b.WriteString("<td></td>")
+			case c.Offset == 0:
+				_, slots := g.span(c.Item)
+				fmt.Fprintf(&b, `<td class="session" rowspan="%d"><b>%s</b> %s-%s`, slots,
+					html.EscapeString(c.Item.Label), c.Item.Start, c.Item.End)
+				if c.Item.Detail != "" {
+					fmt.Fprintf(&b, "<br>%s", html.EscapeString(c.Item.Detail))
+				}
+				if note := c.Item.WeekNote(); note != "" {
+					fmt.Fprintf(&b, "<br><small>%s</small>", note)
+				}
+				b.WriteString("</td>")
+			}
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+const (
+	svgTimeWidth  = 48
+	svgLaneWidth  = 120
+	svgHeader     = 24
+	svgSlotHeight = 24
+)
+
+// Writes the grid as a standalone SVG image, sessions are placed by their
+// exact times
+func SVG(w io.Writer, g *Grid) error {
+	columns := g.columns()
+	lanes := 0
+	for _, n := range g.Lanes {
+		lanes += n
+	}
+	width := svgTimeWidth + lanes*svgLaneWidth
+	height := svgHeader + g.Slots()*svgSlotHeight
+	y := func(c occupancy.Clock) float64 {
+		return svgHeader + float64(c-g.Start)*svgSlotHeight/SlotMinutes
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	if g.Title != "" {
+		fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(g.Title))
+	}
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	for slot := 0; slot < g.Slots(); slot++ {
+		start := occupancy.Clock(int(g.Start) + slot*SlotMinutes)
+		fmt.Fprintf(&b, `<line x1="0" y1="%g" x2="%d" y2="%g" stroke="#ddd"/>`+"\n", y(start), width, y(start))
+		fmt.Fprintf(&b, `<text x="4" y="%g">%s</text>`+"\n", y(start)+14, start)
+	}
+	for d, day := range g.Days {
+		x := svgTimeWidth + columns[d]*svgLaneWidth
+		fmt.Fprintf(&b, `<line x1="%d" y1="0" x2="%d" y2="%d" stroke="#999"/>`+"\n", x, x, height)
+		fmt.Fprintf(&b, `<text x="%d" y="16" font-weight="bold">%s</text>`+"\n", x+4, day)
+	}
+
+	for i := range g.Items {
+		item := &g.Items[i]
+		d := dayColumn(g, item)
+		if d < 0 {
+			continue
+		}
+		x := svgTimeWidth + (columns[d]+item.Lane)*svgLaneWidth + 2
+		top, bottom := y(item.Start), y(item.End)
+		fmt.Fprintf(&b, `<rect x="%d" y="%g" width="%d" height="%g" rx="3" fill="#fde2c8" stroke="#e0a070"/>`+"\n",
+			x, top+1, svgLaneWidth-4, bottom-top-2)
+		n := 0
+		for _, line := range []string{item.Label, item.Start.String() + "-" + item.End.String(), item.Detail, item.WeekNote()} {
+			// Only the lines that fit in the box
+			if line == "" || top+float64(14*(n+1)) > bottom {
+				continue
+			}
+			n++
+			fmt.Fprintf(&b, `<text x="%d" y="%g">%s</text>`+"\n", x+4, top+float64(14*n), html.EscapeString(line))
+		}
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var ErrUnknownLevel = errors.New("logging: unknown level")
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+var current = Info
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// Accepts one of debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("%w: %q", ErrUnknownLevel, s)
+}
+
+func SetLevel(l Level) {
+	current = l
+}
+
+func Enabled(l Level) bool {
+	return l >= current
+}
+
+func logf(l Level, format string, v ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+	log.Output(3, strings.ToUpper(l.String())+" "+fmt.Sprintf(format, v...))
+}
+
+func Debugf(format string, v ...interface{}) { logf(Debug, format, v...) }
+func Infof(format string, v ...interface{})  { logf(Info, format, v...) }
+func Warnf(format string, v ...interface{})  { logf(Warn, format, v...) }
+func Errorf(format string, v ...interface{}) { logf(Error, format, v...) }
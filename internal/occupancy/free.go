@@ -0,0 +1,174 @@
+package occupancy
+
+import (
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+	"strings"
+	"time"
+)
+
+// When a building is open, Close may be 2400
+type Hours struct {
+	Open, Close Clock
+}
+
+var AllDay = Hours{Open: 0, Close: 24 * 60}
+
+type OpeningHours struct {
+	Default Hours
+	// Hours of buildings keyed by venue prefix, e.g. LT or NS4, the
+	// longest matching prefix wins
+	Buildings map[string]Hours
+}
+
+// Returns the hours of the building a venue is in
+func (o OpeningHours) Of(venue string) Hours {
+	hours, longest := o.Default, -1
+	for prefix, h := range o.Buildings {
+		if strings.HasPrefix(venue, prefix) && len(prefix) > longest {
+			hours, longest = h, len(prefix)
+		}
+	}
+	return hours
+}
+
+// Limits free blocks and slots to opening hours, venues are open all day
+// until this is called
+func (idx *Index) SetOpeningHours(o OpeningHours) {
+	idx.hours = o
+}
+
+// Returns the opening hours of the building of venue
+func (idx *Index) HoursOf(venue string) Hours {
+	return idx.hours.Of(venue)
+}
+
+// A free part of a day
+type Block struct {
+	Day   parser.Weekday
+	Start Clock
+	End   Clock
+}
+
+func (b Block) Duration() time.Duration {
+	return time.Duration(b.End-b.Start) * time.Minute
+}
+
+type span struct {
+	start, end Clock
+}
+
+// Returns the gaps between busy spans within hours that start at or
+// after from
+func gaps(hours Hours, busy []span, from Clock) []span {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start < busy[j].start })
+	start := hours.Open
+	if from > start {
+		start = from
+	}
+	var free []span
+	for _, b := range busy {
+		if b.start > start {
+			end := b.start
+			if end > hours.Close {
+				end = hours.Close
+			}
+			if end > start {
+				free = append(free, span{start, end})
+			}
+		}
+		if b.end > start {
+			start = b.end
+		}
+	}
+	if hours.Close > start {
+		free = append(free, span{start, hours.Close})
+	}
+	return free
+}
+
+// Returns the parts of day at least minDuration long in which the venue
+// has no weekly bookings and its building is open
+func (idx *Index) FreeBlocks(venue string, day parser.Weekday, minDuration time.Duration) []Block {
+	var busy []span
+	for _, b := range idx.bookings[venue] {
+		if b.Day == day {
+			busy = append(busy, span{b.Start, b.End})
+		}
+	}
+
+	var blocks []Block
+	for _, gap := range gaps(idx.hours.Of(venue), busy, 0) {
+		block := Block{Day: day, Start: gap.start, End: gap.end}
+		if block.Duration() >= minDuration {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// A free period on a specific date
+type Slot struct {
+	Start, End time.Time
+}
+
+// How far ahead NextFree looks
+const nextFreeDays = 14
+
+// The time c on the date of date in loc
+func timeOn(date time.Time, c Clock, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, int(c), 0, 0, loc)
+}
+
+// Returns the first free period of the venue at or after after, which
+// starts at after when the venue is free then. The timetable is read in
+// the wall clock of after and the slot is in its location. Dates in the
+// exam period only have exams, see IsFreeOn. ok is false when the venue
+// is booked or closed for the next two weeks.
+func (idx *Index) NextFree(venue string, after time.Time) (slot Slot, ok bool) {
+	hours := idx.hours.Of(venue)
+	date := dateOf(after)
+	from := clockOf(after.Hour(), after.Minute())
+	for i := 0; i < nextFreeDays; i++ {
+		free := gaps(hours, idx.busyOn(venue, date), from)
+		if len(free) > 0 {
+			return Slot{
+				Start: timeOn(date, free[0].start, after.Location()),
+				End:   timeOn(date, free[0].end, after.Location()),
+			}, true
+		}
+		date = date.AddDate(0, 0, 1)
+		from = 0
+	}
+	return Slot{}, false
+}
+
+// Returns what occupies the venue on a date
+func (idx *Index) busyOn(venue string, date time.Time) []span {
+	var busy []span
+	if !idx.InExamPeriod(date) {
+		day := parser.WeekdayOf(date.Weekday())
+		for _, b := range idx.bookings[venue] {
+			if b.Day == day {
+				busy = append(busy, span{b.Start, b.End})
+			}
+		}
+		return busy
+	}
+
+	next := date.AddDate(0, 0, 1)
+	for _, b := range idx.exams[venue] {
+		if !b.Start.Before(next) || !date.Before(b.End) {
+			continue
+		}
+		s := span{0, 24 * 60}
+		if b.Start.After(date) {
+			s.start = Clock(b.Start.Sub(date) / time.Minute)
+		}
+		if b.End.Before(next) {
+			s.end = Clock(b.End.Sub(date) / time.Minute)
+		}
+		busy = append(busy, s)
+	}
+	return busy
+}
@@ -0,0 +1,114 @@
+package occupancy_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+	"time"
+)
+
+func freeSnapshot() *snapshot.Snapshot {
+	lesson := func(day parser.Weekday, venue string, from, to time.Time) parser.Schedule {
+		return parser.Schedule{Index: "10101", Type: parser.Tutorial, Day: day, Venue: venue,
+			TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+	}
+	return &snapshot.Snapshot{
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id: "CZ2001",
+			Schedules: []parser.Schedule{
+				lesson(parser.Monday, "TR+15", at(9, 30), at(10, 30)),
+				lesson(parser.Monday, "TR+15", at(10, 30), at(12, 30)),
+				lesson(parser.Monday, "TR+15", at(14, 0), at(15, 0)),
+				lesson(parser.Monday, "LT1A", at(8, 0), at(21, 0)),
+				lesson(parser.Tuesday, "TR+15", at(7, 0), at(23, 0)),
+			},
+		}}}},
+		Exams: []parser.Exam{
+			{SubjectId: "CZ2001", Venue: "TR+15", Duration: 2 * time.Hour,
+				Start: time.Date(2018, 11, 19, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+}
+
+func TestFreeBlocks(t *testing.T) {
+	idx := occupancy.New(freeSnapshot())
+	idx.SetOpeningHours(occupancy.OpeningHours{
+		Default:   occupancy.Hours{Open: clock(t, "0800"), Close: clock(t, "2200")},
+		Buildings: map[string]occupancy.Hours{"LT": {Open: clock(t, "0700"), Close: clock(t, "2300")}},
+	})
+
+	cases := []struct {
+		venue       string
+		day         parser.Weekday
+		minDuration time.Duration
+		expected    []string
+	}{
+		{"TR+15", parser.Monday, 0, []string{"0800-0930", "1230-1400", "1500-2200"}},
+		{"TR+15", parser.Monday, 2 * time.Hour, []string{"1500-2200"}},
+		{"TR+15", parser.Tuesday, 0, nil},
+		{"TR+15", parser.Wednesday, 14 * time.Hour, []string{"0800-2200"}},
+		{"LT1A", parser.Monday, time.Hour, []string{"0700-0800", "2100-2300"}},
+		{"unknown", parser.Friday, 0, []string{"0800-2200"}},
+	}
+	for i, test := range cases {
+		var blocks []string
+		for _, b := range idx.FreeBlocks(test.venue, test.day, test.minDuration) {
+			blocks = append(blocks, b.Start.String()+"-"+b.End.String())
+		}
+		if len(blocks) != len(test.expected) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expected, blocks)
+			continue
+		}
+		for j := range blocks {
+			if blocks[j] != test.expected[j] {
+				t.Errorf("id=%d expected=%v got=%v", i, test.expected, blocks)
+			}
+		}
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	idx := occupancy.New(freeSnapshot())
+	idx.SetOpeningHours(occupancy.OpeningHours{
+		Default: occupancy.Hours{Open: clock(t, "0800"), Close: clock(t, "2200")},
+	})
+	date := func(day, hour, minute int) time.Time {
+		return time.Date(2018, 9, day, hour, minute, 0, 0, time.UTC)
+	}
+	november := func(day, hour, minute int) time.Time {
+		return time.Date(2018, 11, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		after      time.Time
+		start, end time.Time
+	}{
+		// Monday 17th, free until the 0930 tutorial
+		{date(17, 9, 0), date(17, 9, 0), date(17, 9, 30)},
+		// Inside the back to back tutorials
+		{date(17, 10, 0), date(17, 12, 30), date(17, 14, 0)},
+		// Before opening
+		{date(17, 6, 0), date(17, 8, 0), date(17, 9, 30)},
+		// After closing, Tuesday is fully booked so Wednesday
+		{date(17, 23, 0), date(19, 8, 0), date(19, 22, 0)},
+		// Exam period, the weekly tutorials do not run
+		{november(19, 8, 30), november(19, 8, 30), november(19, 9, 0)},
+		{november(19, 10, 0), november(19, 11, 0), november(19, 22, 0)},
+	}
+	for i, test := range cases {
+		slot, ok := idx.NextFree("TR+15", test.after)
+		if !ok || !slot.Start.Equal(test.start) || !slot.End.Equal(test.end) {
+			t.Errorf("id=%d expected=%v-%v got=%v-%v ok=%v", i, test.start, test.end, slot.Start, slot.End, ok)
+		}
+	}
+
+	// The slot is in the location of after, 1000 in Singapore is 1000 on
+	// the timetable
+	sgt := time.FixedZone("SGT", 8*60*60)
+	slot, ok := idx.NextFree("TR+15", time.Date(2018, 9, 17, 10, 0, 0, 0, sgt))
+	expected := "2018-09-17T12:30:00+08:00 2018-09-17T14:00:00+08:00"
+	if got := slot.Start.Format(time.RFC3339) + " " + slot.End.Format(time.RFC3339); !ok || got != expected {
+		t.Errorf("expected=%s got=%s ok=%v", expected, got, ok)
+	}
+}
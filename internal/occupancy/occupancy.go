@@ -0,0 +1,256 @@
+package occupancy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidClock = errors.New("occupancy: invalid clock time")
+)
+
+// Minutes since midnight
+type Clock int
+
+// Accepts 24 hour times such as 0830 or 1730, and 2400 for the end of
+// the day
+func ParseClock(s string) (Clock, error) {
+	if len(s) != 4 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+		}
+	}
+	if s == "2400" {
+		return 24 * 60, nil
+	}
+	hour, _ := strconv.Atoi(s[:2])
+	minute, _ := strconv.Atoi(s[2:])
+	if hour > 23 || minute > 59 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	return Clock(hour*60 + minute), nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d%02d", int(c)/60, int(c)%60)
+}
+
+func (c Clock) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Clock) UnmarshalText(text []byte) error {
+	parsed, err := ParseClock(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+type Booking struct {
+	Venue     string
+	Day       parser.Weekday
+	Start     Clock
+	End       Clock
+	SubjectId string
+	Schedule  parser.Schedule
+}
+
+func (b Booking) Overlaps(day parser.Weekday, start, end Clock) bool {
+	return b.Day == day && b.Start < end && start < b.End
+}
+
+// A paper of the exam timetable held in a venue
+type ExamBooking struct {
+	Venue      string
+	Start, End time.Time
+	Exam       parser.Exam
+}
+
+// Weekly bookings of every venue in a snapshot, along with the exams
+// held in them
+type Index struct {
+	bookings map[string][]Booking
+	exams    map[string][]ExamBooking
+	// First and last day of the exam period, zero without exams
+	examStart, examEnd time.Time
+	hours              OpeningHours
+}
+
+func New(s *snapshot.Snapshot) *Index {
+	idx := &Index{
+		bookings: make(map[string][]Booking),
+		exams:    make(map[string][]ExamBooking),
+		hours:    OpeningHours{Default: AllDay},
+	}
+	for _, exam := range s.Exams {
+		if exam.Venue == "" {
+			continue
+		}
+		idx.AddExam(ExamBooking{Venue: exam.Venue, Start: exam.Start, End: exam.End(), Exam: exam})
+	}
+	for _, subject := range s.Subjects() {
+		for _, schedule := range subject.Schedules {
+			if schedule.Venue == "" || schedule.TimeText == "" {
+				continue
+			}
+			start, end := SessionClocks(schedule)
+			idx.Add(Booking{
+				Venue:     schedule.Venue,
+				Day:       schedule.Day,
+				Start:     start,
+				End:       end,
+				SubjectId: subject.Id,
+				Schedule:  schedule,
+			})
+		}
+	}
+	return idx
+}
+
+func clockOf(hour, minute int) Clock {
+	return Clock(hour*60 + minute)
+}
+
+// Start and end of a session. A session until 2400 has its TimeEnd at
+// midnight of the next day, its end is 24*60 rather than 0.
+func SessionClocks(s parser.Schedule) (Clock, Clock) {
+	start := clockOf(s.TimeStart.Hour(), s.TimeStart.Minute())
+	end := clockOf(s.TimeEnd.Hour(), s.TimeEnd.Minute())
+	if end == 0 && s.TimeEnd.After(s.TimeStart) {
+		end = 24 * 60
+	}
+	return start, end
+}
+
+func (idx *Index) Add(b Booking) {
+	idx.bookings[b.Venue] = append(idx.bookings[b.Venue], b)
+}
+
+func (idx *Index) AddExam(b ExamBooking) {
+	idx.exams[b.Venue] = append(idx.exams[b.Venue], b)
+	day := dateOf(b.Start)
+	if idx.examStart.IsZero() || day.Before(idx.examStart) {
+		idx.examStart = day
+	}
+	if day.After(idx.examEnd) {
+		idx.examEnd = day
+	}
+}
+
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Returns every known venue, sorted
+func (idx *Index) Venues() []string {
+	venues := make([]string, 0, len(idx.bookings))
+	for venue := range idx.bookings {
+		venues = append(venues, venue)
+	}
+	for venue := range idx.exams {
+		if _, ok := idx.bookings[venue]; !ok {
+			venues = append(venues, venue)
+		}
+	}
+	sort.Strings(venues)
+	return venues
+}
+
+// Returns the first and last day of the exam period, ok is false when
+// the snapshot has no exams with a venue
+func (idx *Index) ExamPeriod() (start, end time.Time, ok bool) {
+	return idx.examStart, idx.examEnd, !idx.examStart.IsZero()
+}
+
+func (idx *Index) InExamPeriod(date time.Time) bool {
+	day := dateOf(date)
+	return !idx.examStart.IsZero() && !day.Before(idx.examStart) && !day.After(idx.examEnd)
+}
+
+// Returns the exams held in a venue ordered by start
+func (idx *Index) Exams(venue string) []ExamBooking {
+	exams := append([]ExamBooking(nil), idx.exams[venue]...)
+	sort.Slice(exams, func(i, j int) bool {
+		if !exams[i].Start.Equal(exams[j].Start) {
+			return exams[i].Start.Before(exams[j].Start)
+		}
+		return exams[i].Exam.SubjectId < exams[j].Exam.SubjectId
+	})
+	return exams
+}
+
+// Returns the bookings of a venue ordered by day and start time
+func (idx *Index) Bookings(venue string) []Booking {
+	bookings := append([]Booking(nil), idx.bookings[venue]...)
+	sort.Slice(bookings, func(i, j int) bool {
+		a, b := bookings[i], bookings[j]
+		if a.Day != b.Day {
+			return a.Day.Before(b.Day)
+		}
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.SubjectId < b.SubjectId
+	})
+	return bookings
+}
+
+func (idx *Index) IsFree(venue string, day parser.Weekday, start, end Clock) bool {
+	for _, b := range idx.bookings[venue] {
+		if b.Overlaps(day, start, end) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the venues with no bookings between start and end on day
+func (idx *Index) Free(day parser.Weekday, start, end Clock) []string {
+	var free []string
+	for _, venue := range idx.Venues() {
+		if idx.IsFree(venue, day, start, end) {
+			free = append(free, venue)
+		}
+	}
+	return free
+}
+
+// Like IsFree for a specific date. Classes do not run during the exam
+// period so only exams are checked then, on other dates the weekly
+// bookings of that weekday are.
+func (idx *Index) IsFreeOn(venue string, date time.Time, start, end Clock) bool {
+	if !idx.InExamPeriod(date) {
+		return idx.IsFree(venue, parser.WeekdayOf(date.Weekday()), start, end)
+	}
+	day := dateOf(date)
+	from := day.Add(time.Duration(start) * time.Minute)
+	to := day.Add(time.Duration(end) * time.Minute)
+	for _, b := range idx.exams[venue] {
+		if b.Start.Before(to) && from.Before(b.End) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the venues that are free between start and end on date
+func (idx *Index) FreeOn(date time.Time, start, end Clock) []string {
+	var free []string
+	for _, venue := range idx.Venues() {
+		if idx.IsFreeOn(venue, date, start, end) {
+			free = append(free, venue)
+		}
+	}
+	return free
+}
@@ -0,0 +1,148 @@
+package occupancy_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func clock(t *testing.T, s string) occupancy.Clock {
+	c, err := occupancy.ParseClock(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func at(hour, minute int) time.Time {
+	return time.Date(2018, 9, 12, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseClock(t *testing.T) {
+	cases := []struct {
+		text           string
+		expected       occupancy.Clock
+		expectedToFail bool
+	}{
+		{"0000", 0, false},
+		{"0830", 8*60 + 30, false},
+		{"2359", 23*60 + 59, false},
+		{"2400", 24 * 60, false},
+		{"2401", 0, true},
+		{"2430", 0, true},
+		{"2459", 0, true},
+		{"2500", 0, true},
+		{"0860", 0, true},
+		{"-100", 0, true},
+		{"+100", 0, true},
+		{"08:3", 0, true},
+		{"830", 0, true},
+		{"08300", 0, true},
+		{"", 0, true},
+	}
+
+	for id, c := range cases {
+		got, err := occupancy.ParseClock(c.text)
+		if c.expectedToFail {
+			if !errors.Is(err, occupancy.ErrInvalidClock) {
+				t.Errorf("id=%d expected=%v got=%v %v", id, occupancy.ErrInvalidClock, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.expected {
+			t.Errorf("id=%d expected=%d got=%d %v", id, c.expected, got, err)
+		}
+	}
+}
+
+func TestExamOccupancy(t *testing.T) {
+	s := &snapshot.Snapshot{
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id: "AB0601",
+			Schedules: []parser.Schedule{{
+				Index: "00731", Type: parser.Lecture, Day: parser.Monday, Venue: "LT26",
+				TimeText: "0900-1100", TimeStart: at(9, 0), TimeEnd: at(11, 0),
+			}},
+		}}}},
+		Exams: []parser.Exam{
+			{SubjectId: "CZ2001", Venue: "NORTH SPINE HALL", Duration: 2 * time.Hour,
+				Start: time.Date(2018, 11, 19, 13, 0, 0, 0, time.UTC)},
+			{SubjectId: "AB0601", Venue: "LT26", Duration: 2 * time.Hour,
+				Start: time.Date(2018, 11, 21, 9, 0, 0, 0, time.UTC)},
+			{SubjectId: "HE9091", Duration: time.Hour,
+				Start: time.Date(2018, 11, 23, 17, 0, 0, 0, time.UTC)},
+		},
+	}
+	idx := occupancy.New(s)
+
+	start, end, ok := idx.ExamPeriod()
+	if !ok || start.Day() != 19 || end.Day() != 21 {
+		t.Errorf("unexpected exam period %v %v %v", start, end, ok)
+	}
+	if !reflect.DeepEqual(idx.Venues(), []string{"LT26", "NORTH SPINE HALL"}) {
+		t.Errorf("unexpected venues %v", idx.Venues())
+	}
+
+	cases := []struct {
+		date     time.Time
+		from, to string
+		expected []string
+	}{
+		// A Monday in the teaching weeks follows the weekly bookings
+		{time.Date(2018, 9, 17, 0, 0, 0, 0, time.UTC), "1000", "1200", []string{"NORTH SPINE HALL"}},
+		// Monday of the exam period, the lecture does not run
+		{time.Date(2018, 11, 19, 0, 0, 0, 0, time.UTC), "1000", "1200", []string{"LT26", "NORTH SPINE HALL"}},
+		{time.Date(2018, 11, 19, 0, 0, 0, 0, time.UTC), "1400", "1430", []string{"LT26"}},
+		{time.Date(2018, 11, 19, 0, 0, 0, 0, time.UTC), "1500", "1600", []string{"LT26", "NORTH SPINE HALL"}},
+		{time.Date(2018, 11, 21, 0, 0, 0, 0, time.UTC), "0830", "0901", []string{"NORTH SPINE HALL"}},
+	}
+	for i, test := range cases {
+		free := idx.FreeOn(test.date, clock(t, test.from), clock(t, test.to))
+		if !reflect.DeepEqual(free, test.expected) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expected, free)
+		}
+	}
+
+	exams := idx.Exams("LT26")
+	if len(exams) != 1 || exams[0].Exam.SubjectId != "AB0601" || !exams[0].End.Equal(time.Date(2018, 11, 21, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected exams %v", exams)
+	}
+}
+
+func TestSessionClocks(t *testing.T) {
+	cases := []struct {
+		start, end time.Time
+		expected   [2]occupancy.Clock
+	}{
+		{at(9, 0), at(11, 0), [2]occupancy.Clock{9 * 60, 11 * 60}},
+		// 2400 is read as midnight of the next day
+		{at(22, 30), at(24, 0), [2]occupancy.Clock{22*60 + 30, 24 * 60}},
+		{at(0, 0), at(1, 0), [2]occupancy.Clock{0, 60}},
+		{time.Time{}, time.Time{}, [2]occupancy.Clock{0, 0}},
+	}
+
+	for id, c := range cases {
+		start, end := occupancy.SessionClocks(parser.Schedule{TimeStart: c.start, TimeEnd: c.end})
+		if start != c.expected[0] || end != c.expected[1] {
+			t.Errorf("id=%d expected=%v got=%v", id, c.expected, [2]occupancy.Clock{start, end})
+		}
+	}
+
+	s := &snapshot.Snapshot{
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id: "AB0601",
+			Schedules: []parser.Schedule{{
+				Index: "00731", Type: parser.Lecture, Day: parser.Monday, Venue: "LT26",
+				TimeText: "2230-2400", TimeStart: at(22, 30), TimeEnd: at(24, 0),
+			}},
+		}}}},
+	}
+	idx := occupancy.New(s)
+	if idx.IsFree("LT26", parser.Monday, clock(t, "2300"), clock(t, "2400")) {
+		t.Errorf("expected LT26 to be booked until 2400")
+	}
+}
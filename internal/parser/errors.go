@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"sort"
+)
+
+var (
+	ErrCourseFileMissing = errors.New("parser: course file missing")
+	ErrInvalidHTML       = errors.New("parser: invalid course html")
+	ErrScheduleTable     = errors.New("parser: invalid schedule table")
+	ErrCoursesFailed     = errors.New("parser: courses failed to parse")
+	ErrInvalidExams      = errors.New("parser: invalid exam timetable")
+)
+
+// Why a single course could not be parsed. Kind is one of
+// ErrCourseFileMissing, ErrInvalidHTML or ErrScheduleTable, Err is the
+// underlying error, a *parser.ScheduleError for schedule table problems.
+type CourseError struct {
+	Course downloader.CourseMapping
+	Path   string
+	Kind   error
+	Err    error
+}
+
+func (e *CourseError) Error() string {
+	return fmt.Sprintf("%s (%s): %v: %v", e.Course.Text, e.Path, e.Kind, e.Err)
+}
+
+func (e *CourseError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Totals of a parse run, Skipped holds the schedule rows left out in
+// lenient mode
+type Summary struct {
+	Courses   int
+	Parsed    int
+	Subjects  int
+	Schedules int
+	Errors    []*CourseError
+	Skipped   []*CourseError
+}
+
+func (s *Summary) Failed() int {
+	return len(s.Errors)
+}
+
+// Logs the totals followed by the number of failures of each kind
+func (s *Summary) Log() {
+	logging.Infof("parsed %d/%d courses, %d subjects, %d schedules",
+		s.Parsed, s.Courses, s.Subjects, s.Schedules)
+	if len(s.Skipped) > 0 {
+		logging.Warnf("skipped %d schedule rows", len(s.Skipped))
+	}
+	if s.Failed() == 0 {
+		return
+	}
+
+	byKind := make(map[string]int)
+	for _, err := range s.Errors {
+		byKind[err.Kind.Error()]++
+	}
+	kinds := make([]string, 0, len(byKind))
+	for kind := range byKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	logging.Warnf("%d courses failed", s.Failed())
+	for _, kind := range kinds {
+		logging.Warnf("  %d x %s", byKind[kind], kind)
+	}
+}
+
+// Returns nil when every course was parsed
+func (s *Summary) Err() error {
+	if s.Failed() == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d", ErrCoursesFailed, s.Failed(), s.Courses)
+}
@@ -1,16 +1,20 @@
 package parser
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
 	"github.com/jaxsax/ntu-room-finder/internal/schedule"
 	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"syscall"
 )
 
@@ -21,8 +25,6 @@ func setupSqlOutput(f string) (*os.File, error) {
 	if _, err := os.Stat(f); os.IsExist(err) {
 		outputFile, err = os.OpenFile(f, os.O_APPEND|os.O_WRONLY, 0600)
 		if err != nil {
-			log.Fatalf("failed to truncate %s %v", f, err)
-
 			return nil, err
 		}
 		outputFile.Truncate(0)
@@ -30,132 +32,271 @@ func setupSqlOutput(f string) (*os.File, error) {
 	} else {
 		outputFile, err = os.Create(f)
 		if err != nil {
-			log.Fatalf("failed to create %s for writing %v", f, err)
 			return nil, err
 		}
 	}
 	return outputFile, nil
 }
 
-func Parse(p string) {
+type Options struct {
+	// Snapshot folder produced by the crawler
+	Snapshot string
+	// Path of the generated SQL file
+	Output string
+	// SQL prepended to the output, usually sql/init.sql
+	InitSQL string
+	// Return an error when any course fails instead of only logging it
+	FailOnError bool
+	CourseOptions
+}
+
+// How the course pages of a snapshot are parsed
+type CourseOptions struct {
+	Concurrency int
+	// Skip schedule rows that cannot be parsed instead of failing the
+	// whole course, skipped rows are reported in the summary. Bad exam
+	// rows are skipped with a warning.
+	Lenient bool
+	// acadsem key the stable IDs of subjects and sessions are built
+	// from, see parser.SubjectID
+	Semester string
+}
+
+func Parse(opts Options) (*Summary, error) {
 	go sigInt()
 
-	outputFileName := "out.sql"
+	p := opts.Snapshot
+	outputFileName := opts.Output
 	outputFile, err := setupSqlOutput(outputFileName)
 	if err != nil {
-		log.Fatalf("failed to setup %s %v", outputFileName, err)
-		return
+		return nil, fmt.Errorf("failed to setup %s %v", outputFileName, err)
 	}
 	defer outputFile.Close()
 
-	initSQLFile := "sql/init.sql"
+	initSQLFile := opts.InitSQL
 	initSQL, err := ioutil.ReadFile(initSQLFile)
 	if err != nil {
-		log.Fatalf("failed to read %s %v", initSQLFile, err)
-		return
+		return nil, fmt.Errorf("failed to read %s %v", initSQLFile, err)
 	}
 
 	_, err = outputFile.Write(initSQL)
 	if err != nil {
-		log.Fatalf("failed to write initializing sql %v", err)
-		return
+		return nil, fmt.Errorf("failed to write initializing sql %v", err)
 	}
 
-	courseMappings, err := getCoursesToParse(fmt.Sprintf("%s/%s", p, "mapping.json"))
+	courseMappings, err := downloader.ReadCourseMapping(fmt.Sprintf("%s/%s", p, "mapping.json"))
 	if err != nil {
-		log.Fatalf("failed to find course file names %v", err)
-		return
+		return nil, fmt.Errorf("failed to find course file names %v", err)
 	}
 
-	sqlIn := make(chan []byte)
-	sqlDone := make(chan empty)
-	scheduleErr := make(chan downloader.CourseMapping)
+	if opts.Semester == "" {
+		semester, err := downloader.SnapshotSemester(p)
+		if err != nil {
+			logging.Warnf("ids are built without a semester: %v", err)
+		}
+		opts.Semester = semester.Key
+	}
 
-	go sqlCombiner(sqlIn, sqlDone, outputFile)
+	summary, err := ParseCourses(p, courseMappings, opts.CourseOptions, func(r Result) error {
+		_, err := outputFile.Write(generateSQLForParsed(opts.Semester, r.Course, r.Subjects))
+		return err
+	})
+	if err != nil {
+		return summary, err
+	}
 
-	parseFiles(p, courseMappings, sqlIn, scheduleErr)
-	for i := 0; i < len(courseMappings); i++ {
-		select {
-		case something := <-scheduleErr:
-			log.Printf("error parsing: %v", something)
-			break
-		case <-sqlDone:
-			log.Printf("done %d/%d", i+1, len(courseMappings))
-			break
+	if err := writeExams(p, opts.CourseOptions, outputFile); err != nil {
+		logging.Errorf("%v", err)
+		if opts.FailOnError {
+			return summary, err
 		}
 	}
+
+	summary.Log()
+	if opts.FailOnError {
+		return summary, summary.Err()
+	}
+	return summary, nil
 }
 
-func sqlCombiner(in chan []byte, done chan empty, outputFile *os.File) {
-	for {
-		generatedSQL := <-in
-		log.Printf("ingested an item, there are %d items", len(in))
-		outputFile.Write(generatedSQL)
+// Writes the exam timetable of the snapshot if it was crawled
+func writeExams(folderPath string, opts CourseOptions, w io.Writer) error {
+	f, err := os.Open(filepath.Join(folderPath, downloader.ExamsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExams, err)
+	}
+	defer f.Close()
 
-		done <- empty{}
+	exams, skipped, err := parser.FindExamsWithOptions(f, parser.ExamOptions{Lenient: opts.Lenient})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExams, err)
+	}
+	for _, row := range skipped {
+		logging.Warnf("skipping exam: %v", row)
 	}
+	logging.Infof("parsed %d exams", len(exams))
+	_, err = w.Write(schedule.GenerateExamSQL(opts.Semester, exams))
+	return err
+}
+
+// A successfully parsed course
+type Result struct {
+	Course   downloader.CourseMapping
+	Subjects []parser.Subject
+}
+
+// The outcome of the course at position index of the mapping
+type parsedCourse struct {
+	index    int
+	subjects []parser.Subject
+	skipped  []*CourseError
+	err      *CourseError
 }
 
+// Parses the course pages of a snapshot folder with a pool of workers
+// and calls fn for every parsed course in mapping order, so the same
+// snapshot always produces the same output. Courses that fail are
+// collected in the summary instead. Parsing stops at the first error
+// returned by fn.
+func ParseCourses(folderPath string,
+	courses []downloader.CourseMapping,
+	opts CourseOptions,
+	fn func(Result) error) (*Summary, error) {
+
+	results := make(chan parsedCourse)
+	go parseFiles(folderPath, courses, opts, results)
+
+	return combiner(results, courses, fn)
+}
+
+// Hands results to fn in mapping order. Courses that finish early are
+// held back until every course before them has been handed over.
+func combiner(in chan parsedCourse,
+	courses []downloader.CourseMapping,
+	fn func(Result) error) (*Summary, error) {
+
+	summary := &Summary{Courses: len(courses)}
+	var fnErr error
+	pending := make(map[int]parsedCourse)
+	next := 0
+	for received := 0; received < len(courses); received++ {
+		result := <-in
+		pending[result.index] = result
+		logging.Debugf("ingested an item, there are %d items held back", len(pending)-1)
+
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			for _, skipped := range result.skipped {
+				logging.Warnf("skipped row: %v", skipped)
+			}
+			summary.Skipped = append(summary.Skipped, result.skipped...)
+			if result.err != nil {
+				logging.Errorf("error parsing: %v", result.err)
+				summary.Errors = append(summary.Errors, result.err)
+				continue
+			}
+			// Keep draining after fn fails so no worker is left blocked
+			if fnErr != nil {
+				continue
+			}
+			fnErr = fn(Result{Course: courses[result.index], Subjects: result.subjects})
+
+			summary.Parsed++
+			summary.Subjects += len(result.subjects)
+			for _, subject := range result.subjects {
+				summary.Schedules += len(subject.Schedules)
+			}
+			logging.Debugf("done %d/%d", next, len(courses))
+		}
+	}
+	return summary, fnErr
+}
+
+// Feeds every course to a fixed number of workers
 func parseFiles(folderPath string,
 	courses []downloader.CourseMapping,
-	sqlIn chan []byte,
-	scheduleErr chan downloader.CourseMapping) {
-	for _, c := range courses {
-		go processCourseFile(c, sqlIn, scheduleErr, folderPath)
+	opts CourseOptions,
+	results chan parsedCourse) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				subjects, skipped, err := processCourseFile(courses[i], folderPath, opts.Lenient)
+				for j := range subjects {
+					subjects[j].SetIDs(opts.Semester)
+				}
+				results <- parsedCourse{index: i, subjects: subjects, skipped: skipped, err: err}
+			}
+		}()
+	}
+
+	for i := range courses {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
 }
 
 func processCourseFile(c downloader.CourseMapping,
-	sqlIn chan []byte,
-	scheduleErr chan downloader.CourseMapping,
-	folderPath string) {
+	folderPath string,
+	lenient bool) ([]parser.Subject, []*CourseError, *CourseError) {
 
 	courseFile := fmt.Sprintf("%s/%s.html", folderPath, strconv.FormatUint(c.Id(), 10))
-	f, err := os.Open(courseFile)
-	if err != nil {
-		fmt.Printf("failed to open %s", courseFile)
-		scheduleErr <- c
-		return
+	courseErr := func(kind, err error) *CourseError {
+		return &CourseError{Course: c, Path: courseFile, Kind: kind, Err: err}
 	}
 
-	defer f.Close()
-
-	schedulesForCourse, err := parser.FindSchedule(f)
+	f, err := os.Open(courseFile)
 	if err != nil {
-		fmt.Printf("failed to find schedules for %s", courseFile)
-		scheduleErr <- c
-		return
+		return nil, nil, courseErr(ErrCourseFileMissing, err)
 	}
 
-	generateSQLForParsed(c, schedulesForCourse, sqlIn)
-}
+	defer f.Close()
 
-func getCoursesToParse(pathToMapping string) ([]downloader.CourseMapping, error) {
-	f, err := os.Open(pathToMapping)
+	schedulesForCourse, skippedRows, err := parser.FindScheduleWithOptions(f,
+		parser.ScheduleOptions{Lenient: lenient, Course: c.Text})
 	if err != nil {
-		return nil, err
+		var scheduleErr *parser.ScheduleError
+		if errors.As(err, &scheduleErr) {
+			return nil, nil, courseErr(ErrScheduleTable, err)
+		}
+		return nil, nil, courseErr(ErrInvalidHTML, err)
 	}
 
-	var mappings []downloader.CourseMapping
-	err = json.NewDecoder(f).Decode(&mappings)
-	if err != nil {
-		return nil, err
+	var skipped []*CourseError
+	for _, row := range skippedRows {
+		skipped = append(skipped, courseErr(ErrScheduleTable, row))
 	}
-	return mappings, nil
+	return schedulesForCourse, skipped, nil
 }
 
-func generateSQLForParsed(result downloader.CourseMapping,
-	subjects []parser.Subject,
-	sqlIn chan []byte) {
+func generateSQLForParsed(semester string, result downloader.CourseMapping,
+	subjects []parser.Subject) []byte {
 
-	log.Printf("generating sql for %s", result.Text)
+	logging.Debugf("generating sql for %s", result.Text)
 
-	generatedSQL := schedule.GenerateSQL(&result.Course, subjects)
-	sqlIn <- generatedSQL
+	return schedule.GenerateSQL(semester, &result.Course, subjects)
 }
 
 func sigInt() {
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT)
 	<-ch
 	log.Fatal("CTRL-C; exiting")
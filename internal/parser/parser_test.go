@@ -0,0 +1,143 @@
+package parser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/parser"
+	pkgparser "github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var fixtures = []string{
+	"../../testdata/acc-y1-single.html",
+	"../../testdata/acc-y1-single-lesson.html",
+	"../../testdata/schedule-with-subject.html",
+}
+
+// Builds a snapshot folder of n courses cycling through the fixtures
+func buildSnapshot(t *testing.T, n int) (string, []downloader.CourseMapping) {
+	dir := t.TempDir()
+	mappings := make([]downloader.CourseMapping, n)
+	for i := range mappings {
+		course := pkgparser.Course{
+			Key:  fmt.Sprintf("C%02d;;1;F", i),
+			Text: fmt.Sprintf("Course %02d", i),
+		}
+		mappings[i] = downloader.CourseMapping{Course: course, Index: course.Id()}
+
+		body, err := ioutil.ReadFile(fixtures[i%len(fixtures)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(dir, strconv.FormatUint(course.Id(), 10)+".html")
+		if err := ioutil.WriteFile(name, body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, err := json.Marshal(mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "mapping.json"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, mappings
+}
+
+func parseSnapshot(t *testing.T, dir string) []byte {
+	out := filepath.Join(t.TempDir(), "out.sql")
+	_, err := parser.Parse(parser.Options{
+		Snapshot:      dir,
+		Output:        out,
+		InitSQL:       "../../sql/init.sql",
+		CourseOptions: parser.CourseOptions{Concurrency: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestParseIsDeterministic(t *testing.T) {
+	dir, mappings := buildSnapshot(t, 24)
+
+	first := parseSnapshot(t, dir)
+	for i := 0; i < 5; i++ {
+		if !bytes.Equal(first, parseSnapshot(t, dir)) {
+			t.Fatalf("run=%d output differs from the first run", i)
+		}
+	}
+
+	courseComment := regexp.MustCompile(`-- Schedules for course: (.*)\n`)
+	found := courseComment.FindAllSubmatch(first, -1)
+	if len(found) != len(mappings) {
+		t.Fatalf("expected_courses=%d got=%d", len(mappings), len(found))
+	}
+	for i, m := range found {
+		if string(m[1]) != mappings[i].Text {
+			t.Errorf("position=%d expected=%q got=%q", i, mappings[i].Text, m[1])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	dir, mappings := buildSnapshot(t, 6)
+
+	courseFile := func(m downloader.CourseMapping) string {
+		return filepath.Join(dir, strconv.FormatUint(m.Id(), 10)+".html")
+	}
+	if err := os.Remove(courseFile(mappings[1])); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile("../../testdata/schedule-with-subject.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.Replace(body, []byte("1830-2130"), []byte("18x0-2130"), 1)
+	if err := ioutil.WriteFile(courseFile(mappings[4]), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := parser.Parse(parser.Options{
+		Snapshot:      dir,
+		Output:        filepath.Join(t.TempDir(), "out.sql"),
+		InitSQL:       "../../sql/init.sql",
+		FailOnError:   true,
+		CourseOptions: parser.CourseOptions{Concurrency: 3},
+	})
+	if !errors.Is(err, parser.ErrCoursesFailed) {
+		t.Errorf("expected=%s got=%v", parser.ErrCoursesFailed, err)
+	}
+	if summary.Parsed != 4 || summary.Failed() != 2 {
+		t.Fatalf("expected parsed=4 failed=2 got parsed=%d failed=%d", summary.Parsed, summary.Failed())
+	}
+
+	missing := summary.Errors[0]
+	if missing.Course.Key != mappings[1].Key || !errors.Is(missing, parser.ErrCourseFileMissing) {
+		t.Errorf("expected missing file for %s got=%v", mappings[1].Key, missing)
+	}
+
+	table := summary.Errors[1]
+	var scheduleErr *pkgparser.ScheduleError
+	if table.Course.Key != mappings[4].Key || !errors.Is(table, parser.ErrScheduleTable) ||
+		!errors.As(table, &scheduleErr) {
+		t.Fatalf("expected schedule table error for %s got=%v", mappings[4].Key, table)
+	}
+	if scheduleErr.Course != mappings[4].Text || scheduleErr.Table != 1 || scheduleErr.Row != 0 {
+		t.Errorf("expected course=%s table=1 row=0 got course=%s table=%d row=%d", mappings[4].Text,
+			scheduleErr.Course, scheduleErr.Table, scheduleErr.Row)
+	}
+}
@@ -0,0 +1,91 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+)
+
+var (
+	ErrUnknownIndex = errors.New("planner: index not found")
+	ErrSameSubject  = errors.New("planner: more than one index of a subject")
+)
+
+// A session of an index
+type Session struct {
+	SubjectId string
+	Index     string
+	parser.Schedule
+}
+
+// Two sessions held at the same time, Start to End is the part of the
+// day and Weeks the teaching weeks they share
+type Overlap struct {
+	A, B  Session
+	Day   parser.Weekday
+	Start occupancy.Clock
+	End   occupancy.Clock
+	Weeks parser.Weeks
+}
+
+// Looks up index numbers among subjects, index numbers are unique within
+// a semester
+func FindIndexes(subjects map[string]parser.Subject, numbers ...string) ([]Index, error) {
+	all := make(map[string]Index)
+	for _, subject := range subjects {
+		for _, index := range Indexes(subject) {
+			all[index.Index] = index
+		}
+	}
+
+	indexes := make([]Index, 0, len(numbers))
+	for _, number := range numbers {
+		index, ok := all[number]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIndex, number)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// Reports every pair of sessions from different indexes that are held at
+// the same time, ordered by day and time. Indexes of a subject share
+// their lectures and only one of them is taken, so giving two is an error.
+func Clashes(indexes ...Index) ([]Overlap, error) {
+	seen := make(map[string]string)
+	for _, index := range indexes {
+		if other, ok := seen[index.SubjectId]; ok {
+			return nil, fmt.Errorf("%w: %s has %s and %s", ErrSameSubject, index.SubjectId, other, index.Index)
+		}
+		seen[index.SubjectId] = index.Index
+	}
+
+	var overlaps []Overlap
+	for i := range indexes {
+		for j := i + 1; j < len(indexes); j++ {
+			for _, a := range indexes[i].Sessions {
+				for _, b := range indexes[j].Sessions {
+					o, ok := overlap(a, b)
+					if !ok {
+						continue
+					}
+					o.A = Session{SubjectId: indexes[i].SubjectId, Index: indexes[i].Index, Schedule: a}
+					o.B = Session{SubjectId: indexes[j].SubjectId, Index: indexes[j].Index, Schedule: b}
+					overlaps = append(overlaps, o)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(overlaps, func(i, j int) bool {
+		a, b := overlaps[i], overlaps[j]
+		if a.Day != b.Day {
+			return a.Day.Before(b.Day)
+		}
+		return a.Start < b.Start
+	})
+	return overlaps, nil
+}
@@ -0,0 +1,105 @@
+package planner_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func TestClashes(t *testing.T) {
+	lab := session("10101", parser.Monday, 830, 1030, "Teaching Wk2-13")
+	lab.Venue = "HWLAB1"
+	tutorial := session("20202", parser.Monday, 930, 1130, "Teaching Wk1,3,5")
+	tutorial.Venue = "TR+15"
+	subjects := map[string]parser.Subject{
+		"CZ2001": {Id: "CZ2001", Schedules: []parser.Schedule{
+			lab,
+			session("10101", parser.Tuesday, 830, 930, ""),
+		}},
+		"CZ2002": {Id: "CZ2002", Schedules: []parser.Schedule{
+			tutorial,
+			session("20202", parser.Tuesday, 930, 1030, ""),
+		}},
+		"CZ2003": {Id: "CZ2003", Schedules: []parser.Schedule{
+			session("30303", parser.Tuesday, 900, 1000, ""),
+		}},
+	}
+
+	indexes, err := planner.FindIndexes(subjects, "10101", "20202", "30303")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clashes, err := planner.Clashes(indexes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		a, b     string
+		day      parser.Weekday
+		from, to string
+		weeks    string
+		venueA   string
+		venueB   string
+	}{
+		{"10101", "20202", parser.Monday, "0930", "1030", "3,5", "HWLAB1", "TR+15"},
+		{"10101", "30303", parser.Tuesday, "0900", "0930", "1-13", "", ""},
+		{"20202", "30303", parser.Tuesday, "0930", "1000", "1-13", "", ""},
+	}
+	if len(clashes) != len(expected) {
+		t.Fatalf("expected=%d clashes got=%+v", len(expected), clashes)
+	}
+	for i, test := range expected {
+		c := clashes[i]
+		if c.A.Index != test.a || c.B.Index != test.b || c.Day != test.day ||
+			c.Start.String() != test.from || c.End.String() != test.to || c.Weeks.String() != test.weeks ||
+			c.A.Venue != test.venueA || c.B.Venue != test.venueB {
+			t.Errorf("id=%d expected=%+v got=%+v", i, test, c)
+		}
+	}
+
+	if _, err := planner.FindIndexes(subjects, "99999"); !errors.Is(err, planner.ErrUnknownIndex) {
+		t.Errorf("expected=%v got=%v", planner.ErrUnknownIndex, err)
+	}
+	if clashes, err := planner.Clashes(indexes[0]); err != nil || len(clashes) != 0 {
+		t.Errorf("expected no clashes within an index got=%+v %v", clashes, err)
+	}
+}
+
+func TestClashesRejectsSameSubject(t *testing.T) {
+	// Both indexes share the lecture, which is not a clash
+	lecture := session("10101", parser.Monday, 830, 930, "")
+	lecture.Type = parser.Lecture
+	other := lecture
+	other.Index = "10102"
+	subjects := map[string]parser.Subject{
+		"CZ2001": {Id: "CZ2001", Schedules: []parser.Schedule{
+			lecture, session("10101", parser.Tuesday, 830, 930, ""),
+			other, session("10102", parser.Wednesday, 830, 930, ""),
+		}},
+		"CZ2002": {Id: "CZ2002", Schedules: []parser.Schedule{
+			session("20202", parser.Thursday, 830, 930, ""),
+		}},
+	}
+
+	cases := []struct {
+		numbers []string
+		err     error
+	}{
+		{[]string{"10101", "20202"}, nil},
+		{[]string{"10101", "10102"}, planner.ErrSameSubject},
+		{[]string{"10102", "20202", "10101"}, planner.ErrSameSubject},
+		{[]string{"10101", "10101"}, planner.ErrSameSubject},
+	}
+	for id, c := range cases {
+		indexes, err := planner.FindIndexes(subjects, c.numbers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clashes, err := planner.Clashes(indexes...)
+		if !errors.Is(err, c.err) || len(clashes) != 0 {
+			t.Errorf("id=%d expected=%v got=%v %+v", id, c.err, err, clashes)
+		}
+	}
+}
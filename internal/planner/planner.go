@@ -0,0 +1,336 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNoSubjects          = errors.New("planner: no subjects given")
+	ErrNoIndexes           = errors.New("planner: subject has no indexes")
+	ErrTooManyCombinations = errors.New("planner: too many combinations")
+)
+
+// Partial timetables Search tries when Options.MaxWork is 0
+const DefaultMaxWork = 200000
+
+// The sessions of one index of a subject
+type Index struct {
+	SubjectId string
+	Index     string
+	Sessions  []parser.Schedule
+}
+
+// Groups the sessions of a subject by index, in page order
+func Indexes(s parser.Subject) []Index {
+	var indexes []Index
+	positions := make(map[string]int)
+	for _, schedule := range s.Schedules {
+		i, ok := positions[schedule.Index]
+		if !ok {
+			i = len(indexes)
+			positions[schedule.Index] = i
+			indexes = append(indexes, Index{SubjectId: s.Id, Index: schedule.Index})
+		}
+		indexes[i].Sessions = append(indexes[i].Sessions, schedule)
+	}
+	return indexes
+}
+
+func clockOf(s parser.Schedule) (occupancy.Clock, occupancy.Clock) {
+	return occupancy.SessionClocks(s)
+}
+
+// Whether two sessions are held at the same time in some teaching week.
+// Sessions without a known day or time never clash.
+func Clash(a, b parser.Schedule) bool {
+	_, ok := overlap(a, b)
+	return ok
+}
+
+// Returns the time and weeks two sessions share
+func overlap(a, b parser.Schedule) (Overlap, bool) {
+	if !a.Day.Valid() || a.Day != b.Day {
+		return Overlap{}, false
+	}
+	aStart, aEnd := clockOf(a)
+	bStart, bEnd := clockOf(b)
+	if aStart >= aEnd || bStart >= bEnd || aStart >= bEnd || bStart >= aEnd {
+		return Overlap{}, false
+	}
+	weeks := a.Weeks() & b.Weeks()
+	if weeks == 0 {
+		return Overlap{}, false
+	}
+
+	o := Overlap{Day: a.Day, Start: aStart, End: aEnd, Weeks: weeks}
+	if bStart > o.Start {
+		o.Start = bStart
+	}
+	if bEnd < o.End {
+		o.End = bEnd
+	}
+	return o, true
+}
+
+// What makes a timetable better, every plan gets a cost and the
+// cheapest come first
+type Preferences struct {
+	// Sessions starting before this count as early, e.g. 0930 to avoid
+	// 8:30 classes. 0 counts none.
+	NotBefore occupancy.Clock
+	// Cost of each early session
+	Early float64
+	// Cost of each hour between sessions on the same day
+	Gap float64
+	// Taken off the cost for each weekday without sessions
+	FreeDay float64
+}
+
+func DefaultPreferences() Preferences {
+	return Preferences{NotBefore: 9*60 + 30, Early: 1, Gap: 0.5, FreeDay: 2}
+}
+
+// A clash-free choice of one index per subject, in the order the
+// subjects were given
+type Plan struct {
+	Indexes       []Index
+	Cost          float64
+	EarlySessions int
+	GapMinutes    int
+	FreeDays      []parser.Weekday
+}
+
+type Options struct {
+	Preferences
+	// Number of plans returned, 0 returns every plan
+	Limit int
+	// Partial timetables tried before Search gives up with
+	// ErrTooManyCombinations, 0 uses DefaultMaxWork
+	MaxWork int
+}
+
+type Result struct {
+	Plans []Plan
+	// Number of clash-free combinations, including those beyond Limit
+	Combinations int
+}
+
+// Finds every clash-free combination of one index per subject and
+// returns the cheapest by opts. Subjects with many indexes that rarely
+// clash have more combinations than can be ranked, Search then stops
+// with ErrTooManyCombinations once opts.MaxWork is used up.
+func Search(subjects []parser.Subject, opts Options) (*Result, error) {
+	if len(subjects) == 0 {
+		return nil, ErrNoSubjects
+	}
+
+	candidates := make([][]Index, len(subjects))
+	for i, subject := range subjects {
+		candidates[i] = Indexes(subject)
+		if len(candidates[i]) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoIndexes, subject.Id)
+		}
+	}
+	// Subjects with few indexes first so clashes prune early
+	order := make([]int, len(subjects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(candidates[order[i]]) < len(candidates[order[j]])
+	})
+
+	maxWork := opts.MaxWork
+	if maxWork <= 0 {
+		maxWork = DefaultMaxWork
+	}
+	clashes := newClashTable(candidates)
+	result := &Result{}
+	chosen := make([]int, len(subjects))
+	work := 0
+	var walk func(depth int) bool
+	walk = func(depth int) bool {
+		if depth == len(order) {
+			plan := make([]Index, len(subjects))
+			for subject, i := range chosen {
+				plan[subject] = candidates[subject][i]
+			}
+			result.Combinations++
+			result.add(newPlan(plan, opts.Preferences), opts.Limit)
+			return true
+		}
+		subject := order[depth]
+		for i := range candidates[subject] {
+			if clashes.withChosen(subject, i, chosen, order[:depth]) {
+				continue
+			}
+			if work++; work > maxWork {
+				return false
+			}
+			chosen[subject] = i
+			// Prefixes leaving a later subject without a fitting index
+			// are dropped here rather than at that subject
+			if !clashes.leavesRoom(chosen, order[:depth+1], order[depth+1:]) {
+				continue
+			}
+			if !walk(depth + 1) {
+				return false
+			}
+		}
+		return true
+	}
+	if !walk(0) {
+		return nil, fmt.Errorf("%w: more than %d partial timetables to try, give fewer subjects", ErrTooManyCombinations, maxWork)
+	}
+	return result, nil
+}
+
+// Whether two indexes of different subjects clash, by subject and
+// position in the candidates of Search
+type clashTable struct {
+	// Row of the first index of each subject and its number of indexes
+	offsets, sizes []int
+	clash          [][]bool
+}
+
+func newClashTable(candidates [][]Index) *clashTable {
+	t := &clashTable{offsets: make([]int, len(candidates)), sizes: make([]int, len(candidates))}
+	var all []Index
+	for subject, indexes := range candidates {
+		t.offsets[subject], t.sizes[subject] = len(all), len(indexes)
+		all = append(all, indexes...)
+	}
+	t.clash = make([][]bool, len(all))
+	for i := range all {
+		t.clash[i] = make([]bool, len(all))
+	}
+	for i := range all {
+		for j := i + 1; j < len(all); j++ {
+			if indexesClash(all[i], all[j]) {
+				t.clash[i][j], t.clash[j][i] = true, true
+			}
+		}
+	}
+	return t
+}
+
+func indexesClash(a, b Index) bool {
+	for _, x := range a.Sessions {
+		for _, y := range b.Sessions {
+			if Clash(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether index i of subject clashes with the indexes chosen for
+// subjects
+func (t *clashTable) withChosen(subject, i int, chosen []int, subjects []int) bool {
+	row := t.clash[t.offsets[subject]+i]
+	for _, s := range subjects {
+		if row[t.offsets[s]+chosen[s]] {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether every subject of rest still has an index fitting the indexes
+// chosen for subjects
+func (t *clashTable) leavesRoom(chosen []int, subjects, rest []int) bool {
+	for _, subject := range rest {
+		fits := false
+		for i := 0; i < t.sizes[subject] && !fits; i++ {
+			fits = !t.withChosen(subject, i, chosen, subjects)
+		}
+		if !fits {
+			return false
+		}
+	}
+	return true
+}
+
+// Keeps the plans sorted by cost and at most limit of them
+func (r *Result) add(plan Plan, limit int) {
+	i := sort.Search(len(r.Plans), func(i int) bool {
+		return plan.before(r.Plans[i])
+	})
+	if limit > 0 && i >= limit {
+		return
+	}
+	r.Plans = append(r.Plans, Plan{})
+	copy(r.Plans[i+1:], r.Plans[i:])
+	r.Plans[i] = plan
+	if limit > 0 && len(r.Plans) > limit {
+		r.Plans = r.Plans[:limit]
+	}
+}
+
+// Cheaper plans first, ties are broken by index numbers so results do
+// not depend on the search order
+func (p Plan) before(o Plan) bool {
+	if p.Cost != o.Cost {
+		return p.Cost < o.Cost
+	}
+	return p.key() < o.key()
+}
+
+func (p Plan) key() string {
+	indexes := make([]string, len(p.Indexes))
+	for i, index := range p.Indexes {
+		indexes[i] = index.Index
+	}
+	return strings.Join(indexes, ",")
+}
+
+type interval struct {
+	start, end occupancy.Clock
+}
+
+func newPlan(chosen []Index, prefs Preferences) Plan {
+	plan := Plan{Indexes: append([]Index(nil), chosen...)}
+
+	days := make(map[parser.Weekday][]interval)
+	for _, index := range chosen {
+		for _, session := range index.Sessions {
+			start, end := clockOf(session)
+			if !session.Day.Valid() || start >= end {
+				continue
+			}
+			if prefs.NotBefore > 0 && start < prefs.NotBefore {
+				plan.EarlySessions++
+			}
+			days[session.Day] = append(days[session.Day], interval{start, end})
+		}
+	}
+
+	for _, intervals := range days {
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+		end := intervals[0].end
+		for _, next := range intervals[1:] {
+			if next.start > end {
+				plan.GapMinutes += int(next.start - end)
+			}
+			if next.end > end {
+				end = next.end
+			}
+		}
+	}
+	for _, day := range parser.Weekdays[:5] {
+		if len(days[day]) == 0 {
+			plan.FreeDays = append(plan.FreeDays, day)
+		}
+	}
+
+	plan.Cost = prefs.Early*float64(plan.EarlySessions) +
+		prefs.Gap*float64(plan.GapMinutes)/60 -
+		prefs.FreeDay*float64(len(plan.FreeDays))
+	return plan
+}
@@ -0,0 +1,164 @@
+package planner_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func session(index string, day parser.Weekday, from, to int, remark string) parser.Schedule {
+	at := func(hhmm int) time.Time {
+		return time.Date(0, 1, 1, hhmm/100, hhmm%100, 0, 0, time.UTC)
+	}
+	return parser.Schedule{Index: index, Type: parser.Tutorial, Day: day,
+		TimeStart: at(from), TimeEnd: at(to), Remark: remark}
+}
+
+func TestClash(t *testing.T) {
+	cases := []struct {
+		a, b     parser.Schedule
+		expected bool
+	}{
+		{session("1", parser.Monday, 830, 930, ""), session("2", parser.Monday, 900, 1000, ""), true},
+		{session("1", parser.Monday, 830, 930, ""), session("2", parser.Monday, 930, 1030, ""), false},
+		{session("1", parser.Monday, 830, 930, ""), session("2", parser.Tuesday, 830, 930, ""), false},
+		{session("1", parser.Monday, 830, 930, "Teaching Wk1,3,5"),
+			session("2", parser.Monday, 830, 930, "Teaching Wk2,4,6"), false},
+		{session("1", parser.Monday, 830, 930, "Teaching Wk2-13"),
+			session("2", parser.Monday, 830, 930, "Teaching Wk11"), true},
+		{session("1", "TBA", 830, 930, ""), session("2", "TBA", 830, 930, ""), false},
+	}
+
+	for i, test := range cases {
+		if result := planner.Clash(test.a, test.b); result != test.expected {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expected, result)
+		}
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	subject := parser.Subject{Id: "CZ2001", Schedules: []parser.Schedule{
+		session("10101", parser.Monday, 830, 930, ""),
+		session("10102", parser.Monday, 930, 1030, ""),
+		session("10101", parser.Tuesday, 830, 930, ""),
+	}}
+
+	indexes := planner.Indexes(subject)
+	if len(indexes) != 2 || indexes[0].Index != "10101" || indexes[1].Index != "10102" {
+		t.Fatalf("unexpected indexes %+v", indexes)
+	}
+	if len(indexes[0].Sessions) != 2 || indexes[0].SubjectId != "CZ2001" {
+		t.Errorf("unexpected sessions %+v", indexes[0])
+	}
+}
+
+func TestSearch(t *testing.T) {
+	subjects := []parser.Subject{
+		{Id: "CZ2001", Schedules: []parser.Schedule{
+			session("A1", parser.Monday, 830, 930, ""),
+			session("A2", parser.Monday, 1030, 1130, ""),
+			session("A3", parser.Tuesday, 1030, 1130, ""),
+		}},
+		{Id: "CZ2002", Schedules: []parser.Schedule{
+			session("B1", parser.Monday, 830, 930, ""),
+			session("B2", parser.Monday, 1130, 1230, "Teaching Wk1,3,5"),
+			session("B2", parser.Monday, 1030, 1130, "Teaching Wk2,4,6"),
+		}},
+	}
+
+	result, err := planner.Search(subjects, planner.Options{Preferences: planner.DefaultPreferences()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A1+B1 clash, A2+B2 clash in the even weeks
+	if result.Combinations != 4 {
+		t.Errorf("expected=%d got=%d", 4, result.Combinations)
+	}
+
+	var got [][]string
+	for _, plan := range result.Plans {
+		got = append(got, []string{plan.Indexes[0].Index, plan.Indexes[1].Index})
+	}
+	expected := [][]string{
+		// Both on Monday with an early class and an hour gap, tied
+		{"A1", "B2"},
+		{"A2", "B1"},
+		// No early class but one free day less
+		{"A3", "B2"},
+		{"A3", "B1"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected=%v got=%v", expected, got)
+	}
+
+	best := result.Plans[0]
+	if best.EarlySessions != 1 || best.GapMinutes != 60 || len(best.FreeDays) != 4 {
+		t.Errorf("unexpected plan %+v", best)
+	}
+
+	result, err = planner.Search(subjects, planner.Options{Preferences: planner.DefaultPreferences(), Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Plans) != 1 || result.Combinations != 4 {
+		t.Errorf("expected one of 4 plans, got %d of %d", len(result.Plans), result.Combinations)
+	}
+}
+
+func TestSearchMaxWork(t *testing.T) {
+	// Ten indexes of each subject on a day of its own, no two subjects
+	// clash
+	var subjects []parser.Subject
+	for day, id := range []string{"CZ2001", "CZ2002", "CZ2003", "CZ2004"} {
+		subject := parser.Subject{Id: id}
+		for i := 0; i < 10; i++ {
+			subject.Schedules = append(subject.Schedules,
+				session(id[4:]+string(rune('0'+i)), parser.Weekdays[day], 800+100*i, 900+100*i, ""))
+		}
+		subjects = append(subjects, subject)
+	}
+
+	_, err := planner.Search(subjects, planner.Options{MaxWork: 1000})
+	if !errors.Is(err, planner.ErrTooManyCombinations) {
+		t.Errorf("expected=%v got=%v", planner.ErrTooManyCombinations, err)
+	}
+	result, err := planner.Search(subjects, planner.Options{Limit: 1})
+	if err != nil || result.Combinations != 10000 {
+		t.Errorf("expected 10000 combinations got=%v %v", result, err)
+	}
+
+	// Every index of CZ2003 clashes with the only index of CZ2001, which
+	// is found before trying the ten indexes of CZ2002
+	clashing := []parser.Subject{
+		{Id: "CZ2001", Schedules: []parser.Schedule{session("A1", parser.Friday, 830, 930, "")}},
+		subjects[1],
+		{Id: "CZ2003"},
+	}
+	for i := 0; i < 11; i++ {
+		clashing[2].Schedules = append(clashing[2].Schedules,
+			session("C"+string(rune('a'+i)), parser.Friday, 900, 1000, ""))
+	}
+	result, err = planner.Search(clashing, planner.Options{MaxWork: 2})
+	if err != nil || result.Combinations != 0 {
+		t.Errorf("expected no combinations got=%v %v", result, err)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	cases := []struct {
+		subjects    []parser.Subject
+		expectedErr error
+	}{
+		{nil, planner.ErrNoSubjects},
+		{[]parser.Subject{{Id: "CZ2001"}}, planner.ErrNoIndexes},
+	}
+
+	for i, test := range cases {
+		if _, err := planner.Search(test.subjects, planner.Options{}); !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package rooms
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidRegistry = errors.New("rooms: invalid registry")
+)
+
+// What the timetable does not say about a venue
+type Room struct {
+	Venue    string
+	Capacity int
+	// Building the venue is in, parser.Building of the venue when empty
+	Building string
+}
+
+// Rooms listed in the registry file, see docs/rooms.csv
+type Registry struct {
+	rooms map[string]Room
+}
+
+// Reads a CSV file with the columns venue, capacity and optionally
+// building, the first row is a header
+func Load(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func Read(r io.Reader) (*Registry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRegistry, err)
+	}
+
+	registry := &Registry{rooms: make(map[string]Room)}
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) < 2 || len(row) > 3 {
+			return nil, fmt.Errorf("%w: line %d has %d columns", ErrInvalidRegistry, i+1, len(row))
+		}
+		room := Room{Venue: strings.TrimSpace(row[0])}
+		if room.Venue == "" {
+			return nil, fmt.Errorf("%w: line %d has no venue", ErrInvalidRegistry, i+1)
+		}
+		if room.Capacity, err = strconv.Atoi(strings.TrimSpace(row[1])); err != nil || room.Capacity < 0 {
+			return nil, fmt.Errorf("%w: line %d has capacity %q", ErrInvalidRegistry, i+1, row[1])
+		}
+		if len(row) == 3 {
+			room.Building = strings.ToUpper(strings.TrimSpace(row[2]))
+		}
+		if _, ok := registry.rooms[strings.ToUpper(room.Venue)]; ok {
+			return nil, fmt.Errorf("%w: line %d lists %s again", ErrInvalidRegistry, i+1, room.Venue)
+		}
+		registry.rooms[strings.ToUpper(room.Venue)] = room
+	}
+	return registry, nil
+}
+
+// Returns what is known about venue, a nil registry knows nothing
+func (r *Registry) Room(venue string) (Room, bool) {
+	if r == nil {
+		return Room{Venue: venue}, false
+	}
+	room, ok := r.rooms[strings.ToUpper(venue)]
+	if !ok {
+		room.Venue = venue
+	}
+	return room, ok
+}
+
+// Returns the building of venue as listed or going by parser.Building
+func (r *Registry) Building(venue string) string {
+	if room, _ := r.Room(venue); room.Building != "" {
+		return room.Building
+	}
+	return parser.Building(venue)
+}
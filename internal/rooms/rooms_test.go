@@ -0,0 +1,57 @@
+package rooms_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	registry, err := rooms.Load("../../docs/rooms.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		venue    string
+		capacity int
+		building string
+		ok       bool
+	}{
+		{"LT2A", 250, "LT2A", true},
+		{"tr+15", 40, "NS", true},
+		{"NS4-05-37", 60, "NS4", true},
+		{"NS4-05-38", 0, "NS4", false},
+	}
+
+	for i, test := range cases {
+		room, ok := registry.Room(test.venue)
+		building := registry.Building(test.venue)
+		if ok != test.ok || room.Capacity != test.capacity || building != test.building {
+			t.Errorf("id=%d expected=%d,%s,%v got=%d,%s,%v", i, test.capacity, test.building, test.ok,
+				room.Capacity, building, ok)
+		}
+	}
+
+	var none *rooms.Registry
+	if _, ok := none.Room("LT2A"); ok || none.Building("NS4-05-37") != "NS4" {
+		t.Errorf("expected a nil registry to know nothing")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []string{
+		"venue,capacity\nLT2A,many\n",
+		"venue,capacity\nLT2A,-1\n",
+		"venue,capacity\n,10\n",
+		"venue,capacity\nLT2A\n",
+		"venue,capacity\nLT2A,10\nlt2a,20\n",
+		"venue,capacity\n\"LT2A,10\n",
+	}
+
+	for i, body := range cases {
+		if _, err := rooms.Read(strings.NewReader(body)); !errors.Is(err, rooms.ErrInvalidRegistry) {
+			t.Errorf("id=%d expected=%v got=%v", i, rooms.ErrInvalidRegistry, err)
+		}
+	}
+}
@@ -4,38 +4,119 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// Generates SQL for a list of schedules
-func GenerateSQL(course *parser.Course, subjects []parser.Subject) []byte {
-	subjectTemplate := `INSERT INTO subject(id, schedule_index, title, rawAU)
-        VALUES("%s", "%s", "%s", "%s");` + "\n"
+// Generates SQL for a list of schedules, the stable IDs of subjects and
+// sessions are taken from the subjects so they should be set beforehand
+func GenerateSQL(semester string, course *parser.Course, subjects []parser.Subject) []byte {
+	courseTemplate := `INSERT INTO course(uid, course_key, title, kind, programme, specialisation, year, part_time, general_elective)
+        VALUES(%s, %s, %s, %s, %s, %s, %d, %d, %d);` + "\n"
 
-	scheduleTemplate := `INSERT INTO schedule(schedule_index, schedule_type, schedule_group, day, timeText, timeStart, timeEnd, venue, remark)
-        VALUES("%s", "%s", "%s", "%s", "%s", "%s", "%s", "%s", "%s");` + "\n"
+	subjectTemplate := `INSERT INTO subject(uid, id, course_uid, course_key, index_uid, schedule_index, title, rawAU, au_min, au_max, prerequisite, mutually_exclusive, grade_type)
+        VALUES(%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s);` + "\n"
+
+	exclusionTemplate := `INSERT INTO subject_exclusion(subject_uid, subject_id, exclusion_as, programme, admission)
+        VALUES(%s, %s, %s, %s, %s);` + "\n"
+
+	scheduleTemplate := `INSERT INTO schedule(uid, index_uid, subject_uid, schedule_index, schedule_type, schedule_group, day, day_number, timeText, timeStart, timeEnd, venue, remark)
+        VALUES(%s, %s, %s, %s, %s, %s, %s, %d, %s, %s, %s, %s, %s);` + "\n"
 
 	var sqlBuilder bytes.Buffer
 	fmt.Fprintf(&sqlBuilder, "\n-- Schedules for course: %s\n", course.Text)
 	fmt.Fprintf(&sqlBuilder, "BEGIN TRANSACTION;\n")
+	// Keys that cannot be decoded are written with empty fields
+	info, _ := course.Info()
+	courseUID := parser.CourseID(semester, course.Key)
+	fmt.Fprintf(&sqlBuilder, courseTemplate, sqlString(courseUID), sqlString(course.Key),
+		sqlString(course.Text), sqlString(string(info.Kind)),
+		sqlString(info.Programme), sqlString(info.Specialisation), info.Year,
+		sqlBool(info.PartTime), sqlBool(info.GeneralElective))
 	for _, subject := range subjects {
 		fmt.Fprintf(&sqlBuilder, "\n-- Schedules for subject: %s\n\n", subject.Title)
+		for _, exclusion := range subject.Exclusions {
+			programmes := exclusion.Programmes
+			if len(programmes) == 0 {
+				programmes = []string{""}
+			}
+			for _, programme := range programmes {
+				fmt.Fprintf(&sqlBuilder, exclusionTemplate,
+					sqlString(subject.UID), sqlString(subject.Id), sqlString(exclusion.As),
+					sqlString(programme), sqlString(exclusion.Admission))
+			}
+		}
 		for _, schedule := range subject.Schedules {
 			fmt.Fprintf(&sqlBuilder, subjectTemplate,
-				subject.Id,
-				schedule.Index,
-				subject.Title,
-				subject.AuRaw)
+				sqlString(subject.UID),
+				sqlString(subject.Id),
+				sqlString(courseUID),
+				sqlString(course.Key),
+				sqlString(schedule.IndexUID),
+				sqlString(schedule.Index),
+				sqlString(subject.Title),
+				sqlString(subject.AuRaw),
+				sqlAU(subject.AU, subject.AU.Min),
+				sqlAU(subject.AU, subject.AU.Max),
+				sqlString(subject.Prerequisite),
+				sqlString(strings.Join(subject.MutuallyExclusive, ",")),
+				sqlString(subject.GradeType))
 
 			fmt.Fprintf(&sqlBuilder, scheduleTemplate,
-				schedule.Index, schedule.Type, schedule.Group,
-				schedule.Day,
-				schedule.TimeText,
-				schedule.TimeStart.Format(time.RFC3339),
-				schedule.TimeEnd.Format(time.RFC3339),
-				schedule.Venue, schedule.Remark)
+				sqlString(schedule.UID), sqlString(schedule.IndexUID), sqlString(subject.UID),
+				sqlString(schedule.Index), sqlString(string(schedule.Type)), sqlString(schedule.Group),
+				sqlString(string(schedule.Day)),
+				schedule.Day.Number(),
+				sqlString(schedule.TimeText),
+				sqlString(schedule.TimeStart.Format(time.RFC3339)),
+				sqlString(schedule.TimeEnd.Format(time.RFC3339)),
+				sqlString(schedule.Venue), sqlString(schedule.Remark))
 		}
 	}
 	fmt.Fprintf(&sqlBuilder, "COMMIT;\n")
 	return sqlBuilder.Bytes()
 }
+
+// Quotes s as an SQL string literal
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// Writes AUs that could not be read as NULL
+func sqlAU(au parser.AcademicUnits, value float64) string {
+	if !au.Valid {
+		return "NULL"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Generates SQL for the exam timetable
+func GenerateExamSQL(semester string, exams []parser.Exam) []byte {
+	examTemplate := `INSERT INTO exam(subject_uid, subject_id, title, day, day_number, date_text, time_text, timeStart, timeEnd, duration_minutes, venue)
+        VALUES(%s, %s, %s, %s, %d, %s, %s, %s, %s, %d, %s);` + "\n"
+
+	var sqlBuilder bytes.Buffer
+	fmt.Fprintf(&sqlBuilder, "\n-- Exam timetable\n")
+	fmt.Fprintf(&sqlBuilder, "BEGIN TRANSACTION;\n")
+	for _, exam := range exams {
+		fmt.Fprintf(&sqlBuilder, examTemplate,
+			sqlString(parser.SubjectID(semester, exam.SubjectId)), sqlString(exam.SubjectId),
+			sqlString(exam.Title),
+			sqlString(string(exam.Day)), exam.Day.Number(),
+			sqlString(exam.DateText), sqlString(exam.TimeText),
+			sqlString(exam.Start.Format(time.RFC3339)),
+			sqlString(exam.End().Format(time.RFC3339)),
+			int(exam.Duration/time.Minute),
+			sqlString(exam.Venue))
+	}
+	fmt.Fprintf(&sqlBuilder, "COMMIT;\n")
+	return sqlBuilder.Bytes()
+}
@@ -0,0 +1,91 @@
+package schedule_test
+
+import (
+	"database/sql"
+	"github.com/jaxsax/ntu-room-finder/internal/schedule"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"testing"
+)
+
+// Runs the schema and the generated SQL on an in memory database
+func load(t *testing.T, generated ...[]byte) *sql.DB {
+	initSQL, err := ioutil.ReadFile("../../sql/init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	for _, script := range append([][]byte{initSQL}, generated...) {
+		if _, err := db.Exec(string(script)); err != nil {
+			t.Fatalf("%v\n%s", err, script)
+		}
+	}
+	return db
+}
+
+func TestGenerateSQLQuotes(t *testing.T) {
+	course := &parser.Course{Key: "CSC;;2;F", Text: `Computer Science "Year" 2`}
+	subjects := []parser.Subject{{
+		Id:                "CZ2001",
+		Title:             "ALGORITHMS' DESIGN",
+		Prerequisite:      `CZ1007 "OR" CE1007'`,
+		MutuallyExclusive: []string{"CE2101", "CZ'2101"},
+		GradeType:         "Pass/Fail'",
+		Exclusions: []parser.Exclusion{
+			{As: parser.ExclusionAll, Admission: "Admyr '04-'10"},
+			{As: parser.ExclusionPE, Programmes: []string{"E'EE", "CE"}},
+		},
+		Schedules: []parser.Schedule{{
+			Index: "10105", Type: parser.Lecture, Day: parser.Monday,
+			TimeText: "1330-1430", Venue: "LT'2A", Remark: `"Wk2-13"`,
+		}},
+	}}
+	subjects[0].SetIDs("2018;1")
+	exams := []parser.Exam{{SubjectId: "CZ2001", Title: "ALGORITHMS' DESIGN", Venue: `"SPORTS HALL'`}}
+
+	db := load(t, schedule.GenerateSQL("2018;1", course, subjects),
+		schedule.GenerateExamSQL("2018;1", exams))
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT title FROM course", []string{course.Text}},
+		{"SELECT title || prerequisite || mutually_exclusive || grade_type FROM subject",
+			[]string{`ALGORITHMS' DESIGNCZ1007 "OR" CE1007'CE2101,CZ'2101Pass/Fail'`}},
+		{"SELECT venue || remark FROM schedule", []string{`LT'2A"Wk2-13"`}},
+		{"SELECT exclusion_as || ':' || programme || ':' || admission FROM subject_exclusion ORDER BY rowid",
+			[]string{"All::Admyr '04-'10", "PE:E'EE:", "PE:CE:"}},
+		{"SELECT title || venue FROM exam", []string{`ALGORITHMS' DESIGN"SPORTS HALL'`}},
+	}
+	for id, c := range cases {
+		rows, err := db.Query(c.query)
+		if err != nil {
+			t.Fatalf("id=%d %v", id, err)
+		}
+		var got []string
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				t.Fatalf("id=%d %v", id, err)
+			}
+			got = append(got, value)
+		}
+		rows.Close()
+		if len(got) != len(c.expected) {
+			t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.expected[i] {
+				t.Errorf("id=%d expected=%q got=%q", id, c.expected, got)
+			}
+		}
+	}
+}
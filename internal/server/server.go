@@ -0,0 +1,480 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/campus"
+	"github.com/jaxsax/ntu-room-finder/internal/gql"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/internal/swap"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serves room queries over a loaded snapshot
+type Server struct {
+	snapshot  *snapshot.Snapshot
+	occupancy *occupancy.Index
+	subjects  map[string]parser.Subject
+	mux       *http.ServeMux
+	// Ranking used by /api/plan unless the request overrides it
+	Planner planner.Options
+	// Index swap requests, nil when swaps are turned off
+	Swaps *swap.Service
+	// Bearer token the swap endpoints are refused without when set. The
+	// user named in a request is trusted, so the token is for a front
+	// end that signs users in.
+	SwapSecret string
+	// Buildings used to rank free rooms with near=, nil when not configured
+	Campus *campus.Campus
+}
+
+func New(s *snapshot.Snapshot) *Server {
+	srv := &Server{
+		snapshot:  s,
+		occupancy: occupancy.New(s),
+		subjects:  s.Subjects(),
+		mux:       http.NewServeMux(),
+		Planner:   planner.Options{Preferences: planner.DefaultPreferences(), Limit: 10},
+	}
+	srv.mux.HandleFunc("/api/free-rooms", srv.freeRooms)
+	srv.mux.HandleFunc("/api/rooms/", srv.room)
+	srv.mux.HandleFunc("/api/subjects/", srv.subject)
+	srv.mux.HandleFunc("/api/sessions", srv.sessions)
+	srv.mux.HandleFunc("/api/plan", srv.plan)
+	srv.mux.HandleFunc("/api/clashes", srv.clashes)
+	srv.mux.HandleFunc("/api/swaps", srv.swaps)
+	srv.mux.HandleFunc("/api/swaps/", srv.cancelSwap)
+	srv.mux.Handle("/api/graphql", gql.New(s, srv.occupancy))
+	return srv
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debugf("%s %s", r.Method, r.URL)
+	s.mux.ServeHTTP(w, r)
+}
+
+type freeRoomsResponse struct {
+	Day   parser.Weekday  `json:"day"`
+	Date  string          `json:"date,omitempty"`
+	From  occupancy.Clock `json:"from"`
+	To    occupancy.Clock `json:"to"`
+	Rooms []string        `json:"rooms"`
+	// Walking distances from near, in the order of Rooms
+	Nearby []nearbyRoom `json:"nearby,omitempty"`
+}
+
+type nearbyRoom struct {
+	Venue     string  `json:"venue"`
+	Building  string  `json:"building,omitempty"`
+	Minutes   float64 `json:"minutes"`
+	Reachable bool    `json:"reachable"`
+}
+
+// GET /api/free-rooms?day=WED&from=0830&to=1030, or date=2018-11-19
+// instead of day to take the exam timetable into account. near=NS4
+// orders the rooms by walking distance from a building or venue.
+func (s *Server) freeRooms(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var date time.Time
+	var day parser.Weekday
+	var err error
+	if q.Get("date") != "" {
+		date, err = time.Parse("2006-01-02", q.Get("date"))
+		day = parser.WeekdayOf(date.Weekday())
+	} else {
+		day, err = parser.ParseWeekday(q.Get("day"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := occupancy.ParseClock(q.Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := occupancy.ParseClock(q.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to <= from {
+		http.Error(w, fmt.Sprintf("%s is not after %s", to, from), http.StatusBadRequest)
+		return
+	}
+
+	response := freeRoomsResponse{Day: day, From: from, To: to}
+	if date.IsZero() {
+		response.Rooms = s.occupancy.Free(day, from, to)
+	} else {
+		response.Date = date.Format("2006-01-02")
+		response.Rooms = s.occupancy.FreeOn(date, from, to)
+	}
+	if near := q.Get("near"); near != "" {
+		if s.Campus == nil {
+			http.Error(w, "near needs a campus file, set rooms.campus", http.StatusBadRequest)
+			return
+		}
+		distances, err := s.Campus.Rank(near, response.Rooms)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.Rooms = make([]string, 0, len(distances))
+		for _, d := range distances {
+			response.Rooms = append(response.Rooms, d.Venue)
+			response.Nearby = append(response.Nearby, nearbyRoom{
+				Venue: d.Venue, Building: d.Building, Minutes: d.Minutes, Reachable: d.Reachable,
+			})
+		}
+	}
+	writeJSON(w, response)
+}
+
+// Limits free blocks and next free slots to opening hours
+func (s *Server) SetOpeningHours(o occupancy.OpeningHours) {
+	s.occupancy.SetOpeningHours(o)
+}
+
+// GET /api/rooms/<venue>, /api/rooms/<venue>/free-blocks or
+// /api/rooms/<venue>/next-free
+func (s *Server) room(w http.ResponseWriter, r *http.Request) {
+	venue := strings.TrimPrefix(r.URL.Path, "/api/rooms/")
+	if i := strings.LastIndex(venue, "/"); i >= 0 {
+		switch venue[i+1:] {
+		case "free-blocks":
+			s.freeBlocks(w, r, venue[:i])
+			return
+		case "next-free":
+			s.nextFree(w, r, venue[:i])
+			return
+		}
+	}
+	bookings := s.occupancy.Bookings(venue)
+	if len(bookings) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, bookings)
+}
+
+// GET /api/rooms/<venue>/free-blocks?day=WED&min=2h
+func (s *Server) freeBlocks(w http.ResponseWriter, r *http.Request, venue string) {
+	q := r.URL.Query()
+	day, err := parser.ParseWeekday(q.Get("day"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var min time.Duration
+	if v := q.Get("min"); v != "" {
+		if min, err = time.ParseDuration(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.known(venue) {
+		http.NotFound(w, r)
+		return
+	}
+	blocks := s.occupancy.FreeBlocks(venue, day, min)
+	if blocks == nil {
+		blocks = []occupancy.Block{}
+	}
+	writeJSON(w, blocks)
+}
+
+// Whether venue is booked at all, unknown venues would always look free
+func (s *Server) known(venue string) bool {
+	return len(s.occupancy.Bookings(venue)) > 0 || len(s.occupancy.Exams(venue)) > 0
+}
+
+type nextFreeResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// GET /api/rooms/<venue>/next-free?after=2018-11-19T0830, after defaults
+// to now
+func (s *Server) nextFree(w http.ResponseWriter, r *http.Request, venue string) {
+	after := time.Now()
+	if v := r.URL.Query().Get("after"); v != "" {
+		var err error
+		if after, err = time.ParseInLocation("2006-01-02T1504", v, time.Local); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.known(venue) {
+		http.NotFound(w, r)
+		return
+	}
+	slot, ok := s.occupancy.NextFree(venue, after)
+	if !ok {
+		http.Error(w, venue+" is not free in the next two weeks", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, nextFreeResponse{Start: slot.Start, End: slot.End})
+}
+
+// GET /api/subjects/<code>
+func (s *Server) subject(w http.ResponseWriter, r *http.Request) {
+	id := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/api/subjects/"))
+	subject, ok := s.subjects[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, subject)
+}
+
+type sessionResponse struct {
+	CourseUID string            `json:"courseUid"`
+	CourseKey string            `json:"courseKey"`
+	Course    string            `json:"course"`
+	Info      parser.CourseInfo `json:"info"`
+	SubjectId string            `json:"subject"`
+	Schedule  parser.Schedule   `json:"schedule"`
+}
+
+// GET /api/sessions?programme=EEE&year=2&type=TUT
+func (s *Server) sessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := snapshot.SessionFilter{Programme: q.Get("programme")}
+	if year := q.Get("year"); year != "" {
+		var err error
+		if filter.Year, err = strconv.Atoi(year); err != nil {
+			http.Error(w, "invalid year "+year, http.StatusBadRequest)
+			return
+		}
+	}
+	if t := q.Get("type"); t != "" {
+		filter.Type, _ = parser.ParseSessionType(t)
+	}
+
+	sessions := make([]sessionResponse, 0)
+	for _, session := range s.snapshot.Sessions(filter) {
+		sessions = append(sessions, sessionResponse{
+			CourseUID: session.Course.UID,
+			CourseKey: session.Course.Key,
+			Course:    session.Course.Text,
+			Info:      session.Course.Info,
+			SubjectId: session.SubjectId,
+			Schedule:  session.Schedule,
+		})
+	}
+	writeJSON(w, sessions)
+}
+
+type planResponse struct {
+	Combinations int            `json:"combinations"`
+	Plans        []planner.Plan `json:"plans"`
+}
+
+// Most plans /api/plan answers with, whatever the planner limit
+const maxPlans = 100
+
+// GET /api/plan?subjects=CZ2001,CZ2002, optionally with notBefore=0930,
+// early=1, gap=0.5, freeDay=2 and limit=10 overriding the ranking
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := s.Planner
+	var err error
+	if v := q.Get("notBefore"); v != "" {
+		opts.NotBefore, err = occupancy.ParseClock(v)
+	}
+	weights := map[string]*float64{"early": &opts.Early, "gap": &opts.Gap, "freeDay": &opts.FreeDay}
+	for name, weight := range weights {
+		if v := q.Get(name); v != "" && err == nil {
+			*weight, err = strconv.ParseFloat(v, 64)
+		}
+	}
+	if v := q.Get("limit"); v != "" && err == nil {
+		opts.Limit, err = strconv.Atoi(v)
+		if err == nil && (opts.Limit < 1 || opts.Limit > maxPlans) {
+			err = fmt.Errorf("limit must be from 1 to %d, got %d", maxPlans, opts.Limit)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Limit == 0 || opts.Limit > maxPlans {
+		opts.Limit = maxPlans
+	}
+
+	var subjects []parser.Subject
+	for _, code := range strings.Split(q.Get("subjects"), ",") {
+		if code = strings.TrimSpace(code); code == "" {
+			continue
+		}
+		subject, ok := s.subjects[strings.ToUpper(code)]
+		if !ok {
+			http.Error(w, "unknown subject "+code, http.StatusNotFound)
+			return
+		}
+		subjects = append(subjects, subject)
+	}
+
+	result, err := planner.Search(subjects, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, planResponse{Combinations: result.Combinations, Plans: result.Plans})
+}
+
+// GET /api/clashes?indexes=10101,20202
+func (s *Server) clashes(w http.ResponseWriter, r *http.Request) {
+	var numbers []string
+	for _, number := range strings.Split(r.URL.Query().Get("indexes"), ",") {
+		if number = strings.TrimSpace(number); number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	indexes, err := planner.FindIndexes(s.subjects, numbers...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	clashes, err := planner.Clashes(indexes...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if clashes == nil {
+		clashes = []planner.Overlap{}
+	}
+	writeJSON(w, clashes)
+}
+
+type swapRequest struct {
+	User    string `json:"user"`
+	Subject string `json:"subject"`
+	Have    string `json:"have"`
+	Want    string `json:"want"`
+}
+
+type swapResponse struct {
+	Requests []swap.Request `json:"requests"`
+	Matches  []swap.Match   `json:"matches"`
+}
+
+// GET /api/swaps?user=ann lists the requests and matches of a user,
+// POST /api/swaps with {"user", "subject", "have", "want"} adds a request
+// and returns it along with the matches it completed
+func (s *Server) swaps(w http.ResponseWriter, r *http.Request) {
+	if !s.swapsAllowed(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user := r.URL.Query().Get("user")
+		requests, err := s.Swaps.Requests(user)
+		if err != nil {
+			swapError(w, err)
+			return
+		}
+		matches, err := s.Swaps.Matches(user)
+		if err != nil {
+			swapError(w, err)
+			return
+		}
+		if requests == nil {
+			requests = []swap.Request{}
+		}
+		if matches == nil {
+			matches = []swap.Match{}
+		}
+		writeJSON(w, swapResponse{Requests: requests, Matches: matches})
+	case http.MethodPost:
+		var body swapRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request, matches, err := s.Swaps.Post(swap.Request{
+			User: body.User, SubjectId: body.Subject, Have: body.Have, Want: body.Want,
+		})
+		if err != nil {
+			swapError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, swapResponse{Requests: []swap.Request{request}, Matches: matches})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DELETE /api/swaps/<id>?user=ann cancels a pending request
+func (s *Server) cancelSwap(w http.ResponseWriter, r *http.Request) {
+	if !s.swapsAllowed(w, r) {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/swaps/"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Swaps.Cancel(r.URL.Query().Get("user"), id); err != nil {
+		swapError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Answers requests when swaps are off or the secret is wrong
+func (s *Server) swapsAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if s.Swaps == nil {
+		http.Error(w, "swaps are turned off", http.StatusNotFound)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.SwapSecret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.SwapSecret)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "wrong or missing swap secret", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func swapError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, swap.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, swap.ErrDuplicate):
+		status = http.StatusConflict
+	case errors.Is(err, swap.ErrMissingUser), errors.Is(err, swap.ErrUnknownSubject),
+		errors.Is(err, swap.ErrUnknownIndex), errors.Is(err, swap.ErrSameIndex):
+		status = http.StatusBadRequest
+	default:
+		logging.Errorf("swaps: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Errorf("failed to encode response %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package server_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/campus"
+	"github.com/jaxsax/ntu-room-finder/internal/server"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/internal/swap"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(index string, t parser.SessionType, day parser.Weekday, venue string, from, to time.Time) parser.Schedule {
+	return parser.Schedule{Index: index, Type: t, Day: day, Venue: venue,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+// Two subjects, the tutorials of 10105 and 20201 clash
+func newSnapshot() *snapshot.Snapshot {
+	return &snapshot.Snapshot{
+		Path: "data/2018-09-13",
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{
+			{Id: "CZ2001", Schedules: []parser.Schedule{
+				session("10105", parser.Lecture, parser.Monday, "LT2A", at(13, 30), at(14, 30)),
+				session("10105", parser.Tutorial, parser.Wednesday, "N4-01A-02", at(14, 30), at(15, 30)),
+				session("10106", parser.Lecture, parser.Monday, "LT2A", at(13, 30), at(14, 30)),
+				session("10106", parser.Tutorial, parser.Tuesday, "N4-01A-03", at(8, 30), at(9, 30)),
+			}},
+			{Id: "CZ2002", Schedules: []parser.Schedule{
+				session("20201", parser.Tutorial, parser.Wednesday, "NS4-05-37", at(14, 30), at(15, 30)),
+				session("20202", parser.Tutorial, parser.Thursday, "NS4-05-37", at(10, 30), at(11, 30)),
+			}},
+		}}},
+	}
+}
+
+// Serves newSnapshot, setup may be nil and is called before the server
+// starts
+func newServer(t *testing.T, setup func(*server.Server)) *httptest.Server {
+	srv := server.New(newSnapshot())
+	if setup != nil {
+		setup(srv)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func get(t *testing.T, url string) (int, string) {
+	return send(t, http.MethodGet, url, "")
+}
+
+func send(t *testing.T, method, url, body string) (int, string) {
+	return sendToken(t, method, url, body, "")
+}
+
+// Like send, with token as the bearer token unless it is empty
+func sendToken(t *testing.T, method, url, body, token string) (int, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	reply, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, string(reply)
+}
+
+type testCase struct {
+	query       string
+	status      int
+	contains    []string
+	notContains []string
+}
+
+func check(t *testing.T, id int, c testCase, status int, body string) {
+	if status != c.status {
+		t.Errorf("id=%d expected=%d got=%d %s", id, c.status, status, body)
+	}
+	for _, s := range c.contains {
+		if !strings.Contains(body, s) {
+			t.Errorf("id=%d expected=%q got=%s", id, s, body)
+		}
+	}
+	for _, s := range c.notContains {
+		if strings.Contains(body, s) {
+			t.Errorf("id=%d unexpected=%q got=%s", id, s, body)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	ts := newServer(t, nil)
+	cases := []testCase{
+		{"?subjects=CZ2001,cz2002", http.StatusOK, []string{`"combinations":3`, `"Index":"20202"`}, nil},
+		{"?subjects=CZ2001&limit=1", http.StatusOK, []string{`"combinations":2`}, nil},
+		{"?subjects=CZ2001,CZ2002&notBefore=0800&early=0&gap=0&freeDay=0", http.StatusOK,
+			[]string{`"combinations":3`, `"Cost":0`}, nil},
+		{"?subjects=CZ2001,CZ9999", http.StatusNotFound, []string{"unknown subject CZ9999"}, nil},
+		{"?subjects=", http.StatusBadRequest, []string{"planner: no subjects given"}, nil},
+		{"?subjects=CZ2001&limit=0", http.StatusBadRequest, []string{"limit must be from 1 to 100, got 0"}, nil},
+		{"?subjects=CZ2001&limit=1000", http.StatusBadRequest, []string{"limit must be from 1 to 100, got 1000"}, nil},
+		{"?subjects=CZ2001&gap=lots", http.StatusBadRequest, []string{"invalid syntax"}, nil},
+		{"?subjects=CZ2001&notBefore=9am", http.StatusBadRequest, []string{"occupancy: invalid clock time"}, nil},
+	}
+
+	for id, c := range cases {
+		status, body := get(t, ts.URL+"/api/plan"+c.query)
+		check(t, id, c, status, body)
+	}
+
+	limited := newServer(t, func(s *server.Server) { s.Planner.MaxWork = 1 })
+	status, body := get(t, limited.URL+"/api/plan?subjects=CZ2001,CZ2002")
+	check(t, 0, testCase{status: http.StatusBadRequest, contains: []string{"planner: too many combinations"}}, status, body)
+}
+
+func TestClashes(t *testing.T) {
+	ts := newServer(t, nil)
+	cases := []testCase{
+		{"?indexes=10105,20201", http.StatusOK, []string{`"Day":"WED","Start":"1430","End":"1530"`}, []string{`"MON"`}},
+		{"?indexes=10106,%2020201", http.StatusOK, []string{"[]"}, nil},
+		{"?indexes=10105,10106", http.StatusBadRequest,
+			[]string{"planner: more than one index of a subject: CZ2001 has 10105 and 10106"}, nil},
+		{"?indexes=10105,20201,10105", http.StatusBadRequest, []string{"CZ2001 has 10105 and 10105"}, nil},
+		{"?indexes=", http.StatusOK, []string{"[]"}, nil},
+		{"?indexes=10105,99999", http.StatusNotFound, []string{"planner: index not found: 99999"}, nil},
+	}
+
+	for id, c := range cases {
+		status, body := get(t, ts.URL+"/api/clashes"+c.query)
+		check(t, id, c, status, body)
+	}
+}
+
+func TestSwaps(t *testing.T) {
+	store, err := swap.Open(filepath.Join(t.TempDir(), "swaps.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ts := newServer(t, func(s *server.Server) {
+		s.Swaps = swap.NewService(store, "2018;1", newSnapshot().Subjects(), 3)
+		s.SwapSecret = "letmein"
+	})
+
+	// Run in order, later steps see the requests of earlier ones
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		testCase
+	}{
+		{http.MethodPost, "/api/swaps", `{"user": "ann", "subject": "cz2001", "have": "10105", "want": "10106"}`,
+			testCase{status: http.StatusCreated, contains: []string{`"ID":1,`, `"SubjectId":"CZ2001"`, `"Want":"10106"`}}},
+		{http.MethodPost, "/api/swaps", `{"user": "ann", "subject": "CZ2001", "have": "10105", "want": "10106"}`,
+			testCase{status: http.StatusConflict, contains: []string{"swap: "}}},
+		{http.MethodPost, "/api/swaps", `{"user": "ann", "subject": "CZ2001", "have": "10105", "want": "99999"}`,
+			testCase{status: http.StatusBadRequest, contains: []string{"99999"}}},
+		{http.MethodPost, "/api/swaps", `{"subject": "CZ2001", "have": "10106", "want": "10105"}`,
+			testCase{status: http.StatusBadRequest}},
+		{http.MethodPost, "/api/swaps", `{"user": "bob", "subject": "CZ9999", "have": "10106", "want": "10105"}`,
+			testCase{status: http.StatusBadRequest, contains: []string{"CZ9999"}}},
+		{http.MethodPost, "/api/swaps", `{"user":`, testCase{status: http.StatusBadRequest}},
+		{http.MethodPost, "/api/swaps", `{"user": "bob", "subject": "CZ2001", "have": "10106", "want": "10105"}`,
+			testCase{status: http.StatusCreated, contains: []string{`"matches":[{"ID":1,`, `"User":"ann"`, `"User":"bob"`}}},
+		{http.MethodGet, "/api/swaps?user=ann", "",
+			testCase{status: http.StatusOK, contains: []string{`"MatchID":1`, `"matches":[{"ID":1,`}}},
+		{http.MethodGet, "/api/swaps?user=cat", "",
+			testCase{status: http.StatusOK, contains: []string{`"requests":[]`, `"matches":[]`}}},
+		{http.MethodPost, "/api/swaps", `{"user": "cat", "subject": "CZ2002", "have": "20201", "want": "20202"}`,
+			testCase{status: http.StatusCreated, contains: []string{`"ID":3,`}}},
+		{http.MethodDelete, "/api/swaps/3?user=bob", "", testCase{status: http.StatusNotFound}},
+		{http.MethodDelete, "/api/swaps/3?user=cat", "", testCase{status: http.StatusNoContent}},
+		{http.MethodDelete, "/api/swaps/3?user=cat", "", testCase{status: http.StatusNotFound}},
+		// Matched requests stay
+		{http.MethodDelete, "/api/swaps/1?user=ann", "", testCase{status: http.StatusNotFound}},
+		{http.MethodDelete, "/api/swaps/first?user=ann", "", testCase{status: http.StatusBadRequest}},
+		{http.MethodGet, "/api/swaps/1", "", testCase{status: http.StatusMethodNotAllowed}},
+		{http.MethodPut, "/api/swaps", "", testCase{status: http.StatusMethodNotAllowed}},
+	}
+
+	for id, step := range steps {
+		status, body := sendToken(t, step.method, ts.URL+step.path, step.body, "letmein")
+		check(t, id, step.testCase, status, body)
+	}
+
+	// The user is only trusted from callers with the secret
+	for id, token := range []string{"", "letmeout", "Bearer letmein"} {
+		status, body := sendToken(t, http.MethodGet, ts.URL+"/api/swaps?user=ann", "", token)
+		check(t, id, testCase{status: http.StatusUnauthorized, contains: []string{"swap secret"}}, status, body)
+		status, body = sendToken(t, http.MethodDelete, ts.URL+"/api/swaps/2?user=bob", "", token)
+		check(t, id, testCase{status: http.StatusUnauthorized}, status, body)
+	}
+
+	off := newServer(t, nil)
+	status, body := get(t, off.URL+"/api/swaps?user=ann")
+	check(t, 0, testCase{status: http.StatusNotFound, contains: []string{"swaps are turned off"}}, status, body)
+}
+
+func TestFreeBlocks(t *testing.T) {
+	ts := newServer(t, nil)
+	cases := []testCase{
+		{"/LT2A/free-blocks?day=MON", http.StatusOK,
+			[]string{`{"Day":"MON","Start":"0000","End":"1330"}`, `{"Day":"MON","Start":"1430","End":"2400"}`}, nil},
+		{"/LT2A/free-blocks?day=mon&min=10h", http.StatusOK, []string{`"End":"1330"`}, []string{`"Start":"1430"`}},
+		{"/LT2A/free-blocks?day=TUE", http.StatusOK, []string{`{"Day":"TUE","Start":"0000","End":"2400"}`}, nil},
+		{"/LT2A/free-blocks?day=MON&min=24h", http.StatusOK, []string{"[]"}, nil},
+		{"/LT2A/free-blocks?day=someday", http.StatusBadRequest, nil, nil},
+		{"/LT2A/free-blocks?day=MON&min=2", http.StatusBadRequest, []string{"missing unit"}, nil},
+		{"/LT99/free-blocks?day=MON", http.StatusNotFound, nil, nil},
+	}
+
+	for id, c := range cases {
+		status, body := get(t, ts.URL+"/api/rooms"+c.query)
+		check(t, id, c, status, body)
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	// after is read in the local time zone, the slot has to stay in it
+	local := time.Local
+	time.Local = time.FixedZone("SGT", 8*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	ts := newServer(t, nil)
+	cases := []testCase{
+		{"/LT2A/next-free?after=2018-09-17T1345", http.StatusOK,
+			[]string{`{"start":"2018-09-17T14:30:00+08:00","end":"2018-09-18T00:00:00+08:00"}`}, nil},
+		{"/LT2A/next-free?after=2018-09-17T0800", http.StatusOK,
+			[]string{`{"start":"2018-09-17T08:00:00+08:00","end":"2018-09-17T13:30:00+08:00"}`}, nil},
+		{"/LT2A/next-free?after=2018-09-17", http.StatusBadRequest, []string{"cannot parse"}, nil},
+		{"/LT2A/next-free?after=2018-09-17T2500", http.StatusBadRequest, nil, nil},
+		{"/LT99/next-free?after=2018-09-17T0800", http.StatusNotFound, nil, nil},
+	}
+
+	for id, c := range cases {
+		status, body := get(t, ts.URL+"/api/rooms"+c.query)
+		check(t, id, c, status, body)
+	}
+}
+
+func TestFreeRooms(t *testing.T) {
+	buildings, err := campus.New([]campus.Building{{Code: "N4"}, {Code: "NS4"}},
+		[]campus.Edge{{From: "N4", To: "NS4", Minutes: 5}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newServer(t, func(s *server.Server) { s.Campus = buildings })
+	cases := []testCase{
+		{"?day=TUE&from=1000&to=1100", http.StatusOK,
+			[]string{`"rooms":["LT2A","N4-01A-02","N4-01A-03","NS4-05-37"]`}, []string{"nearby"}},
+		{"?day=TUE&from=0900&to=1000", http.StatusOK, []string{`"rooms":["LT2A","N4-01A-02","NS4-05-37"]`}, nil},
+		{"?date=2018-09-18&from=0900&to=1000", http.StatusOK,
+			[]string{`"day":"TUE","date":"2018-09-18"`, `"rooms":["LT2A","N4-01A-02","NS4-05-37"]`}, nil},
+		{"?day=TUE&from=1000&to=1100&near=NS4", http.StatusOK,
+			[]string{`"rooms":["NS4-05-37","N4-01A-02","N4-01A-03","LT2A"]`,
+				`{"venue":"NS4-05-37","building":"NS4","minutes":0,"reachable":true}`,
+				`{"venue":"N4-01A-03","building":"N4","minutes":5,"reachable":true}`,
+				`{"venue":"LT2A","minutes":0,"reachable":false}`}, nil},
+		{"?day=TUE&from=1000&to=1100&near=N4-01A-02", http.StatusOK,
+			[]string{`"rooms":["N4-01A-02","N4-01A-03","NS4-05-37","LT2A"]`}, nil},
+		{"?day=TUE&from=1000&to=1100&near=S1", http.StatusBadRequest, []string{"campus: building not found: S1"}, nil},
+		{"?day=TUE&from=1000&to=1000", http.StatusBadRequest, []string{"1000 is not after 1000"}, nil},
+		{"?day=TUE&from=1000&to=0900", http.StatusBadRequest, []string{"0900 is not after 1000"}, nil},
+		{"?day=TUE&from=10&to=1100", http.StatusBadRequest, []string{"occupancy: invalid clock time"}, nil},
+		{"?day=TUE&from=-100&to=0000", http.StatusBadRequest, []string{"occupancy: invalid clock time"}, nil},
+		{"?day=TUE&from=2330&to=2430", http.StatusBadRequest, []string{"occupancy: invalid clock time"}, nil},
+		{"?day=someday&from=1000&to=1100", http.StatusBadRequest, nil, nil},
+		{"?date=18-09-2018&from=1000&to=1100", http.StatusBadRequest, nil, nil},
+	}
+
+	for id, c := range cases {
+		status, body := get(t, ts.URL+"/api/free-rooms"+c.query)
+		check(t, id, c, status, body)
+	}
+
+	off := newServer(t, nil)
+	status, body := get(t, off.URL+"/api/free-rooms?day=TUE&from=1000&to=1100&near=NS4")
+	check(t, 0, testCase{status: http.StatusBadRequest, contains: []string{"near needs a campus file"}}, status, body)
+}
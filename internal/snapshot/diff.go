@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+)
+
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+)
+
+func (k ChangeKind) String() string {
+	if k == Added {
+		return "+"
+	}
+	return "-"
+}
+
+type Change struct {
+	Kind      ChangeKind
+	SubjectId string
+	Schedule  parser.Schedule
+}
+
+func (c Change) String() string {
+	s := c.Schedule
+	return fmt.Sprintf("%s %s %s %s %s %s %s %s",
+		c.Kind, c.SubjectId, s.Index, s.Type, s.Group, s.Day, s.TimeText, s.Venue)
+}
+
+type sessionKey struct {
+	subjectId string
+	id        uint64
+}
+
+func sessions(s *Snapshot) map[sessionKey]Change {
+	m := make(map[sessionKey]Change)
+	for _, subject := range s.Subjects() {
+		for _, schedule := range subject.Schedules {
+			m[sessionKey{subject.Id, schedule.Id()}] = Change{
+				SubjectId: subject.Id,
+				Schedule:  schedule,
+			}
+		}
+	}
+	return m
+}
+
+// Lists the sessions that were added or removed going from a to b
+func Diff(a, b *Snapshot) []Change {
+	before, after := sessions(a), sessions(b)
+
+	var changes []Change
+	for key, change := range after {
+		if _, ok := before[key]; !ok {
+			change.Kind = Added
+			changes = append(changes, change)
+		}
+	}
+	for key, change := range before {
+		if _, ok := after[key]; !ok {
+			change.Kind = Removed
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.SubjectId != b.SubjectId {
+			return a.SubjectId < b.SubjectId
+		}
+		if a.Schedule.Index != b.Schedule.Index {
+			return a.Schedule.Index < b.Schedule.Index
+		}
+		if a.Schedule.Id() != b.Schedule.Id() {
+			return a.Schedule.Id() < b.Schedule.Id()
+		}
+		return a.Kind < b.Kind
+	})
+	return changes
+}
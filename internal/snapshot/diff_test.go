@@ -0,0 +1,55 @@
+package snapshot_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func withSubjects(subjects ...parser.Subject) *snapshot.Snapshot {
+	return &snapshot.Snapshot{Courses: []snapshot.Course{{Subjects: subjects}}}
+}
+
+func TestDiff(t *testing.T) {
+	before := withSubjects(
+		parser.Subject{Id: "CZ2002", Schedules: []parser.Schedule{
+			session("20201", parser.Friday, "1030-1130", "LT1"),
+		}},
+		parser.Subject{Id: "CZ2001", Schedules: []parser.Schedule{
+			session("10105", parser.Monday, "0830-0930", "TR+15"),
+			session("10106", parser.Tuesday, "0830-0930", "TR+16"),
+		}},
+	)
+	after := withSubjects(
+		parser.Subject{Id: "CZ2001", Schedules: []parser.Schedule{
+			session("10105", parser.Monday, "0830-0930", "TR+15"),
+			// Moved to another venue
+			session("10106", parser.Tuesday, "0830-0930", "TR+18"),
+			session("10107", parser.Wednesday, "0830-0930", "TR+17"),
+		}},
+		parser.Subject{Id: "CZ1003", Schedules: []parser.Schedule{
+			session("30301", parser.Thursday, "1430-1530", "LT2"),
+		}},
+	)
+
+	expected := []string{
+		"+ CZ1003 30301 TUT  THU 1430-1530 LT2",
+		"- CZ2001 10106 TUT  TUE 0830-0930 TR+16",
+		"+ CZ2001 10106 TUT  TUE 0830-0930 TR+18",
+		"+ CZ2001 10107 TUT  WED 0830-0930 TR+17",
+		"- CZ2002 20201 TUT  FRI 1030-1130 LT1",
+	}
+	changes := snapshot.Diff(before, after)
+	if len(changes) != len(expected) {
+		t.Fatalf("expected=%d got=%v", len(expected), changes)
+	}
+	for id, change := range changes {
+		if change.String() != expected[id] {
+			t.Errorf("id=%d expected=%q got=%q", id, expected[id], change.String())
+		}
+	}
+
+	if changes := snapshot.Diff(after, after); len(changes) != 0 {
+		t.Errorf("expected no changes got=%v", changes)
+	}
+}
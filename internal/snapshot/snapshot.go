@@ -0,0 +1,208 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	coursesparser "github.com/jaxsax/ntu-room-finder/internal/parser"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNoSnapshot = errors.New("snapshot: no snapshot folders found")
+)
+
+var snapshotFolderPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(_\d{4}-\w)?$`)
+
+// A crawled folder, parsed back into courses and their subjects
+type Snapshot struct {
+	Path     string
+	Semester parser.AcademicSemester
+	Courses  []Course
+	// Exam timetable, empty when it was not crawled
+	Exams []parser.Exam
+}
+
+type Course struct {
+	downloader.CourseMapping
+	// Stable identifier including the semester, see parser.CourseID
+	UID string
+	// Decoded from the course key, zero when the key is not understood
+	Info     parser.CourseInfo
+	Subjects []parser.Subject
+}
+
+// Returns the most recent dated snapshot folder inside dataDir
+func Latest(dataDir string) (string, error) {
+	entries, err := ioutil.ReadDir(dataDir)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && snapshotFolderPattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("%w in %s", ErrNoSnapshot, dataDir)
+	}
+	sort.Strings(names)
+	return filepath.Join(dataDir, names[len(names)-1]), nil
+}
+
+func Load(path string) (*Snapshot, error) {
+	mappings, err := downloader.ReadCourseMapping(filepath.Join(path, "mapping.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Path: path}
+	if sem, err := downloader.SnapshotSemester(path); err == nil {
+		snapshot.Semester = sem
+	} else {
+		logging.Warnf("ids are built without a semester: %v", err)
+	}
+
+	if f, err := os.Open(filepath.Join(path, downloader.ExamsFile)); err == nil {
+		exams, skipped, err := parser.FindExamsWithOptions(f, parser.ExamOptions{Lenient: true})
+		f.Close()
+		if err != nil {
+			logging.Warnf("skipping exam timetable: %v", err)
+		}
+		for _, row := range skipped {
+			logging.Warnf("skipping exam: %v", row)
+		}
+		snapshot.Exams = exams
+	}
+
+	opts := coursesparser.CourseOptions{
+		Concurrency: runtime.NumCPU(),
+		Lenient:     true,
+		Semester:    snapshot.Semester.Key,
+	}
+	summary, err := coursesparser.ParseCourses(path, mappings, opts,
+		func(r coursesparser.Result) error {
+			info, err := r.Course.Info()
+			if err != nil {
+				logging.Debugf("%s: %v", r.Course.Text, err)
+			}
+			snapshot.Courses = append(snapshot.Courses, Course{
+				CourseMapping: r.Course,
+				UID:           parser.CourseID(snapshot.Semester.Key, r.Course.Key),
+				Info:          info,
+				Subjects:      r.Subjects,
+			})
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if summary.Failed() > 0 {
+		logging.Warnf("skipped %d of %d courses", summary.Failed(), summary.Courses)
+	}
+	return snapshot, nil
+}
+
+// Returns every distinct subject in the snapshot, keyed by subject id.
+// Subjects appear under many courses so their schedules are merged,
+// dropping sessions that were already seen.
+func (s *Snapshot) Subjects() map[string]parser.Subject {
+	subjects := make(map[string]parser.Subject)
+	seen := make(map[string]map[uint64]bool)
+	for _, course := range s.Courses {
+		for _, subject := range course.Subjects {
+			merged, ok := subjects[subject.Id]
+			if !ok {
+				merged = subject
+				merged.Schedules = nil
+				seen[subject.Id] = make(map[uint64]bool)
+			}
+			for _, schedule := range subject.Schedules {
+				id := schedule.Id()
+				if seen[subject.Id][id] {
+					continue
+				}
+				seen[subject.Id][id] = true
+				merged.Schedules = append(merged.Schedules, schedule)
+			}
+			subjects[subject.Id] = merged
+		}
+	}
+	return subjects
+}
+
+var subjectCodePattern = regexp.MustCompile(`\b[A-Z]{2,3}\d{4}[A-Z]?\b`)
+
+// Returns the subject codes mentioned in prerequisites and mutually
+// exclusive lists that are not in the snapshot, sorted. These are often
+// electives that are only found by searching for their code.
+func (s *Snapshot) ReferencedSubjects() []string {
+	subjects := s.Subjects()
+	missing := make(map[string]bool)
+	for _, subject := range subjects {
+		codes := subjectCodePattern.FindAllString(subject.Prerequisite, -1)
+		codes = append(codes, subject.MutuallyExclusive...)
+		for _, code := range codes {
+			if _, ok := subjects[code]; !ok {
+				missing[code] = true
+			}
+		}
+	}
+	codes := make([]string, 0, len(missing))
+	for code := range missing {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// A session as listed under a course
+type Session struct {
+	Course    *Course
+	SubjectId string
+	Schedule  parser.Schedule
+}
+
+// Zero fields match everything
+type SessionFilter struct {
+	Programme string
+	Year      int
+	Type      parser.SessionType
+}
+
+func (f SessionFilter) matchCourse(c *Course) bool {
+	return (f.Programme == "" || strings.EqualFold(f.Programme, c.Info.Programme)) &&
+		(f.Year == 0 || f.Year == c.Info.Year)
+}
+
+// Returns the sessions of the courses matching f in course order, e.g.
+// every year 2 EEE tutorial. A session listed under several matching
+// courses appears once for each.
+func (s *Snapshot) Sessions(f SessionFilter) []Session {
+	var sessions []Session
+	for i := range s.Courses {
+		course := &s.Courses[i]
+		if !f.matchCourse(course) {
+			continue
+		}
+		for _, subject := range course.Subjects {
+			for _, schedule := range subject.Schedules {
+				if f.Type != "" && f.Type != schedule.Type {
+					continue
+				}
+				sessions = append(sessions, Session{Course: course, SubjectId: subject.Id, Schedule: schedule})
+			}
+		}
+	}
+	return sessions
+}
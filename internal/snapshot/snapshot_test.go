@@ -0,0 +1,141 @@
+package snapshot_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/downloader"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func session(index string, day parser.Weekday, timeText, venue string) parser.Schedule {
+	return parser.Schedule{Index: index, Type: parser.Tutorial, Day: day, TimeText: timeText, Venue: venue}
+}
+
+func TestSubjects(t *testing.T) {
+	s := &snapshot.Snapshot{Courses: []snapshot.Course{
+		{Subjects: []parser.Subject{
+			{Id: "CZ2001", Title: "ALGORITHMS", Schedules: []parser.Schedule{
+				session("10105", parser.Monday, "0830-0930", "TR+15"),
+				session("10106", parser.Tuesday, "0830-0930", "TR+16"),
+			}},
+		}},
+		{Subjects: []parser.Subject{
+			{Id: "CZ2002", Schedules: []parser.Schedule{session("20201", parser.Friday, "1030-1130", "LT1")}},
+			// The same subject seen from another course, with one more
+			// session and a title that is ignored
+			{Id: "CZ2001", Title: "OTHER", Schedules: []parser.Schedule{
+				session("10106", parser.Tuesday, "0830-0930", "TR+16"),
+				session("10107", parser.Wednesday, "0830-0930", "TR+17"),
+				session("10105", parser.Monday, "0830-0930", "TR+15"),
+			}},
+		}},
+	}}
+
+	subjects := s.Subjects()
+	if len(subjects) != 2 {
+		t.Fatalf("expected 2 subjects got=%v", subjects)
+	}
+	cases := []struct {
+		id      string
+		title   string
+		indexes []string
+	}{
+		{"CZ2001", "ALGORITHMS", []string{"10105", "10106", "10107"}},
+		{"CZ2002", "", []string{"20201"}},
+	}
+	for id, c := range cases {
+		subject := subjects[c.id]
+		var indexes []string
+		for _, schedule := range subject.Schedules {
+			indexes = append(indexes, schedule.Index)
+		}
+		if subject.Title != c.title || !reflect.DeepEqual(indexes, c.indexes) {
+			t.Errorf("id=%d expected=%s %v got=%s %v", id, c.title, c.indexes, subject.Title, indexes)
+		}
+	}
+
+	// The courses keep their own sessions
+	if n := len(s.Courses[1].Subjects[1].Schedules); n != 3 {
+		t.Errorf("expected=3 got=%d", n)
+	}
+}
+
+// Writes a snapshot folder with one course per page, an empty page
+// leaves the course file out
+func writeSnapshot(t *testing.T, pages ...string) string {
+	dir := filepath.Join(t.TempDir(), "2018-09-13_2018-1")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mappings := make([]downloader.CourseMapping, len(pages))
+	for i, page := range pages {
+		course := parser.Course{
+			Key:  fmt.Sprintf("C%02d;;1;F", i),
+			Text: fmt.Sprintf("Course %02d", i),
+		}
+		mappings[i] = downloader.CourseMapping{Course: course, Index: course.Id()}
+		if page == "" {
+			continue
+		}
+		name := filepath.Join(dir, strconv.FormatUint(course.Id(), 10)+".html")
+		if err := ioutil.WriteFile(name, []byte(page), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	body, err := json.Marshal(mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "mapping.json"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadIsLenient(t *testing.T) {
+	body, err := ioutil.ReadFile("../../testdata/acc-y1-single.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(body)
+	broken := strings.Replace(page, "1030-1230", "10x0-1230", 1)
+	dir := writeSnapshot(t, page, broken, "")
+
+	s, err := snapshot.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Semester.Key != "2018;1" {
+		t.Errorf("expected=2018;1 got=%s", s.Semester.Key)
+	}
+	// The missing course is skipped, the broken row is dropped
+	if len(s.Courses) != 2 {
+		t.Fatalf("expected 2 courses got=%d", len(s.Courses))
+	}
+	counts := make(map[string]int)
+	for _, course := range s.Courses {
+		for _, subject := range course.Subjects {
+			counts[course.Text] += len(subject.Schedules)
+		}
+	}
+	if counts["Course 00"] == 0 || counts["Course 01"] != counts["Course 00"]-1 {
+		t.Errorf("expected one row less in the broken page got=%v", counts)
+	}
+
+	// Both courses list the same subjects, merging gives the intact page
+	merged := 0
+	for _, subject := range s.Subjects() {
+		merged += len(subject.Schedules)
+	}
+	if merged != counts["Course 00"] {
+		t.Errorf("expected=%d got=%d", counts["Course 00"], merged)
+	}
+}
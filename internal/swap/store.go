@@ -0,0 +1,205 @@
+package swap
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS swap_match (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    semester STRING NOT NULL,
+    created STRING NOT NULL
+);
+
+-- have_index and want_index are index numbers of subject_id, match_id is
+-- NULL while the request is pending
+CREATE TABLE IF NOT EXISTS swap_request (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    semester STRING NOT NULL,
+    user STRING NOT NULL,
+    subject_id STRING NOT NULL,
+    have_index STRING NOT NULL,
+    want_index STRING NOT NULL,
+    created STRING NOT NULL,
+    match_id INTEGER REFERENCES swap_match(id),
+    -- position of the request in its match
+    match_position INTEGER,
+    cancelled INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// Requests and matches kept in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// Writes are serialised by the service, a single connection also
+	// keeps :memory: databases alive between calls
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("swap: creating tables in %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Add(r Request) (Request, error) {
+	res, err := s.db.Exec(`INSERT INTO swap_request(semester, user, subject_id, have_index, want_index, created)
+        VALUES(?, ?, ?, ?, ?, ?)`,
+		r.Semester, r.User, r.SubjectId, r.Have, r.Want, r.Created.Format(time.RFC3339Nano))
+	if err != nil {
+		return r, err
+	}
+	r.ID, err = res.LastInsertId()
+	return r, err
+}
+
+const requestColumns = `r.id, r.semester, r.user, r.subject_id, r.have_index, r.want_index, r.created,
+        COALESCE(r.match_id, 0)`
+
+func scanRequests(rows *sql.Rows) ([]Request, error) {
+	defer rows.Close()
+	var requests []Request
+	for rows.Next() {
+		var r Request
+		var created string
+		err := rows.Scan(&r.ID, &r.Semester, &r.User, &r.SubjectId, &r.Have, &r.Want, &created, &r.MatchID)
+		if err != nil {
+			return nil, err
+		}
+		if r.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// Requests of the semester waiting for a match, oldest first
+func (s *Store) Pending(semester string) ([]Request, error) {
+	rows, err := s.db.Query(`SELECT `+requestColumns+` FROM swap_request r
+        WHERE semester = ? AND match_id IS NULL AND cancelled = 0 ORDER BY id`, semester)
+	if err != nil {
+		return nil, err
+	}
+	return scanRequests(rows)
+}
+
+// Requests of user that were not cancelled, oldest first
+func (s *Store) Requests(semester, user string) ([]Request, error) {
+	rows, err := s.db.Query(`SELECT `+requestColumns+` FROM swap_request r
+        WHERE semester = ? AND user = ? AND cancelled = 0 ORDER BY id`, semester, user)
+	if err != nil {
+		return nil, err
+	}
+	return scanRequests(rows)
+}
+
+// Cancels a pending request, matched requests cannot be cancelled
+func (s *Store) Cancel(user string, id int64) error {
+	res, err := s.db.Exec(`UPDATE swap_request SET cancelled = 1
+        WHERE id = ? AND user = ? AND match_id IS NULL AND cancelled = 0`, id, user)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Cancels the pending requests of user giving away have, e.g. once
+// another request for it was matched
+func (s *Store) CancelPending(semester, user, subjectId, have string) error {
+	_, err := s.db.Exec(`UPDATE swap_request SET cancelled = 1
+        WHERE semester = ? AND user = ? AND subject_id = ? AND have_index = ? AND match_id IS NULL AND cancelled = 0`,
+		semester, user, subjectId, have)
+	return err
+}
+
+// Stores m and marks its requests as matched
+func (s *Store) Record(m Match) (Match, error) {
+	if len(m.Requests) == 0 {
+		return m, errors.New("swap: empty match")
+	}
+	m.Created = time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return m, err
+	}
+	res, err := tx.Exec(`INSERT INTO swap_match(semester, created) VALUES(?, ?)`,
+		m.Requests[0].Semester, m.Created.Format(time.RFC3339Nano))
+	if err != nil {
+		tx.Rollback()
+		return m, err
+	}
+	if m.ID, err = res.LastInsertId(); err != nil {
+		tx.Rollback()
+		return m, err
+	}
+	for i := range m.Requests {
+		_, err := tx.Exec(`UPDATE swap_request SET match_id = ?, match_position = ? WHERE id = ?`,
+			m.ID, i, m.Requests[i].ID)
+		if err != nil {
+			tx.Rollback()
+			return m, err
+		}
+		m.Requests[i].MatchID = m.ID
+	}
+	return m, tx.Commit()
+}
+
+// Matches of the semester involving user, oldest first
+func (s *Store) Matches(semester, user string) ([]Match, error) {
+	rows, err := s.db.Query(`SELECT `+requestColumns+`, m.created FROM swap_request r
+        JOIN swap_match m ON m.id = r.match_id
+        WHERE r.match_id IN (SELECT match_id FROM swap_request WHERE semester = ? AND user = ?)
+        ORDER BY r.match_id, r.match_position`, semester, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var r Request
+		var created, matchCreated string
+		err := rows.Scan(&r.ID, &r.Semester, &r.User, &r.SubjectId, &r.Have, &r.Want, &created, &r.MatchID,
+			&matchCreated)
+		if err != nil {
+			return nil, err
+		}
+		if r.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 || matches[len(matches)-1].ID != r.MatchID {
+			m := Match{ID: r.MatchID}
+			if m.Created, err = time.Parse(time.RFC3339Nano, matchCreated); err != nil {
+				return nil, err
+			}
+			matches = append(matches, m)
+		}
+		last := &matches[len(matches)-1]
+		last.Requests = append(last.Requests, r)
+	}
+	return matches, rows.Err()
+}
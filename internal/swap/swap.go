@@ -0,0 +1,218 @@
+package swap
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/planner"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissingUser    = errors.New("swap: user is required")
+	ErrUnknownSubject = errors.New("swap: subject not found")
+	ErrUnknownIndex   = errors.New("swap: index is not offered by the subject")
+	ErrSameIndex      = errors.New("swap: have and want are the same index")
+	ErrDuplicate      = errors.New("swap: request is already pending")
+	ErrNotFound       = errors.New("swap: request not found")
+)
+
+// "Have index X, want index Y" for a subject. Requests are pending until
+// they are matched or cancelled.
+type Request struct {
+	ID        int64
+	Semester  string
+	User      string
+	SubjectId string
+	Have      string
+	Want      string
+	Created   time.Time
+	// Set once the request is part of a match
+	MatchID int64 `json:",omitempty"`
+}
+
+// Requests that can all be granted at once, each gets the index of the
+// next one: Requests[i].Want is Requests[i+1].Have, wrapping around. Two
+// requests make a direct swap, more make a cycle.
+type Match struct {
+	ID       int64
+	Created  time.Time
+	Requests []Request
+}
+
+// Checks a request against the indexes of its subject
+func Validate(subjects map[string]parser.Subject, r Request) error {
+	if strings.TrimSpace(r.User) == "" {
+		return ErrMissingUser
+	}
+	subject, ok := subjects[r.SubjectId]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSubject, r.SubjectId)
+	}
+	if r.Have == r.Want {
+		return fmt.Errorf("%w: %s", ErrSameIndex, r.Have)
+	}
+	indexes := make(map[string]bool)
+	for _, index := range planner.Indexes(subject) {
+		indexes[index.Index] = true
+	}
+	for _, index := range []string{r.Have, r.Want} {
+		if !indexes[index] {
+			return fmt.Errorf("%w: %s %s", ErrUnknownIndex, r.SubjectId, index)
+		}
+	}
+	return nil
+}
+
+// Takes requests for the subjects of a semester and matches them as they
+// come in
+type Service struct {
+	store    *Store
+	semester string
+	subjects map[string]parser.Subject
+	// Largest number of requests in a cycle
+	maxCycle int
+
+	mu sync.Mutex
+}
+
+func NewService(store *Store, semester string, subjects map[string]parser.Subject, maxCycle int) *Service {
+	if maxCycle < 2 {
+		maxCycle = 2
+	}
+	return &Service{store: store, semester: semester, subjects: subjects, maxCycle: maxCycle}
+}
+
+// Validates and stores r, then returns the matches it completed
+func (s *Service) Post(r Request) (Request, []Match, error) {
+	r.Semester = s.semester
+	r.SubjectId = strings.ToUpper(strings.TrimSpace(r.SubjectId))
+	r.User = strings.TrimSpace(r.User)
+	if err := Validate(s.subjects, r); err != nil {
+		return r, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := s.store.Pending(s.semester)
+	if err != nil {
+		return r, nil, err
+	}
+	// A user can only give away an index once, asking for more indexes in
+	// exchange for it takes separate cancels
+	for _, p := range pending {
+		if p.holding() == r.holding() {
+			return p, nil, fmt.Errorf("%w: %d", ErrDuplicate, p.ID)
+		}
+	}
+
+	r.Created = time.Now().UTC()
+	r, err = s.store.Add(r)
+	if err != nil {
+		return r, nil, err
+	}
+
+	var matches []Match
+	for _, m := range FindMatches(append(pending, r), s.maxCycle) {
+		m, err := s.store.Record(m)
+		if err != nil {
+			return r, matches, err
+		}
+		matches = append(matches, m)
+		for _, matched := range m.Requests {
+			if matched.ID == r.ID {
+				r.MatchID = m.ID
+			}
+			// Other requests giving away the same index can no longer be
+			// granted
+			if err := s.store.CancelPending(matched.Semester, matched.User, matched.SubjectId, matched.Have); err != nil {
+				return r, matches, err
+			}
+		}
+	}
+	return r, matches, nil
+}
+
+// Cancels a pending request of user
+func (s *Service) Cancel(user string, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Cancel(user, id)
+}
+
+// Requests of user in the semester, pending and matched
+func (s *Service) Requests(user string) ([]Request, error) {
+	return s.store.Requests(s.semester, user)
+}
+
+// Matches involving user in the semester
+func (s *Service) Matches(user string) ([]Match, error) {
+	return s.store.Matches(s.semester, user)
+}
+
+// The index a request gives away, a user holds it once per subject
+func (r Request) holding() string {
+	return r.User + "\x00" + r.SubjectId + "\x00" + r.Have
+}
+
+// Finds disjoint cycles among pending requests, shortest first so direct
+// swaps win over longer cycles, then oldest request first. A user takes
+// part in a cycle at most once and gives away an index at most once.
+func FindMatches(pending []Request, maxCycle int) []Match {
+	used := make(map[int]bool)
+	given := make(map[string]bool)
+	var matches []Match
+	for size := 2; size <= maxCycle; size++ {
+		for start := range pending {
+			if used[start] {
+				continue
+			}
+			cycle := findCycle(pending, used, []int{start}, size)
+			if cycle == nil {
+				continue
+			}
+			var m Match
+			for _, i := range cycle {
+				used[i] = true
+				given[pending[i].holding()] = true
+				m.Requests = append(m.Requests, pending[i])
+			}
+			matches = append(matches, m)
+			for i, r := range pending {
+				if given[r.holding()] {
+					used[i] = true
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// Extends path to exactly size requests whose last one wants the index
+// the first one has
+func findCycle(pending []Request, used map[int]bool, path []int, size int) []int {
+	first, last := pending[path[0]], pending[path[len(path)-1]]
+	if len(path) == size {
+		if last.Want == first.Have {
+			return path
+		}
+		return nil
+	}
+
+	users := make(map[string]bool)
+	for _, i := range path {
+		users[pending[i].User] = true
+	}
+	for next, r := range pending {
+		if used[next] || users[r.User] || r.SubjectId != first.SubjectId || r.Have != last.Want {
+			continue
+		}
+		if cycle := findCycle(pending, used, append(path, next), size); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package swap_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/internal/swap"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func request(id int64, user, subject, have, want string) swap.Request {
+	return swap.Request{ID: id, User: user, SubjectId: subject, Have: have, Want: want}
+}
+
+func matchedIDs(matches []swap.Match) [][]int64 {
+	var ids [][]int64
+	for _, m := range matches {
+		var cycle []int64
+		for _, r := range m.Requests {
+			cycle = append(cycle, r.ID)
+		}
+		ids = append(ids, cycle)
+	}
+	return ids
+}
+
+func TestFindMatches(t *testing.T) {
+	cases := []struct {
+		pending  []swap.Request
+		maxCycle int
+		expected [][]int64
+	}{
+		// Direct swap
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2001", "2", "1"),
+		}, 3, [][]int64{{1, 2}}},
+		// Three way cycle
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2001", "2", "3"),
+			request(3, "cat", "CZ2001", "3", "1"),
+		}, 3, [][]int64{{1, 2, 3}}},
+		// Too long for maxCycle
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2001", "2", "3"),
+			request(3, "cat", "CZ2001", "3", "1"),
+		}, 2, nil},
+		// Direct swaps win over cycles using the same request
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2001", "2", "3"),
+			request(3, "cat", "CZ2001", "3", "1"),
+			request(4, "dan", "CZ2001", "2", "1"),
+		}, 3, [][]int64{{1, 4}}},
+		// Different subjects and the same user never match
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2002", "2", "1"),
+			request(3, "ann", "CZ2001", "2", "1"),
+		}, 3, nil},
+		// Each request is used once, oldest first
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "bob", "CZ2001", "2", "1"),
+			request(3, "cat", "CZ2001", "2", "1"),
+			request(4, "dan", "CZ2001", "1", "2"),
+		}, 3, [][]int64{{1, 2}, {3, 4}}},
+		// An index is given away once even when several requests offer it
+		{[]swap.Request{
+			request(1, "ann", "CZ2001", "1", "2"),
+			request(2, "ann", "CZ2001", "1", "3"),
+			request(3, "bob", "CZ2001", "2", "1"),
+			request(4, "cat", "CZ2001", "3", "1"),
+		}, 3, [][]int64{{1, 3}}},
+	}
+
+	for i, test := range cases {
+		result := matchedIDs(swap.FindMatches(test.pending, test.maxCycle))
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expected, result)
+		}
+	}
+}
+
+var subjects = map[string]parser.Subject{
+	"CZ2001": {Id: "CZ2001", Schedules: []parser.Schedule{
+		{Index: "10101"}, {Index: "10102"}, {Index: "10103"},
+	}},
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		request     swap.Request
+		expectedErr error
+	}{
+		{request(0, "ann", "CZ2001", "10101", "10102"), nil},
+		{request(0, "", "CZ2001", "10101", "10102"), swap.ErrMissingUser},
+		{request(0, "ann", "CZ9999", "10101", "10102"), swap.ErrUnknownSubject},
+		{request(0, "ann", "CZ2001", "10101", "10101"), swap.ErrSameIndex},
+		{request(0, "ann", "CZ2001", "10101", "99999"), swap.ErrUnknownIndex},
+	}
+
+	for i, test := range cases {
+		if err := swap.Validate(subjects, test.request); !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swaps.db")
+	store, err := swap.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := swap.NewService(store, "2018;1", subjects, 3)
+
+	posts := []swap.Request{
+		request(0, "ann", "cz2001", "10101", "10102"),
+		request(0, "bob", "CZ2001", "10102", "10103"),
+		request(0, "dan", "CZ2001", "10101", "10103"),
+	}
+	for i, r := range posts {
+		if _, matches, err := service.Post(r); err != nil || len(matches) != 0 {
+			t.Fatalf("id=%d unexpected matches=%v err=%v", i, matches, err)
+		}
+	}
+	if _, _, err := service.Post(posts[0]); !errors.Is(err, swap.ErrDuplicate) {
+		t.Errorf("expected=%v got=%v", swap.ErrDuplicate, err)
+	}
+	// Offering the same index for another one is a duplicate too
+	if _, _, err := service.Post(request(0, "ann", "CZ2001", "10101", "10103")); !errors.Is(err, swap.ErrDuplicate) {
+		t.Errorf("expected=%v got=%v", swap.ErrDuplicate, err)
+	}
+	if err := service.Cancel("bob", 3); !errors.Is(err, swap.ErrNotFound) {
+		t.Errorf("expected=%v got=%v", swap.ErrNotFound, err)
+	}
+	if err := service.Cancel("dan", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Completes the cycle ann -> bob -> cat
+	cat, matches, err := service.Post(request(0, "cat", "CZ2001", "10103", "10101"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matchedIDs(matches), [][]int64{{1, 2, 4}}) || cat.MatchID != matches[0].ID {
+		t.Errorf("unexpected matches=%+v request=%+v", matches, cat)
+	}
+	store.Close()
+
+	// Everything survives a restart
+	store, err = swap.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	service = swap.NewService(store, "2018;1", subjects, 3)
+
+	saved, err := service.Matches("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matchedIDs(saved), [][]int64{{1, 2, 4}}) {
+		t.Errorf("expected=%v got=%v", [][]int64{{1, 2, 4}}, matchedIDs(saved))
+	}
+	requests, err := service.Requests("dan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected cancelled requests to be hidden got=%+v", requests)
+	}
+	pending, err := store.Pending("2018;1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected no pending requests got=%+v", pending)
+	}
+}
+
+func TestServiceGivesAwayIndexOnce(t *testing.T) {
+	store, err := swap.Open(filepath.Join(t.TempDir(), "swaps.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	service := swap.NewService(store, "2018;1", subjects, 3)
+
+	// Stored before Post turned such requests away
+	for _, r := range []swap.Request{
+		request(0, "ann", "CZ2001", "10101", "10102"),
+		request(0, "ann", "CZ2001", "10101", "10103"),
+		request(0, "cat", "CZ2001", "10103", "10101"),
+	} {
+		r.Semester = "2018;1"
+		if _, err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, matches, err := service.Post(request(0, "bob", "CZ2001", "10102", "10101"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matchedIDs(matches), [][]int64{{1, 4}}) {
+		t.Errorf("expected=%v got=%v", [][]int64{{1, 4}}, matchedIDs(matches))
+	}
+	pending, err := store.Pending("2018;1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].User != "cat" {
+		t.Errorf("expected only the request of cat to be pending got=%+v", pending)
+	}
+}
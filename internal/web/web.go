@@ -0,0 +1,197 @@
+package web
+
+import (
+	"embed"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/internal/grid"
+	"github.com/jaxsax/ntu-room-finder/internal/logging"
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var files embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"roomURL": func(venue string) string { return "/rooms/" + url.PathEscape(venue) },
+}).ParseFS(files, "templates/*.html"))
+
+// Server rendered pages for finding free rooms, everything they need is
+// in the snapshot so they work offline
+type Handler struct {
+	snapshot  *snapshot.Snapshot
+	occupancy *occupancy.Index
+	rooms     *rooms.Registry
+	buildings []string
+	mux       *http.ServeMux
+}
+
+// registry may be nil, rooms then have no capacity and a minimum
+// capacity matches none
+func New(s *snapshot.Snapshot, idx *occupancy.Index, registry *rooms.Registry) *Handler {
+	h := &Handler{snapshot: s, occupancy: idx, rooms: registry, mux: http.NewServeMux()}
+	seen := make(map[string]bool)
+	for _, venue := range idx.Venues() {
+		if building := registry.Building(venue); !seen[building] {
+			seen[building] = true
+			h.buildings = append(h.buildings, building)
+		}
+	}
+	sort.Strings(h.buildings)
+
+	h.mux.HandleFunc("/", h.index)
+	h.mux.HandleFunc("/rooms/", h.room)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debugf("%s %s", r.Method, r.URL)
+	h.mux.ServeHTTP(w, r)
+}
+
+type freeRoom struct {
+	Venue    string
+	Building string
+	// 0 when unknown
+	Capacity int
+}
+
+type indexPage struct {
+	Snapshot  string
+	Days      []parser.Weekday
+	Buildings []string
+	Day       parser.Weekday
+	From, To  string
+	Building  string
+	Capacity  string
+	Error     string
+	Rooms     []freeRoom
+}
+
+// GET /?day=WED&from=0830&to=1030&building=NS4&capacity=40
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+	q := r.URL.Query()
+	page := indexPage{
+		Snapshot:  h.snapshot.Path,
+		Days:      parser.Weekdays,
+		Buildings: h.buildings,
+		Day:       parser.WeekdayOf(now.Weekday()),
+		From:      fmt.Sprintf("%02d00", now.Hour()),
+		Building:  q.Get("building"),
+		Capacity:  q.Get("capacity"),
+	}
+	if v := q.Get("day"); v != "" {
+		page.Day = parser.Weekday(v)
+	}
+	if v := q.Get("from"); v != "" {
+		page.From = v
+	}
+	page.To = q.Get("to")
+
+	free, err := h.freeRooms(&page)
+	if err != nil {
+		page.Error = err.Error()
+		render(w, http.StatusBadRequest, "index.html", page)
+		return
+	}
+	page.Rooms = free
+	render(w, http.StatusOK, "index.html", page)
+}
+
+// Fills in the defaults of page and returns the rooms matching it
+func (h *Handler) freeRooms(page *indexPage) ([]freeRoom, error) {
+	day, err := parser.ParseWeekday(string(page.Day))
+	if err != nil {
+		return nil, err
+	}
+	page.Day = day
+	from, err := occupancy.ParseClock(page.From)
+	if err != nil {
+		return nil, err
+	}
+	to := from + 60
+	if page.To != "" {
+		if to, err = occupancy.ParseClock(page.To); err != nil {
+			return nil, err
+		}
+	}
+	page.To = to.String()
+	if to <= from {
+		return nil, fmt.Errorf("%s is not after %s", page.To, page.From)
+	}
+	capacity := 0
+	if page.Capacity != "" {
+		if capacity, err = strconv.Atoi(page.Capacity); err != nil || capacity < 0 {
+			return nil, fmt.Errorf("capacity %q is not a number of seats", page.Capacity)
+		}
+	}
+
+	var free []freeRoom
+	for _, venue := range h.occupancy.Free(day, from, to) {
+		room, _ := h.rooms.Room(venue)
+		building := h.rooms.Building(venue)
+		if page.Building != "" && building != page.Building || room.Capacity < capacity {
+			continue
+		}
+		free = append(free, freeRoom{Venue: venue, Building: building, Capacity: room.Capacity})
+	}
+	return free, nil
+}
+
+type roomPage struct {
+	Snapshot string
+	Room     rooms.Room
+	Building string
+	// Weekly bookings drawn by grid.HTML, empty without any
+	Grid  template.HTML
+	Exams []occupancy.ExamBooking
+}
+
+// GET /rooms/<venue>, the weekly bookings of a room as a grid
+func (h *Handler) room(w http.ResponseWriter, r *http.Request) {
+	venue := strings.TrimPrefix(r.URL.Path, "/rooms/")
+	bookings := h.occupancy.Bookings(venue)
+	exams := h.occupancy.Exams(venue)
+	if len(bookings) == 0 && len(exams) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	room, _ := h.rooms.Room(venue)
+	page := roomPage{
+		Snapshot: h.snapshot.Path,
+		Room:     room,
+		Building: h.rooms.Building(venue),
+		Exams:    exams,
+	}
+	if len(bookings) > 0 {
+		var b strings.Builder
+		if err := grid.HTML(&b, grid.Room("", bookings)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Grid = template.HTML(b.String())
+	}
+	render(w, http.StatusOK, "room.html", page)
+}
+
+func render(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		logging.Errorf("failed to render %s %v", name, err)
+	}
+}
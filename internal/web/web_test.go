@@ -0,0 +1,130 @@
+package web_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/internal/occupancy"
+	"github.com/jaxsax/ntu-room-finder/internal/rooms"
+	"github.com/jaxsax/ntu-room-finder/internal/snapshot"
+	"github.com/jaxsax/ntu-room-finder/internal/web"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func session(day parser.Weekday, venue string, from, to time.Time) parser.Schedule {
+	return parser.Schedule{Index: "10105", Type: parser.Lecture, Day: day, Venue: venue,
+		TimeText: from.Format("1504") + "-" + to.Format("1504"), TimeStart: from, TimeEnd: to}
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	s := &snapshot.Snapshot{
+		Path: "data/2018-09-13",
+		Courses: []snapshot.Course{{Subjects: []parser.Subject{{
+			Id: "CZ2001",
+			Schedules: []parser.Schedule{
+				session(parser.Monday, "LT2A", at(13, 30), at(14, 30)),
+				session(parser.Tuesday, "NS4-05-37", at(9, 30), at(10, 30)),
+				session(parser.Saturday, "NS4-05-38", at(19, 0), at(21, 0)),
+				session(parser.Wednesday, "TR+15", at(8, 30), at(9, 30)),
+			},
+		}}}},
+	}
+	registry, err := rooms.Read(strings.NewReader("venue,capacity,building\nLT2A,250,\nNS4-05-37,60,\nTR+15,40,NS\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(web.New(s, occupancy.New(s), registry))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, url string) (int, string) {
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, string(body)
+}
+
+func TestIndex(t *testing.T) {
+	server := newServer(t)
+	cases := []struct {
+		query       string
+		status      int
+		contains    []string
+		notContains []string
+	}{
+		{"?day=MON&from=1300&to=1400", http.StatusOK,
+			[]string{"3 rooms free on MON from 1300 to 1400", `href="/rooms/NS4-05-37"`, `href="/rooms/TR&#43;15"`,
+				`<option value="MON" selected>`, "<td>?</td>"},
+			[]string{`href="/rooms/LT2A"`}},
+		{"?day=tue&from=0900&building=NS4", http.StatusOK,
+			[]string{"1 rooms free on TUE from 0900 to 1000", "NS4-05-38", `<option value="NS4" selected>`},
+			[]string{"NS4-05-37", `href="/rooms/LT2A"`}},
+		{"?day=WED&from=1000&to=1100&capacity=50", http.StatusOK,
+			[]string{"2 rooms free", `href="/rooms/LT2A"`, "<td>250</td>", "NS4-05-37"},
+			[]string{"TR&#43;15", "NS4-05-38"}},
+		{"?day=FUNDAY&from=1000", http.StatusBadRequest, []string{"unknown weekday"}, nil},
+		{"?day=MON&from=1000&to=0900", http.StatusBadRequest, []string{"0900 is not after 1000"}, nil},
+		{"?day=MON&from=1000&capacity=lots", http.StatusBadRequest, []string{"is not a number of seats"}, nil},
+		{"nope", http.StatusNotFound, nil, nil},
+	}
+
+	for i, test := range cases {
+		path := "/" + test.query
+		status, body := get(t, server.URL+path)
+		if status != test.status {
+			t.Errorf("id=%d expected=%d got=%d", i, test.status, status)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("id=%d expected body containing %q got=%s", i, s, body)
+			}
+		}
+		for _, s := range test.notContains {
+			if strings.Contains(body, s) {
+				t.Errorf("id=%d expected body without %q", i, s)
+			}
+		}
+	}
+}
+
+func TestRoom(t *testing.T) {
+	server := newServer(t)
+	status, body := get(t, server.URL+"/rooms/LT2A")
+	if status != http.StatusOK {
+		t.Fatalf("expected=%d got=%d", http.StatusOK, status)
+	}
+	for _, s := range []string{"250 seats", "<tr><th>1330</th>", "<b>CZ2001 LEC/STUDIO</b> 1330-1430<br>10105", ">FRI</th>"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected body containing %q got=%s", s, body)
+		}
+	}
+	if strings.Contains(body, ">SAT</th>") || strings.Contains(body, "<tr><th>1800</th>") {
+		t.Errorf("expected no weekend or evening slots got=%s", body)
+	}
+
+	// Saturday evening widens the grid
+	_, body = get(t, server.URL+"/rooms/NS4-05-38")
+	for _, s := range []string{">SAT</th>", "<tr><th>2030</th>", "Building NS4</p>"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected body containing %q got=%s", s, body)
+		}
+	}
+
+	if status, _ := get(t, server.URL+"/rooms/LT1"); status != http.StatusNotFound {
+		t.Errorf("expected=%d got=%d", http.StatusNotFound, status)
+	}
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAU = errors.New("parser: invalid academic units")
+)
+
+// Academic units of a subject. Most subjects are worth a fixed number of
+// AUs and have Min equal to Max, a few list a range like "1.0-3.0 AU".
+// Valid is false when the text on the page could not be read, the raw
+// text is kept in Subject.AuRaw either way.
+type AcademicUnits struct {
+	Min, Max float64
+	Valid    bool
+}
+
+func (a AcademicUnits) String() string {
+	if !a.Valid {
+		return ""
+	}
+	if a.Min == a.Max {
+		return strconv.FormatFloat(a.Min, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(a.Min, 'f', 1, 64) + "-" + strconv.FormatFloat(a.Max, 'f', 1, 64)
+}
+
+// Reads AU texts like "   2.0 AU", "3 AUs" or "1.0 - 3.0 AU"
+func ParseAU(raw string) (AcademicUnits, error) {
+	text := strings.ToUpper(strings.TrimSpace(raw))
+	for _, unit := range []string{"AUS", "AU"} {
+		if strings.HasSuffix(text, unit) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, unit))
+			break
+		}
+	}
+
+	parts := strings.Split(text, "-")
+	if len(parts) == 1 {
+		parts = strings.Split(text, " TO ")
+	}
+	if len(parts) > 2 {
+		return AcademicUnits{}, fmt.Errorf("%w: %q", ErrInvalidAU, raw)
+	}
+
+	var values []float64
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || value < 0 || value != value {
+			return AcademicUnits{}, fmt.Errorf("%w: %q", ErrInvalidAU, raw)
+		}
+		values = append(values, value)
+	}
+	au := AcademicUnits{Min: values[0], Max: values[len(values)-1], Valid: true}
+	if au.Min > au.Max {
+		return AcademicUnits{}, fmt.Errorf("%w: %q", ErrInvalidAU, raw)
+	}
+	return au, nil
+}
@@ -0,0 +1,39 @@
+package parser_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func TestParseAU(t *testing.T) {
+	cases := []struct {
+		raw         string
+		expected    parser.AcademicUnits
+		expectedErr error
+	}{
+		{"   2.0 AU", parser.AcademicUnits{Min: 2, Max: 2, Valid: true}, nil},
+		{"3 AUs", parser.AcademicUnits{Min: 3, Max: 3, Valid: true}, nil},
+		{"0.0 AU", parser.AcademicUnits{Min: 0, Max: 0, Valid: true}, nil},
+		{"1.5", parser.AcademicUnits{Min: 1.5, Max: 1.5, Valid: true}, nil},
+		{"1.0-3.0 AU", parser.AcademicUnits{Min: 1, Max: 3, Valid: true}, nil},
+		{"1.0 - 3.0 AU", parser.AcademicUnits{Min: 1, Max: 3, Valid: true}, nil},
+		{"2 to 4 au", parser.AcademicUnits{Min: 2, Max: 4, Valid: true}, nil},
+		{"", parser.AcademicUnits{}, parser.ErrInvalidAU},
+		{"AU", parser.AcademicUnits{}, parser.ErrInvalidAU},
+		{"TBA", parser.AcademicUnits{}, parser.ErrInvalidAU},
+		{"3.0-1.0 AU", parser.AcademicUnits{}, parser.ErrInvalidAU},
+		{"1-2-3 AU", parser.AcademicUnits{}, parser.ErrInvalidAU},
+		{"NaN AU", parser.AcademicUnits{}, parser.ErrInvalidAU},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseAU(test.raw)
+		if result != test.expected {
+			t.Errorf("id=%d expected=%+v got=%+v", i, test.expected, result)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
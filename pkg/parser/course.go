@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCourseKey = errors.New("parser: invalid course key")
+)
+
+// What a course in the r_course_yr select lists
+type CourseKind string
+
+const (
+	// A degree programme in a year of study, e.g. EEE;;2;F
+	CourseProgramme CourseKind = "programme"
+	// MLOAD;<minor>;X;F
+	CourseMinor CourseKind = "minor"
+	// GERP;<area>;X;F, general education requirement electives
+	CourseGeneralEducation CourseKind = "general-education"
+	// GLOAD;<school>;X;F, electives offered by a school
+	CourseGeneralElective CourseKind = "general-elective"
+	// USP;<track>;X;F and CNY;CNY;X;F
+	CourseScholars CourseKind = "scholars"
+	// SEARCH;<subject code>;X;F, pages found by looking up a subject
+	// code rather than listed in r_course_yr
+	CourseSearch CourseKind = "search"
+	// Anything else, e.g. EP;EP;X;F for English Proficiency
+	CourseOther CourseKind = "other"
+)
+
+// Decoded form of Course.Key. Keys are four fields separated by
+// semicolons: programme, specialisation, year of study and mode, e.g.
+// ACC;GA;1;F is year 1 of Accountancy (GA), full-time. Elective lists
+// like MLOAD;AHIS;X;F put their category first and the programme or
+// area second, with X for the year.
+type CourseInfo struct {
+	Kind CourseKind
+	// Programme code, the area of an elective list or a searched subject
+	Programme      string
+	Specialisation string `json:",omitempty"`
+	// 0 when the course is not tied to a year of study
+	Year     int
+	PartTime bool
+	// Set for the elective lists students pick from outside their
+	// programme: minors, GER-PE and school electives
+	GeneralElective bool
+}
+
+var courseKinds = map[string]CourseKind{
+	"MLOAD":  CourseMinor,
+	"GERP":   CourseGeneralEducation,
+	"GLOAD":  CourseGeneralElective,
+	"USP":    CourseScholars,
+	"CNY":    CourseScholars,
+	"SEARCH": CourseSearch,
+}
+
+func ParseCourseKey(key string) (CourseInfo, error) {
+	fields := strings.Split(key, ";")
+	if len(fields) != 4 || fields[0] == "" {
+		return CourseInfo{}, fmt.Errorf("%w: %q", ErrInvalidCourseKey, key)
+	}
+
+	var info CourseInfo
+	switch fields[3] {
+	case "F":
+	case "P":
+		info.PartTime = true
+	default:
+		return CourseInfo{}, fmt.Errorf("%w: %q has unknown mode %q", ErrInvalidCourseKey, key, fields[3])
+	}
+
+	if fields[2] == "X" {
+		kind, ok := courseKinds[fields[0]]
+		if !ok {
+			kind = CourseOther
+		}
+		info.Kind = kind
+		info.Programme = fields[1]
+		info.GeneralElective = kind == CourseMinor || kind == CourseGeneralEducation ||
+			kind == CourseGeneralElective
+		return info, nil
+	}
+
+	year, err := strconv.Atoi(fields[2])
+	if err != nil || year < 1 {
+		return CourseInfo{}, fmt.Errorf("%w: %q has unknown year %q", ErrInvalidCourseKey, key, fields[2])
+	}
+	info.Kind = CourseProgramme
+	info.Programme = fields[0]
+	info.Specialisation = fields[1]
+	info.Year = year
+	return info, nil
+}
+
+// Decodes the key of the course
+func (c *Course) Info() (CourseInfo, error) {
+	return ParseCourseKey(c.Key)
+}
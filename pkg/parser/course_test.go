@@ -0,0 +1,63 @@
+package parser_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func TestParseCourseKey(t *testing.T) {
+	cases := []struct {
+		key         string
+		expected    parser.CourseInfo
+		expectedErr error
+	}{
+		{"ACC;GA;1;F", parser.CourseInfo{
+			Kind: parser.CourseProgramme, Programme: "ACC", Specialisation: "GA", Year: 1}, nil},
+		{"EEE;;2;F", parser.CourseInfo{
+			Kind: parser.CourseProgramme, Programme: "EEE", Year: 2}, nil},
+		{"ME;;5;P", parser.CourseInfo{
+			Kind: parser.CourseProgramme, Programme: "ME", Year: 5, PartTime: true}, nil},
+		{"MLOAD;AHIS;X;F", parser.CourseInfo{
+			Kind: parser.CourseMinor, Programme: "AHIS", GeneralElective: true}, nil},
+		{"GERP;STS;X;F", parser.CourseInfo{
+			Kind: parser.CourseGeneralEducation, Programme: "STS", GeneralElective: true}, nil},
+		{"GLOAD;EEE;X;F", parser.CourseInfo{
+			Kind: parser.CourseGeneralElective, Programme: "EEE", GeneralElective: true}, nil},
+		{"USP;CORE;X;F", parser.CourseInfo{Kind: parser.CourseScholars, Programme: "CORE"}, nil},
+		{"CNY;CNY;X;F", parser.CourseInfo{Kind: parser.CourseScholars, Programme: "CNY"}, nil},
+		{"SEARCH;CZ2001;X;F", parser.CourseInfo{Kind: parser.CourseSearch, Programme: "CZ2001"}, nil},
+		{"EP;EP;X;F", parser.CourseInfo{Kind: parser.CourseOther, Programme: "EP"}, nil},
+		{"", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+		{"ACC;GA;1", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+		{"ACC;GA;1;Q", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+		{"ACC;GA;Y;F", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+		{"ACC;GA;0;F", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+		{";GA;1;F", parser.CourseInfo{}, parser.ErrInvalidCourseKey},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseCourseKey(test.key)
+		if result != test.expected {
+			t.Errorf("id=%d expected=%+v got=%+v", i, test.expected, result)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
+
+func TestEveryCourseKeyDecodes(t *testing.T) {
+	courses, err := parser.FindCourses(GetAcadSemFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) == 0 {
+		t.Fatal("no courses in fixture")
+	}
+	for _, course := range courses {
+		if _, err := course.Info(); err != nil {
+			t.Errorf("%s: %v", course.Text, err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCantFindExamTable = errors.New("parser: cannot find exam timetable")
+	ErrInvalidExam       = errors.New("parser: invalid exam row")
+)
+
+// An entry of the exam timetable. Start holds the date and time of the
+// paper as wall clock time in UTC, like Schedule.TimeStart. Venue is
+// empty when the timetable does not list one.
+type Exam struct {
+	SubjectId    string
+	Title        string
+	Day          Weekday
+	DateText     string
+	TimeText     string
+	DurationText string
+	Start        time.Time
+	Duration     time.Duration
+	Venue        string
+}
+
+func (e *Exam) End() time.Time {
+	return e.Start.Add(e.Duration)
+}
+
+// Columns of the exam timetable, VENUE is optional
+const (
+	examDate     = "DATE"
+	examTime     = "TIME"
+	examCourse   = "COURSE"
+	examTitle    = "COURSE TITLE"
+	examDuration = "DURATION"
+	examVenue    = "VENUE"
+)
+
+var examRequiredHeaders = []string{examDate, examTime, examCourse, examDuration}
+
+var examDateLayouts = []string{"2 January 2006", "2-Jan-2006", "2006-01-02"}
+
+type ExamOptions struct {
+	// Skip rows that cannot be parsed instead of failing the whole
+	// timetable, the skipped rows are returned as errors
+	Lenient bool
+}
+
+// Reads the exam timetable page, the table is found by its headers
+func FindExams(body io.Reader) ([]Exam, error) {
+	exams, _, err := FindExamsWithOptions(body, ExamOptions{})
+	return exams, err
+}
+
+// Like FindExams, also returning the rows skipped in lenient mode.
+// Without lenient mode the first bad row is returned as the error.
+func FindExamsWithOptions(body io.Reader, opts ExamOptions) ([]Exam, []error, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tableMatcher := func(n *html.Node) (keep bool, exit bool) {
+		keep = n.Type == html.ElementNode && n.DataAtom == atom.Table
+		return
+	}
+
+	for _, table := range TraverseNodes(doc, tableMatcher) {
+		rows := TraverseNodes(table, lessonTrMatcher)
+		if len(rows) == 0 {
+			continue
+		}
+		columns := make(map[string]int)
+		for i, cell := range rowCells(rows[0]) {
+			text, _ := nodeText(cell)
+			columns[strings.ToUpper(text)] = i
+		}
+		if !hasColumns(columns, examRequiredHeaders) {
+			continue
+		}
+
+		exams := make([]Exam, 0)
+		var skipped []error
+		for r, row := range rows[1:] {
+			var texts []string
+			for _, cell := range rowCells(row) {
+				text, _ := nodeText(cell)
+				texts = append(texts, text)
+			}
+			exam, err := parseExamRow(texts, columns)
+			if err != nil {
+				err = fmt.Errorf("%w: row %d: %v", ErrInvalidExam, r, err)
+				if !opts.Lenient {
+					return nil, nil, err
+				}
+				skipped = append(skipped, err)
+				continue
+			}
+			exams = append(exams, exam)
+		}
+		return exams, skipped, nil
+	}
+	return nil, nil, ErrCantFindExamTable
+}
+
+func hasColumns(columns map[string]int, names []string) bool {
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseExamRow(cells []string, columns map[string]int) (Exam, error) {
+	cell := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(cells) {
+			return ""
+		}
+		return cells[i]
+	}
+
+	exam := Exam{
+		SubjectId:    cell(examCourse),
+		Title:        cell(examTitle),
+		DateText:     cell(examDate),
+		TimeText:     cell(examTime),
+		DurationText: cell(examDuration),
+		Venue:        cell(examVenue),
+	}
+	if exam.SubjectId == "" {
+		return exam, errors.New("missing course")
+	}
+
+	date, err := parseExamDate(exam.DateText)
+	if err != nil {
+		return exam, err
+	}
+	hour, minute, err := parseExamTime(exam.TimeText)
+	if err != nil {
+		return exam, err
+	}
+	exam.Start = date.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	exam.Day = WeekdayOf(exam.Start.Weekday())
+
+	hours, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(
+		strings.ToLower(exam.DurationText), "hrs")), 64)
+	if err != nil || hours <= 0 || hours > 24 {
+		return exam, fmt.Errorf("invalid duration %q", exam.DurationText)
+	}
+	exam.Duration = time.Duration(hours * float64(time.Hour))
+	return exam, nil
+}
+
+func parseExamDate(s string) (time.Time, error) {
+	for _, layout := range examDateLayouts {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// Reads times like "9.00 am", "1.30 pm" or "1300"
+func parseExamTime(s string) (int, int, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if hour, minute, err := splitTime(text); err == nil {
+		return hour, minute, nil
+	}
+
+	var pm bool
+	switch {
+	case strings.HasSuffix(text, "am"):
+	case strings.HasSuffix(text, "pm"):
+		pm = true
+	default:
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	text = strings.TrimSpace(text[:len(text)-2])
+	parts := strings.FieldsFunc(text, func(r rune) bool { return r == '.' || r == ':' })
+	if len(parts) == 1 {
+		parts = append(parts, "00")
+	}
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 1 || hour > 12 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	if hour == 12 {
+		hour = 0
+	}
+	if pm {
+		hour += 12
+	}
+	return hour, minute, nil
+}
+
+// Returns the name and value of every hidden input in the page, used to
+// carry the exam plan of the timetable's search form into the request
+func FindHiddenInputs(body io.Reader) (map[string]string, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	inputMatcher := func(n *html.Node) (keep bool, exit bool) {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Input {
+			return
+		}
+		inputType, err := FindAttribute(n.Attr, "type")
+		keep = err == nil && strings.EqualFold(inputType, "hidden")
+		return
+	}
+
+	inputs := make(map[string]string)
+	for _, input := range TraverseNodes(doc, inputMatcher) {
+		name, err := FindAttribute(input.Attr, "name")
+		if err != nil {
+			continue
+		}
+		value, _ := FindAttribute(input.Attr, "value")
+		inputs[name] = value
+	}
+	return inputs, nil
+}
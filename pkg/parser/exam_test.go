@@ -0,0 +1,124 @@
+package parser_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindExams(t *testing.T) {
+	exams, err := parser.FindExams(GetFileReader("../../testdata/exam-timetable.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exams) != 3 {
+		t.Fatalf("expected 3 exams got=%d", len(exams))
+	}
+
+	expected := parser.Exam{
+		SubjectId:    "CZ2001",
+		Title:        "ALGORITHMS",
+		Day:          parser.Monday,
+		DateText:     "19 November 2018",
+		TimeText:     "1.00 pm",
+		DurationText: "2.5",
+		Start:        time.Date(2018, 11, 19, 13, 0, 0, 0, time.UTC),
+		Duration:     150 * time.Minute,
+		Venue:        "NORTH SPINE HALL",
+	}
+	if !reflect.DeepEqual(expected, exams[1]) {
+		t.Errorf("expected=%#v got=%#v", expected, exams[1])
+	}
+	if end := exams[1].End(); !end.Equal(time.Date(2018, 11, 19, 15, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected end %v", end)
+	}
+	if exams[2].Venue != "" || exams[2].Start.Hour() != 17 || exams[2].Day != parser.Wednesday {
+		t.Errorf("unexpected exam %#v", exams[2])
+	}
+}
+
+func TestFindExamsErrors(t *testing.T) {
+	const header = `<table><tr><th>DATE</th><th>TIME</th><th>COURSE</th><th>DURATION</th></tr>`
+	cases := []struct {
+		body        string
+		start       time.Time
+		expectedErr error
+	}{
+		{header + `<tr><td>2018-11-19</td><td>0830</td><td>AB0601</td><td>2 hrs</td></tr></table>`,
+			time.Date(2018, 11, 19, 8, 30, 0, 0, time.UTC), nil},
+		{header + `<tr><td>19-Nov-2018</td><td>12.30 pm</td><td>AB0601</td><td>2</td></tr></table>`,
+			time.Date(2018, 11, 19, 12, 30, 0, 0, time.UTC), nil},
+		{header + `<tr><td>19-Nov-2018</td><td>12 am</td><td>AB0601</td><td>2</td></tr></table>`,
+			time.Date(2018, 11, 19, 0, 0, 0, 0, time.UTC), nil},
+		{header + `<tr><td>19 Nov</td><td>9.00 am</td><td>AB0601</td><td>2</td></tr></table>`,
+			time.Time{}, parser.ErrInvalidExam},
+		{header + `<tr><td>2018-11-19</td><td>13.00 pm</td><td>AB0601</td><td>2</td></tr></table>`,
+			time.Time{}, parser.ErrInvalidExam},
+		{header + `<tr><td>2018-11-19</td><td>9.00 am</td><td>AB0601</td><td>0</td></tr></table>`,
+			time.Time{}, parser.ErrInvalidExam},
+		{header + `<tr><td>2018-11-19</td><td>9.00 am</td><td></td><td>2</td></tr></table>`,
+			time.Time{}, parser.ErrInvalidExam},
+		{header + `<tr><td>2018-11-19</td></tr></table>`, time.Time{}, parser.ErrInvalidExam},
+		{`<table><tr><th>DATE</th></tr></table>`, time.Time{}, parser.ErrCantFindExamTable},
+	}
+
+	for i, test := range cases {
+		exams, err := parser.FindExams(strings.NewReader(test.body))
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+		if err == nil && (len(exams) != 1 || !exams[0].Start.Equal(test.start)) {
+			t.Errorf("id=%d expected start=%v got=%#v", i, test.start, exams)
+		}
+	}
+}
+
+func TestFindExamsLenient(t *testing.T) {
+	body := `<table><tr><th>DATE</th><th>TIME</th><th>COURSE</th><th>DURATION</th></tr>` +
+		`<tr><td>2018-11-19</td><td>0830</td><td>AB0601</td><td>2 hrs</td></tr>` +
+		`<tr><td>19 Nov</td><td>9.00 am</td><td>AB0602</td><td>2</td></tr>` +
+		`<tr><td>2018-11-20</td><td>9.00 am</td><td></td><td>2</td></tr>` +
+		`<tr><td>2018-11-21</td><td>1.00 pm</td><td>AB0603</td><td>2.5</td></tr></table>`
+
+	exams, skipped, err := parser.FindExamsWithOptions(strings.NewReader(body), parser.ExamOptions{})
+	if !errors.Is(err, parser.ErrInvalidExam) || exams != nil || skipped != nil {
+		t.Errorf("expected=%v got=%v %v %v", parser.ErrInvalidExam, err, exams, skipped)
+	}
+
+	exams, skipped, err = parser.FindExamsWithOptions(strings.NewReader(body), parser.ExamOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, exam := range exams {
+		ids = append(ids, exam.SubjectId)
+	}
+	if !reflect.DeepEqual(ids, []string{"AB0601", "AB0603"}) {
+		t.Errorf("expected=[AB0601 AB0603] got=%v", ids)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("expected 2 skipped rows got=%v", skipped)
+	}
+	for id, row := range skipped {
+		if !errors.Is(row, parser.ErrInvalidExam) || !strings.Contains(row.Error(), fmt.Sprintf("row %d", id+1)) {
+			t.Errorf("id=%d expected=row %d got=%v", id, id+1, row)
+		}
+	}
+}
+
+func TestFindHiddenInputs(t *testing.T) {
+	inputs, err := parser.FindHiddenInputs(GetFileReader("../../testdata/exam-main.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"p_exam_yr": "2018", "p_semester": "1", "p_plan_no": "73", "p_type": "UE", "bOption": "Next",
+	}
+	if !reflect.DeepEqual(expected, inputs) {
+		t.Errorf("expected=%v got=%v", expected, inputs)
+	}
+}
@@ -0,0 +1,5 @@
+package parser
+
+// Exposes internals to the external parser_test package
+var SplitTime = splitTime
+var SplitExclusion = splitExclusion
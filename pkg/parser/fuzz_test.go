@@ -0,0 +1,269 @@
+package parser_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"html"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Seeds a fuzz target with every file in testdata
+func addFixtures(f *testing.F) {
+	for _, name := range fixtureNames(f) {
+		f.Add(readFixture(f, name))
+	}
+}
+
+func FuzzFindSchedule(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		parser.FindSchedule(bytes.NewReader(body))
+		parser.FindScheduleWithOptions(bytes.NewReader(body), parser.ScheduleOptions{Lenient: true})
+		parser.StreamSchedule(bytes.NewReader(body))
+	})
+}
+
+func FuzzFindCourses(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		courses, err := parser.FindCourses(bytes.NewReader(body))
+		if err == nil {
+			for _, c := range courses {
+				if strings.TrimSpace(c.Key) == "" {
+					t.Errorf("course with an empty key %#v", c)
+				}
+			}
+		}
+	})
+}
+
+func FuzzFindLatestAcadSem(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		sem, err := parser.FindLatestAcadSem(bytes.NewReader(body))
+		if sem == nil {
+			t.Fatalf("nil semester, err=%v", err)
+		}
+	})
+}
+
+func FuzzFindExams(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		exams, err := parser.FindExams(bytes.NewReader(body))
+		if err == nil {
+			for _, exam := range exams {
+				if exam.Duration <= 0 || exam.End().Before(exam.Start) {
+					t.Errorf("invalid exam %#v", exam)
+				}
+			}
+		}
+		parser.FindHiddenInputs(bytes.NewReader(body))
+	})
+}
+
+func FuzzSplitTime(f *testing.F) {
+	for _, seed := range []string{"0830", "1830", "2400", "2430", "0000", "", "1", "12345", "-130", "+130", "08x0"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		hour, minute, err := parser.SplitTime(s)
+		if err != nil {
+			return
+		}
+		if hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+			t.Errorf("%q: out of range %d:%d", s, hour, minute)
+		}
+		if formatted := fmt.Sprintf("%02d%02d", hour, minute); formatted != s {
+			t.Errorf("%q: round trips to %q", s, formatted)
+		}
+	})
+}
+
+// Renders subjects the way the class schedule pages lay them out
+func renderSchedule(subjects []parser.Subject) string {
+	var b strings.Builder
+	b.WriteString("<html><body>\n")
+	for _, subject := range subjects {
+		fmt.Fprintf(&b, `<table >
+<tr>
+<TD WIDTH="100"><B><FONT COLOR=#0000FF>%s</FONT></B></TD>
+<TD WIDTH="500"><B><FONT COLOR=#0000FF>%s</FONT></B></TD>
+<TD WIDTH="50"><B><FONT COLOR=#0000FF>%s</FONT></B></TD>
+</tr>
+`, html.EscapeString(subject.Id), html.EscapeString(subject.Title), html.EscapeString(subject.AuRaw))
+		details := [][2]string{{"", ""}}
+		if subject.Prerequisite != "" {
+			details = append(details, [2]string{"Prerequisite:", subject.Prerequisite})
+		}
+		if len(subject.MutuallyExclusive) > 0 {
+			details = append(details, [2]string{"Mutually exclusive with:", strings.Join(subject.MutuallyExclusive, ", ")})
+		}
+		for _, exclusion := range subject.Exclusions {
+			label := map[string]string{
+				parser.ExclusionAny:  "Not available to Programme:",
+				parser.ExclusionAll:  "Not available to all Programme with:",
+				parser.ExclusionCore: "Not available as Core to Programme:",
+				parser.ExclusionPE:   "Not available as PE to Programme:",
+				parser.ExclusionUE:   "Not available as UE to Programme:",
+			}[exclusion.As]
+			value := strings.Join(exclusion.Programmes, ", ")
+			if exclusion.Admission != "" {
+				value = strings.TrimSpace(value + " (" + exclusion.Admission + ")")
+			}
+			details = append(details, [2]string{label, value})
+		}
+		if subject.GradeType != "" {
+			details = append(details, [2]string{"Grade Type:", subject.GradeType})
+		}
+		for _, remark := range subject.Remarks {
+			details = append(details, [2]string{remark, ""})
+		}
+		for _, detail := range details {
+			fmt.Fprintf(&b, `<tr>
+<TD><B><FONT SIZE=2 COLOR=#FF00FF>%s</FONT></B></TD>
+<TD COLSPAN="2"><B><FONT  SIZE=2 COLOR=#FF00FF>%s</FONT></B></TD>
+</tr>
+`, html.EscapeString(detail[0]), html.EscapeString(detail[1]))
+		}
+		b.WriteString("</table>\n")
+
+		b.WriteString("<table  border>\n<tr>\n")
+		for _, header := range []string{"INDEX", "TYPE", "GROUP", "DAY", "TIME", "VENUE", "REMARK"} {
+			fmt.Fprintf(&b, "<th><b>%s</b></th>\n", header)
+		}
+		b.WriteString("</tr>\n")
+
+		previousIndex := ""
+		for _, s := range subject.Schedules {
+			index := s.Index
+			if index == previousIndex {
+				index = ""
+			}
+			previousIndex = s.Index
+
+			b.WriteString(`<TR BGCOLOR="#CAE2EA">` + "\n")
+			for _, cell := range []string{index, string(s.Type), s.Group, string(s.Day), s.TimeText, s.Venue, s.Remark} {
+				fmt.Fprintf(&b, "<td><b>%s</b></td>\n", html.EscapeString(cell))
+			}
+			b.WriteString("</tr>\n")
+		}
+		b.WriteString("</table>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// Text that survives rendering, trimming and unescaping unchanged
+func randomText(r *rand.Rand, max int) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 &<>/-.'\""
+	n := r.Intn(max + 1)
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// A list of codes as found in the subject detail rows, nil when empty
+func randomCodes(r *rand.Rand) []string {
+	var codes []string
+	for i := r.Intn(4); i > 0; i-- {
+		codes = append(codes, fmt.Sprintf("%c%c%04d", 'A'+r.Intn(26), 'A'+r.Intn(26), r.Intn(10000)))
+	}
+	return codes
+}
+
+func randomTimetable(r *rand.Rand) []parser.Subject {
+	days := append([]parser.Weekday{"", "TBA"}, parser.Weekdays...)
+	subjects := make([]parser.Subject, r.Intn(4))
+	for i := range subjects {
+		subject := parser.Subject{
+			Id:        randomText(r, 8),
+			Title:     randomText(r, 40),
+			AuRaw:     randomText(r, 8),
+			Schedules: make([]parser.Schedule, 0),
+		}
+		if r.Intn(2) == 0 {
+			subject.AuRaw = fmt.Sprintf("%d.%d AU", r.Intn(10), r.Intn(10))
+		}
+		subject.AU, _ = parser.ParseAU(subject.AuRaw)
+		if r.Intn(2) == 0 {
+			subject.Prerequisite = randomText(r, 30)
+			subject.MutuallyExclusive = randomCodes(r)
+			subject.GradeType = randomText(r, 10)
+			kinds := []string{parser.ExclusionAny, parser.ExclusionAll, parser.ExclusionCore,
+				parser.ExclusionPE, parser.ExclusionUE}
+			for _, kind := range kinds[:r.Intn(len(kinds)+1)] {
+				exclusion := parser.Exclusion{As: kind, Programmes: randomCodes(r)}
+				if r.Intn(2) == 0 {
+					year := 2000 + r.Intn(20)
+					exclusion.Admission = fmt.Sprintf("Admyr %d-%d", year, year+r.Intn(5))
+				}
+				if exclusion.Programmes != nil || exclusion.Admission != "" {
+					subject.Exclusions = append(subject.Exclusions, exclusion)
+				}
+			}
+			if r.Intn(2) == 0 {
+				subject.Remarks = []string{"Not offered as Unrestricted Elective"}
+			}
+		}
+		for index := 0; index < r.Intn(4); index++ {
+			indexText := fmt.Sprintf("%05d", r.Intn(100000))
+			for n := 1 + r.Intn(3); n > 0; n-- {
+				s := parser.Schedule{
+					Index:  indexText,
+					Group:  randomText(r, 4),
+					Day:    days[r.Intn(len(days))],
+					Venue:  randomText(r, 10),
+					Remark: randomText(r, 20),
+				}
+				s.Type, _ = parser.ParseSessionType(randomText(r, 10))
+				if r.Intn(5) > 0 {
+					start := r.Intn(24*60 - 1)
+					end := start + 1 + r.Intn(24*60-start)
+					s.TimeText = fmt.Sprintf("%02d%02d-%02d%02d", start/60, start%60, end/60, end%60)
+					s.TimeStart = time.Date(2018, 9, 12, start/60, start%60, 0, 0, time.UTC)
+					s.TimeEnd = time.Date(2018, 9, 12, end/60, end%60, 0, 0, time.UTC)
+				}
+				subject.Schedules = append(subject.Schedules, s)
+			}
+		}
+		subjects[i] = subject
+	}
+	return subjects
+}
+
+func FuzzScheduleRoundTrip(f *testing.F) {
+	for seed := int64(0); seed < 16; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		expected := randomTimetable(rand.New(rand.NewSource(seed)))
+		if expected == nil {
+			expected = []parser.Subject{}
+		}
+		page := renderSchedule(expected)
+
+		result, err := parser.FindSchedule(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, result) {
+			t.Fatalf("FindSchedule\nexpected=%#v\ngot=%#v\npage:\n%s", expected, result, page)
+		}
+
+		result, err = parser.StreamSchedule(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, result) {
+			t.Fatalf("StreamSchedule\nexpected=%#v\ngot=%#v\npage:\n%s", expected, result, page)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package parser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden files in testdata/golden")
+
+const goldenDir = "../../testdata/golden"
+
+// Everything the parser extracts from a page
+type golden struct {
+	Semester *parser.AcademicSemester `json:",omitempty"`
+	Courses  []parser.Course          `json:",omitempty"`
+	Subjects []parser.Subject
+	Exams    []parser.Exam `json:",omitempty"`
+}
+
+// Lists every fixture file in testdata, golden files excluded
+func fixtureNames(tb testing.TB) []string {
+	entries, err := ioutil.ReadDir("../../testdata")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func parseGolden(t *testing.T, body []byte) golden {
+	var g golden
+	if sem, err := parser.FindLatestAcadSem(bytes.NewReader(body)); err == nil {
+		g.Semester = sem
+	}
+	courses, err := parser.FindCourses(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Courses = courses
+	subjects, err := parser.FindSchedule(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Subjects = subjects
+	if exams, err := parser.FindExams(bytes.NewReader(body)); err == nil {
+		g.Exams = exams
+	}
+	return g
+}
+
+// Compares the parsed output of every fixture with its checked in JSON,
+// run with -update to regenerate them after an intended change
+func TestGolden(t *testing.T) {
+	for _, name := range fixtureNames(t) {
+		t.Run(name, func(t *testing.T) {
+			result, err := json.MarshalIndent(parseGolden(t, readFixture(t, name)), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, '\n')
+
+			path := filepath.Join(goldenDir, name+".json")
+			if *update {
+				if err := os.MkdirAll(goldenDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, result, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create it", err)
+			}
+			if !bytes.Equal(expected, result) {
+				t.Errorf("output differs from %s, run go test -update if the change is intended\ngot:\n%s", path, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidID = errors.New("parser: invalid id")
+)
+
+// Stable identifiers for courses, subjects, indexes and sessions. Unlike
+// Course.Id and Schedule.Id, which hash the contents of a record to name
+// files, these are built from the fields that identify a record within a
+// semester, so the same session has the same ID in every snapshot of the
+// semester even when its venue or remark changes. They look like
+//
+//	ntu1:2018;1:course:EEE;;2;F
+//	ntu1:2018;1:subject:CZ2001
+//	ntu1:2018;1:index:CZ2001:10101
+//	ntu1:2018;1:session:CZ2001:10101:LEC/STUDIO:1:MON:0830-0930:1
+//
+// that is the scheme and its version, the acadsem key, the kind of
+// record and its fields separated by colons. A session is identified by
+// its subject, index, type, group, day and time, with a final counter
+// telling apart sessions that share all of them, e.g. labs held on
+// alternate weeks. Colons and percent signs inside fields are escaped
+// as %3A and %25. The version changes whenever the format does.
+const IDVersion = 1
+
+const idScheme = "ntu"
+
+// Kinds of record an ID can refer to
+type IDKind string
+
+const (
+	IDCourse  IDKind = "course"
+	IDSubject IDKind = "subject"
+	IDIndex   IDKind = "index"
+	IDSession IDKind = "session"
+)
+
+// Number of fields after the kind
+var idFields = map[IDKind]int{
+	IDCourse:  1,
+	IDSubject: 1,
+	IDIndex:   2,
+	IDSession: 7,
+}
+
+// Decoded form of an ID
+type ID struct {
+	Version  int
+	Semester string
+	Kind     IDKind
+	Fields   []string
+}
+
+func (id ID) String() string {
+	parts := []string{idScheme + strconv.Itoa(id.Version), escapeIDField(id.Semester), string(id.Kind)}
+	for _, field := range id.Fields {
+		parts = append(parts, escapeIDField(field))
+	}
+	return strings.Join(parts, ":")
+}
+
+func newID(semester string, kind IDKind, fields ...string) string {
+	return ID{Version: IDVersion, Semester: semester, Kind: kind, Fields: fields}.String()
+}
+
+func CourseID(semester, courseKey string) string {
+	return newID(semester, IDCourse, courseKey)
+}
+
+func SubjectID(semester, subjectId string) string {
+	return newID(semester, IDSubject, subjectId)
+}
+
+func IndexID(semester, subjectId, index string) string {
+	return newID(semester, IDIndex, subjectId, index)
+}
+
+// n counts sessions of the index with the same type, group, day and
+// time, starting from 1
+func SessionID(semester, subjectId string, s *Schedule, n int) string {
+	return newID(semester, IDSession, subjectId, s.Index, string(s.Type), s.Group,
+		string(s.Day), s.TimeText, strconv.Itoa(n))
+}
+
+// Sets the IDs of the subject and its sessions, sessions are counted in
+// page order
+func (s *Subject) SetIDs(semester string) {
+	s.UID = SubjectID(semester, s.Id)
+	seen := make(map[string]int)
+	for i := range s.Schedules {
+		schedule := &s.Schedules[i]
+		key := SessionID(semester, s.Id, schedule, 0)
+		seen[key]++
+		schedule.UID = SessionID(semester, s.Id, schedule, seen[key])
+		schedule.IndexUID = IndexID(semester, s.Id, schedule.Index)
+	}
+}
+
+func ParseID(s string) (ID, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 || !strings.HasPrefix(parts[0], idScheme) {
+		return ID{}, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[0], idScheme))
+	if err != nil || version != IDVersion {
+		return ID{}, fmt.Errorf("%w: %q has unknown version", ErrInvalidID, s)
+	}
+	kind := IDKind(parts[2])
+	n, ok := idFields[kind]
+	if !ok || len(parts)-3 != n {
+		return ID{}, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+
+	id := ID{Version: version, Kind: kind}
+	if id.Semester, err = unescapeIDField(parts[1]); err != nil {
+		return ID{}, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	for _, part := range parts[3:] {
+		field, err := unescapeIDField(part)
+		if err != nil {
+			return ID{}, fmt.Errorf("%w: %q", ErrInvalidID, s)
+		}
+		id.Fields = append(id.Fields, field)
+	}
+	return id, nil
+}
+
+var idEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+var idUnescaper = strings.NewReplacer("%25", "%", "%3A", ":")
+
+func escapeIDField(s string) string {
+	return idEscaper.Replace(s)
+}
+
+func unescapeIDField(s string) (string, error) {
+	unescaped := idUnescaper.Replace(s)
+	if escapeIDField(unescaped) != s {
+		return "", errors.New("invalid escape")
+	}
+	return unescaped, nil
+}
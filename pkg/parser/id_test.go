@@ -0,0 +1,100 @@
+package parser_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"reflect"
+	"testing"
+)
+
+func TestIDs(t *testing.T) {
+	lecture := parser.Schedule{Index: "10101", Type: parser.Lecture, Group: "1",
+		Day: parser.Monday, TimeText: "0830-0930"}
+	cases := []struct {
+		id       string
+		expected string
+	}{
+		{parser.CourseID("2018;1", "EEE;;2;F"), "ntu1:2018;1:course:EEE;;2;F"},
+		{parser.SubjectID("2018;1", "CZ2001"), "ntu1:2018;1:subject:CZ2001"},
+		{parser.IndexID("2018;1", "CZ2001", "10101"), "ntu1:2018;1:index:CZ2001:10101"},
+		{parser.SessionID("2018;1", "CZ2001", &lecture, 1),
+			"ntu1:2018;1:session:CZ2001:10101:LEC/STUDIO:1:MON:0830-0930:1"},
+		{parser.SubjectID("", "CZ2001"), "ntu1::subject:CZ2001"},
+		{parser.CourseID("2018;1", "A:B%"), "ntu1:2018;1:course:A%3AB%25"},
+	}
+
+	for i, test := range cases {
+		if test.id != test.expected {
+			t.Errorf("id=%d expected=%s got=%s", i, test.expected, test.id)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	cases := []struct {
+		id          string
+		expected    parser.ID
+		expectedErr error
+	}{
+		{"ntu1:2018;1:course:EEE;;2;F", parser.ID{Version: 1, Semester: "2018;1",
+			Kind: parser.IDCourse, Fields: []string{"EEE;;2;F"}}, nil},
+		{"ntu1:2018;1:session:CZ2001:10101:LEC/STUDIO:1:MON:0830-0930:2", parser.ID{Version: 1,
+			Semester: "2018;1", Kind: parser.IDSession,
+			Fields: []string{"CZ2001", "10101", "LEC/STUDIO", "1", "MON", "0830-0930", "2"}}, nil},
+		{"ntu1:2018;1:course:A%3AB%25", parser.ID{Version: 1, Semester: "2018;1",
+			Kind: parser.IDCourse, Fields: []string{"A:B%"}}, nil},
+		{"ntu2:2018;1:subject:CZ2001", parser.ID{}, parser.ErrInvalidID},
+		{"abc1:2018;1:subject:CZ2001", parser.ID{}, parser.ErrInvalidID},
+		{"ntu1:2018;1:room:LT1", parser.ID{}, parser.ErrInvalidID},
+		{"ntu1:2018;1:index:CZ2001", parser.ID{}, parser.ErrInvalidID},
+		{"ntu1:2018;1:subject:CZ%2", parser.ID{}, parser.ErrInvalidID},
+		{"", parser.ID{}, parser.ErrInvalidID},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseID(test.id)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("id=%d expected=%+v got=%+v", i, test.expected, result)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+		if err == nil && result.String() != test.id {
+			t.Errorf("id=%d expected=%s got=%s", i, test.id, result.String())
+		}
+	}
+}
+
+func TestSetIDs(t *testing.T) {
+	lab := parser.Schedule{Index: "10101", Type: parser.Laboratory, Group: "1",
+		Day: parser.Tuesday, TimeText: "1030-1230"}
+	oddWeeks, evenWeeks := lab, lab
+	oddWeeks.Venue, oddWeeks.Remark = "HWLAB1", "Teaching Wk1,3,5"
+	evenWeeks.Venue, evenWeeks.Remark = "HWLAB2", "Teaching Wk2,4,6"
+	subject := parser.Subject{Id: "CZ2001", Schedules: []parser.Schedule{oddWeeks, evenWeeks}}
+
+	subject.SetIDs("2018;1")
+	expected := []string{
+		"ntu1:2018;1:session:CZ2001:10101:LAB:1:TUE:1030-1230:1",
+		"ntu1:2018;1:session:CZ2001:10101:LAB:1:TUE:1030-1230:2",
+	}
+	if subject.UID != "ntu1:2018;1:subject:CZ2001" {
+		t.Errorf("expected=%s got=%s", "ntu1:2018;1:subject:CZ2001", subject.UID)
+	}
+	for i, schedule := range subject.Schedules {
+		if schedule.UID != expected[i] {
+			t.Errorf("id=%d expected=%s got=%s", i, expected[i], schedule.UID)
+		}
+		if schedule.IndexUID != "ntu1:2018;1:index:CZ2001:10101" {
+			t.Errorf("id=%d expected=%s got=%s", i, "ntu1:2018;1:index:CZ2001:10101", schedule.IndexUID)
+		}
+	}
+
+	// A venue change keeps the ID
+	subject.Schedules[0].Venue = "HWLAB3"
+	before := subject.Schedules[0].UID
+	subject.SetIDs("2018;1")
+	if subject.Schedules[0].UID != before {
+		t.Errorf("expected=%s got=%s", before, subject.Schedules[0].UID)
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"golang.org/x/net/html"
@@ -21,17 +22,32 @@ type Course struct {
 }
 
 type Subject struct {
-	Id        string
-	Title     string
-	AuRaw     string
+	Id string
+	// Stable identifier including the semester, set by SetIDs
+	UID   string `json:",omitempty"`
+	Title string
+	AuRaw string
+	// AuRaw read as a number, not Valid when the page has something else
+	AU AcademicUnits
+	// Details from the rows below the title, empty when a page has none
+	Prerequisite      string      `json:",omitempty"`
+	MutuallyExclusive []string    `json:",omitempty"`
+	Exclusions        []Exclusion `json:",omitempty"`
+	GradeType         string      `json:",omitempty"`
+	// Detail rows with a label that is not recognised
+	Remarks   []string `json:",omitempty"`
 	Schedules []Schedule
 }
 
 type Schedule struct {
+	// Stable identifiers of the session and its index, set by
+	// Subject.SetIDs
+	UID       string `json:",omitempty"`
+	IndexUID  string `json:",omitempty"`
 	Index     string
-	Type      string
+	Type      SessionType
 	Group     string
-	Day       string
+	Day       Weekday
 	Venue     string
 	Remark    string
 	TimeText  string
@@ -39,6 +55,8 @@ type Schedule struct {
 	TimeEnd   time.Time
 }
 
+// Hash of the contents of the schedule, changes whenever any of them do.
+// See SessionID for an identifier that stays the same across snapshots.
 func (s *Schedule) Id() uint64 {
 	hasher := fnv.New64()
 
@@ -52,6 +70,7 @@ func (s *Schedule) Id() uint64 {
 	return hasher.Sum64()
 }
 
+// Hash of the course key, used to name the course page of a snapshot
 func (c *Course) Id() uint64 {
 	hasher := fnv.New64()
 
@@ -74,8 +93,41 @@ var (
 	ErrCantFindAttribute     = errors.New("parser: cannot find attribute")
 	ErrCantFindAcadSem       = errors.New("parser: cannot find academic semester")
 	ErrCantFindScheduleTable = errors.New("parser: cannot find tables matching schedule signature")
+	ErrInvalidTime           = errors.New("parser: invalid time")
+	ErrMissingColumns        = errors.New("parser: schedule row is missing columns")
 )
 
+// Locates a problem in the schedule table of a course page. Course is
+// the course of the page when the caller knows it, Subject is the
+// subject the table belongs to, Table counts every <table> in the page,
+// Row counts the data rows of that table and Column the cells of that
+// row, all starting from 0. Err is the reason.
+type ScheduleError struct {
+	Course  string
+	Subject string
+	Table   int
+	Row     int
+	Column  int
+	Err     error
+}
+
+func (e *ScheduleError) Error() string {
+	column := strconv.Itoa(e.Column)
+	if e.Column >= 0 && e.Column < len(headerSequence) {
+		column = headerSequence[e.Column]
+	}
+	course := ""
+	if e.Course != "" {
+		course = fmt.Sprintf("course %q ", e.Course)
+	}
+	return fmt.Sprintf("parser: %ssubject %q table %d row %d column %s: %v",
+		course, e.Subject, e.Table, e.Row, column, e.Err)
+}
+
+func (e *ScheduleError) Unwrap() error {
+	return e.Err
+}
+
 const (
 	AcadSemNameKey = "acadsem"
 	CoursesNameKey = "r_course_yr"
@@ -137,7 +189,7 @@ func FindLatestAcadSem(body io.Reader) (*AcademicSemester, error) {
 		optionNodes := TraverseNodes(node, optionMatcher)
 		for _, node := range optionNodes {
 
-			text := node.FirstChild.Data
+			text := firstText(node)
 			value, err := FindAttribute(node.Attr, "value")
 			if err != nil {
 				return &AcademicSemester{}, err
@@ -174,7 +226,7 @@ func FindCourses(body io.Reader) ([]Course, error) {
 	for _, node := range selectNodes {
 		optionNodes := TraverseNodes(node, optionMatcher)
 		for _, node := range optionNodes {
-			text := node.FirstChild.Data
+			text := firstText(node)
 			key, err := FindAttribute(node.Attr, "value")
 			if err != nil {
 				return courses, ErrCantFindAttribute
@@ -195,95 +247,173 @@ func lessonTrMatcher(n *html.Node) (keep bool, exit bool) {
 	return
 }
 
+var headerSequence = []string{"INDEX", "TYPE", "GROUP", "DAY", "TIME", "VENUE", "REMARK"}
+
+// Returns the data of the first child of n, empty if it has none
+func firstText(n *html.Node) string {
+	if n.FirstChild == nil {
+		return ""
+	}
+	return n.FirstChild.Data
+}
+
+// Returns the trimmed text inside n and whether n contained any text
+// node at all, even a blank one
+func nodeText(n *html.Node) (string, bool) {
+	var b strings.Builder
+	found := false
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			found = true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.TrimSpace(b.String()), found
+}
+
+func isScheduleHeader(headers []string) bool {
+	if len(headers) != len(headerSequence) {
+		return false
+	}
+	for i, header := range headers {
+		if header != headerSequence[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // Accepts a html.Node containing a table
 func canParseSchedule(n *html.Node) bool {
 	headerMatcher := func(n *html.Node) (keep bool, exit bool) {
-		keep = n.DataAtom == atom.Th
+		keep = n.Type == html.ElementNode && n.DataAtom == atom.Th
 		return
 	}
 
-	headerSequence := []string{"INDEX", "TYPE", "GROUP", "DAY", "TIME", "VENUE", "REMARK"}
-	matchSequence := make([]bool, len(headerSequence))
-	headers := TraverseNodes(n, headerMatcher)
-
-	for i, header := range headers {
-		headerText := header.FirstChild.FirstChild.Data
-		if headerText == headerSequence[i] {
-			matchSequence[i] = true
-		}
+	var headers []string
+	for _, header := range TraverseNodes(n, headerMatcher) {
+		text, _ := nodeText(header)
+		headers = append(headers, text)
 	}
+	return isScheduleHeader(headers)
+}
 
-	for _, m := range matchSequence {
-		if !m {
-			return false
+// Returns the <td> and <th> children of a row
+func rowCells(row *html.Node) []*html.Node {
+	var cells []*html.Node
+	for c := row.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+			cells = append(cells, c)
 		}
 	}
-
-	return true
+	return cells
 }
 
-func parseSchedule(n *html.Node) ([]Schedule, error) {
+// Parses the data rows of a schedule table. Rows that fail are returned
+// as errors, in lenient mode they are skipped and parsing carries on,
+// otherwise parsing stops at the first one.
+func parseSchedule(n *html.Node, table int, subjectId string, opts ScheduleOptions) ([]Schedule, []*ScheduleError) {
 	rows := TraverseNodes(n, lessonTrMatcher)
 	schedules := make([]Schedule, 0)
+	if len(rows) == 0 {
+		return schedules, nil
+	}
 
+	var errs []*ScheduleError
 	dataRows := rows[1:]
 	var cachedIndex string
-	for _, row := range dataRows {
-		var schedule Schedule
-		for i, td := 0, row.FirstChild.NextSibling; td != nil; i, td = i+1, td.NextSibling.NextSibling {
-			node := td.FirstChild.FirstChild
-			var text string
-			if node != nil {
-				text = strings.TrimSpace(node.Data)
+	for r, row := range dataRows {
+		cells := rowCells(row)
+		texts := make([]string, len(cells))
+		hasIndex := false
+		for i, cell := range cells {
+			var found bool
+			texts[i], found = nodeText(cell)
+			if i == 0 {
+				hasIndex = found
 			}
-			switch i {
-			case 0:
-				if node != nil {
-					cachedIndex = text
-				}
-				schedule.Index = cachedIndex
-				break
-			case 1:
-				schedule.Type = text
-				break
-			case 2:
-				schedule.Group = text
-				break
-			case 3:
-				schedule.Day = text
-				break
-			case 4:
-				schedule.TimeText = text
-				if len(schedule.TimeText) <= 0 {
-					break
-				}
-				timeText := strings.Split(schedule.TimeText, "-")
-				startHour, startMinute, err := splitTime(timeText[0])
-				if err != nil {
-					return nil, err
-				}
-				schedule.TimeStart = time.Date(2018, 9, 12, startHour, startMinute, 0, 0, time.UTC)
-
-				endHour, endMinute, err := splitTime(timeText[1])
-				if err != nil {
-					return nil, err
-				}
-				schedule.TimeEnd = time.Date(2018, 9, 12, endHour, endMinute, 0, 0, time.UTC)
-
-				break
-			case 5:
-				schedule.Venue = text
-				break
-			case 6:
-				schedule.Remark = text
-				break
-			default:
-				fmt.Printf("unhandled index: %d\n", i)
+		}
+
+		schedule, column, err := parseScheduleRow(texts, hasIndex, &cachedIndex)
+		if err != nil {
+			errs = append(errs, &ScheduleError{
+				Course: opts.Course, Subject: subjectId, Table: table, Row: r, Column: column, Err: err,
+			})
+			if !opts.Lenient {
+				return nil, errs
 			}
+			continue
 		}
 		schedules = append(schedules, schedule)
 	}
-	return schedules, nil
+	return schedules, errs
+}
+
+// Converts the cell texts of a schedule table row. The index cell is
+// only filled on the first row of every index so the last index seen is
+// kept in cachedIndex. On failure the offending column is returned.
+func parseScheduleRow(cells []string, hasIndex bool, cachedIndex *string) (Schedule, int, error) {
+	var schedule Schedule
+	if len(cells) > 0 && hasIndex {
+		*cachedIndex = cells[0]
+	}
+	schedule.Index = *cachedIndex
+
+	if len(cells) < len(headerSequence) {
+		return schedule, len(cells), fmt.Errorf("%w: expected %d got %d",
+			ErrMissingColumns, len(headerSequence), len(cells))
+	}
+	for i := 1; i < len(headerSequence); i++ {
+		if err := setScheduleColumn(&schedule, i, cells[i]); err != nil {
+			return schedule, i, err
+		}
+	}
+	return schedule, -1, nil
+}
+
+// Fills the field of schedule at column i of a schedule table, the
+// index column is handled by callers as it is only set on the first
+// row of every index
+func setScheduleColumn(schedule *Schedule, i int, text string) error {
+	switch i {
+	case 1:
+		// Unknown values are kept as they are
+		schedule.Type, _ = ParseSessionType(text)
+	case 2:
+		schedule.Group = text
+	case 3:
+		schedule.Day, _ = ParseWeekday(text)
+	case 4:
+		schedule.TimeText = text
+		if len(schedule.TimeText) <= 0 {
+			break
+		}
+		timeText := strings.Split(schedule.TimeText, "-")
+		if len(timeText) != 2 {
+			return fmt.Errorf("%w: %q", ErrInvalidTime, text)
+		}
+		startHour, startMinute, err := splitTime(timeText[0])
+		if err != nil {
+			return err
+		}
+		schedule.TimeStart = time.Date(2018, 9, 12, startHour, startMinute, 0, 0, time.UTC)
+
+		endHour, endMinute, err := splitTime(timeText[1])
+		if err != nil {
+			return err
+		}
+		schedule.TimeEnd = time.Date(2018, 9, 12, endHour, endMinute, 0, 0, time.UTC)
+	case 5:
+		schedule.Venue = text
+	case 6:
+		schedule.Remark = text
+	}
+	return nil
 }
 
 func canParseSubject(n *html.Node) bool {
@@ -292,70 +422,84 @@ func canParseSubject(n *html.Node) bool {
 }
 
 func parseSubject(n *html.Node) (Subject, error) {
-	rows := TraverseNodes(n, lessonTrMatcher)
-	row := rows[0]
-
 	tdMatcher := func(n *html.Node) (keep bool, exit bool) {
-		keep = n.DataAtom == atom.Td
+		keep = n.Type == html.ElementNode && n.DataAtom == atom.Td
 		return
 	}
 
-	var subject Subject
-	cols := TraverseNodes(row, tdMatcher)
-	for i, col := range cols {
-		text := strings.TrimSpace(col.FirstChild.FirstChild.FirstChild.Data)
-		switch i {
-		case 0:
-			subject.Id = text
-			break
-		case 1:
-			subject.Title = text
-			break
-		case 2:
-			subject.AuRaw = text
-			break
+	var rows [][]string
+	for _, row := range TraverseNodes(n, lessonTrMatcher) {
+		var cells []string
+		for _, col := range TraverseNodes(row, tdMatcher) {
+			text, _ := nodeText(col)
+			cells = append(cells, text)
 		}
+		rows = append(rows, cells)
 	}
+	return subjectFromRows(rows), nil
+}
 
-	return subject, nil
+type ScheduleOptions struct {
+	// Skip schedule rows that cannot be parsed instead of failing the
+	// whole page, the skipped rows are returned as errors
+	Lenient bool
+	// Course of the page, only used to locate ScheduleErrors
+	Course string
 }
 
 func FindSchedule(body io.Reader) ([]Subject, error) {
+	subjects, _, err := FindScheduleWithOptions(body, ScheduleOptions{})
+	return subjects, err
+}
+
+// Like FindSchedule, also returning the rows skipped in lenient mode.
+// Without lenient mode the first bad row is returned as the error.
+func FindScheduleWithOptions(body io.Reader, opts ScheduleOptions) ([]Subject, []*ScheduleError, error) {
 	doc, err := html.Parse(body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	tableMatcher := func(n *html.Node) (keep bool, exit bool) {
-		keep = n.DataAtom == atom.Table
+		keep = n.Type == html.ElementNode && n.DataAtom == atom.Table
 		return
 	}
 
 	subjects := make([]Subject, 0)
+	var skipped []*ScheduleError
 
 	tables := TraverseNodes(doc, tableMatcher)
 	var subject Subject
-	for _, table := range tables {
+	for i, table := range tables {
 		if canParseSchedule(table) {
-			schedules, err := parseSchedule(table)
-			if err != nil {
-				return nil, err
+			schedules, errs := parseSchedule(table, i, subject.Id, opts)
+			if len(errs) > 0 && !opts.Lenient {
+				return nil, nil, errs[0]
 			}
+			skipped = append(skipped, errs...)
 			subject.Schedules = schedules
 			subjects = append(subjects, subject)
 		} else if canParseSubject(table) {
 			subject, err = parseSubject(table)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
-	return subjects, nil
+	return subjects, skipped, nil
 }
 
 // Splits time from a 24 hour format into hours and minutes
 // EG: 1600 -> 16 00
 func splitTime(s string) (int, int, error) {
+	if len(s) != 4 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+		}
+	}
 	hourPart, err := strconv.Atoi(s[:2])
 	if err != nil {
 		return 0, 0, err
@@ -364,6 +508,10 @@ func splitTime(s string) (int, int, error) {
 	if err != nil {
 		return 0, 0, err
 	}
+	// 2400 is the end of the day, any later is not a time
+	if hourPart > 24 || minutePart > 59 || (hourPart == 24 && minutePart != 0) {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
 	return hourPart, minutePart, nil
 }
 
@@ -375,3 +523,45 @@ func FindAttribute(attrs []html.Attribute, attr string) (string, error) {
 	}
 	return "", ErrCantFindAttribute
 }
+
+// Removes the subjects that drop returns true for from a course page,
+// along with their schedule tables, and renders the rest of the page
+func DropSubjects(body io.Reader, drop func(id string) bool) ([]byte, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	tableMatcher := func(n *html.Node) (keep bool, exit bool) {
+		keep = n.Type == html.ElementNode && n.DataAtom == atom.Table
+		return
+	}
+
+	var dropping bool
+	for _, table := range TraverseNodes(doc, tableMatcher) {
+		if canParseSchedule(table) {
+			if !dropping {
+				continue
+			}
+		} else if canParseSubject(table) {
+			subject, err := parseSubject(table)
+			if err != nil {
+				return nil, err
+			}
+			if dropping = drop(subject.Id); !dropping {
+				continue
+			}
+		} else {
+			continue
+		}
+		if table.Parent != nil {
+			table.Parent.RemoveChild(table)
+		}
+	}
+
+	var page bytes.Buffer
+	if err := html.Render(&page, doc); err != nil {
+		return nil, err
+	}
+	return page.Bytes(), nil
+}
@@ -1,10 +1,14 @@
 package parser_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/jaxsax/ntu-room-finder/pkg/parser"
 	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -187,3 +191,256 @@ func TestSchedule(t *testing.T) {
 		}
 	}
 }
+
+const scheduleHeader = `<table><tr>
+<th><b>INDEX</b></th><th><b>TYPE</b></th><th><b>GROUP</b></th><th><b>DAY</b></th>
+<th><b>TIME</b></th><th><b>VENUE</b></th><th><b>REMARK</b></th></tr>`
+
+const subjectHeader = `<table><tr><td><b><font>AB0601</font></b></td><td>TITLE</td><td>2.0 AU</td></tr><tr></tr></table>`
+
+func TestMalformedPagesDoNotPanic(t *testing.T) {
+	// Pages without a semester or course list find nothing and schedule
+	// tables with broken rows fail, or skip the row when lenient
+	cases := []struct {
+		body        string
+		semester    bool
+		courses     int
+		scheduleErr error
+	}{
+		{`<select name=acadsem><option selected value=a></option></select>`, true, 0, nil},
+		{`<select name=r_course_yr><option value=a></option></select>`, false, 1, nil},
+		{`<table><tr><th></th><th></th><th></th><th></th><th></th><th></th><th></th><th></th><th></th></tr></table>`,
+			false, 0, nil},
+		{`<table><tr><th>INDEX</th></tr><tr><td></td></tr></table>`, false, 0, nil},
+		{`<table><tr><td></td></tr><tr></tr></table>`, false, 0, nil},
+		{`<table><tr></tr><tr></tr></table>`, false, 0, nil},
+		{scheduleHeader + `<tr></tr></table>`, false, 0, parser.ErrMissingColumns},
+		{scheduleHeader + `<tr><td></td><td></td><td></td><td></td><td>1</td><td></td><td></td></tr></table>`,
+			false, 0, parser.ErrInvalidTime},
+		{scheduleHeader + `<tr><td></td><td></td><td></td><td></td><td>1830-</td><td></td><td></td></tr></table>`,
+			false, 0, parser.ErrInvalidTime},
+		{scheduleHeader + `<tr><td></td><td></td><td></td><td></td><td>-1-2-3</td><td></td><td></td></tr></table>`,
+			false, 0, parser.ErrInvalidTime},
+		{scheduleHeader + `<tr>text<td><b></b></td></tr></table>`, false, 0, parser.ErrMissingColumns},
+	}
+
+	countSchedules := func(subjects []parser.Subject) int {
+		n := 0
+		for _, subject := range subjects {
+			n += len(subject.Schedules)
+		}
+		return n
+	}
+	for id, c := range cases {
+		if _, err := parser.FindLatestAcadSem(strings.NewReader(c.body)); (err == nil) != c.semester {
+			t.Errorf("id=%d expected semester=%v got=%v", id, c.semester, err)
+		}
+		courses, err := parser.FindCourses(strings.NewReader(c.body))
+		if err != nil || len(courses) != c.courses {
+			t.Errorf("id=%d expected=%d courses got=%v %v", id, c.courses, courses, err)
+		}
+
+		subjects, err := parser.FindSchedule(strings.NewReader(c.body))
+		if !errors.Is(err, c.scheduleErr) || countSchedules(subjects) != 0 {
+			t.Errorf("id=%d expected=%v got=%v %+v", id, c.scheduleErr, err, subjects)
+		}
+		streamed, err := parser.StreamSchedule(strings.NewReader(c.body))
+		if !errors.Is(err, c.scheduleErr) || countSchedules(streamed) != 0 {
+			t.Errorf("id=%d expected stream=%v got=%v %+v", id, c.scheduleErr, err, streamed)
+		}
+
+		// Lenient parsing never fails on a bad row, it is skipped instead
+		expectedSkipped := 0
+		if c.scheduleErr != nil {
+			expectedSkipped = 1
+		}
+		subjects, skipped, err := parser.FindScheduleWithOptions(strings.NewReader(c.body),
+			parser.ScheduleOptions{Lenient: true})
+		if err != nil || countSchedules(subjects) != 0 || len(skipped) != expectedSkipped {
+			t.Errorf("id=%d expected lenient skipped=%d got=%v %v %+v", id, expectedSkipped, skipped, err, subjects)
+		}
+		for _, row := range skipped {
+			if !errors.Is(row, c.scheduleErr) {
+				t.Errorf("id=%d expected skipped=%v got=%v", id, c.scheduleErr, row)
+			}
+		}
+	}
+}
+
+func TestScheduleErrorPosition(t *testing.T) {
+	body := subjectHeader + scheduleHeader + `
+<tr><td>1</td><td>LEC</td><td>1</td><td>MON</td><td>0830-0930</td><td>LT1</td><td></td></tr>
+<tr><td></td><td>TUT</td><td>1</td><td>TUE</td><td>0830-09</td><td>TR1</td><td></td></tr>
+<tr><td></td><td>TUT</td><td>1</td><td>TUE</td></tr>
+<tr><td>2</td><td>LEC</td><td>1</td><td>WED</td><td>1030-1130</td><td>LT1</td><td></td></tr>
+</table>`
+
+	_, err := parser.FindSchedule(strings.NewReader(body))
+	scheduleErr, ok := err.(*parser.ScheduleError)
+	if !ok {
+		t.Fatalf("expected ScheduleError got=%v", err)
+	}
+	expected := parser.ScheduleError{Subject: "AB0601", Table: 1, Row: 1, Column: 4}
+	if scheduleErr.Subject != expected.Subject || scheduleErr.Table != expected.Table ||
+		scheduleErr.Row != expected.Row || scheduleErr.Column != expected.Column {
+		t.Errorf("expected=%+v got=%+v", expected, *scheduleErr)
+	}
+	if !errors.Is(err, parser.ErrInvalidTime) {
+		t.Errorf("expected=%s got=%s", parser.ErrInvalidTime, err)
+	}
+
+	subjects, skipped, err := parser.FindScheduleWithOptions(strings.NewReader(body),
+		parser.ScheduleOptions{Lenient: true, Course: "ACC 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) > 0 && (skipped[0].Course != "ACC 1" || !strings.Contains(skipped[0].Error(), `course "ACC 1"`)) {
+		t.Errorf("expected course=ACC 1 got=%v", skipped[0])
+	}
+	if len(subjects) != 1 || len(subjects[0].Schedules) != 2 {
+		t.Fatalf("expected 2 schedules got=%#v", subjects)
+	}
+	if subjects[0].Schedules[1].Index != "2" {
+		t.Errorf("expected index=2 got=%q", subjects[0].Schedules[1].Index)
+	}
+	if len(skipped) != 2 || skipped[0].Row != 1 || skipped[1].Row != 2 ||
+		!errors.Is(skipped[1], parser.ErrMissingColumns) || skipped[1].Column != 4 {
+		t.Errorf("unexpected skipped rows %v", skipped)
+	}
+
+	stream := parser.NewScheduleStreamWithOptions(strings.NewReader(body),
+		parser.ScheduleOptions{Lenient: true, Course: "ACC 1"})
+	subject, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(subject, subjects[0]) || !reflect.DeepEqual(stream.Skipped(), skipped) {
+		t.Errorf("stream expected=%#v %v got=%#v %v", subjects[0], skipped, subject, stream.Skipped())
+	}
+}
+
+func TestSplitTime(t *testing.T) {
+	cases := []struct {
+		text           string
+		hour, minute   int
+		expectedToFail bool
+	}{
+		{"0830", 8, 30, false},
+		{"0000", 0, 0, false},
+		{"2359", 23, 59, false},
+		{"2400", 24, 0, false},
+		{"2401", 0, 0, true},
+		{"2430", 0, 0, true},
+		{"2459", 0, 0, true},
+		{"2500", 0, 0, true},
+		{"0860", 0, 0, true},
+	}
+
+	for id, c := range cases {
+		hour, minute, err := parser.SplitTime(c.text)
+		if c.expectedToFail {
+			if !errors.Is(err, parser.ErrInvalidTime) {
+				t.Errorf("id=%d expected=%s got=%v", id, parser.ErrInvalidTime, err)
+			}
+			continue
+		}
+		if err != nil || hour != c.hour || minute != c.minute {
+			t.Errorf("id=%d expected=%d:%d got=%d:%d %v", id, c.hour, c.minute, hour, minute, err)
+		}
+	}
+}
+
+func TestSplitExclusion(t *testing.T) {
+	cases := []struct {
+		value      string
+		programmes []string
+		admission  string
+	}{
+		{"BCE, BCG", []string{"BCE", "BCG"}, ""},
+		{"(Admyr 2004-2010)", nil, "Admyr 2004-2010"},
+		{"CE, EEE(Admyr 2011-2015)", []string{"CE", "EEE"}, "Admyr 2011-2015"},
+		{"CE (Admyr 2011) , EEE (Admyr 2012)", []string{"CE", "EEE"}, "Admyr 2011, Admyr 2012"},
+		{"()", nil, ""},
+	}
+	for id, c := range cases {
+		programmes, admission := parser.SplitExclusion(c.value)
+		if !reflect.DeepEqual(programmes, c.programmes) || admission != c.admission {
+			t.Errorf("id=%d expected=%v %q got=%v %q", id, c.programmes, c.admission, programmes, admission)
+		}
+	}
+}
+
+func TestSubjectDetails(t *testing.T) {
+	subjects, err := parser.FindSchedule(GetFileReader("../../testdata/subject-with-details.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subjects) != 1 {
+		t.Fatalf("expected 1 subject got=%d", len(subjects))
+	}
+	subject := subjects[0]
+	subject.Schedules = nil
+
+	expected := parser.Subject{
+		Id:                "CZ2001",
+		Title:             "ALGORITHMS",
+		AuRaw:             "3.0 AU",
+		AU:                parser.AcademicUnits{Min: 3, Max: 3, Valid: true},
+		Prerequisite:      "CZ1007 OR CE1007",
+		MutuallyExclusive: []string{"CE2101", "CZ2101"},
+		Exclusions: []parser.Exclusion{
+			{As: parser.ExclusionAny, Programmes: []string{"BCE", "BCG", "CE", "CPE"}},
+			{As: parser.ExclusionPE, Programmes: []string{"EEE"}},
+			{As: parser.ExclusionAll, Admission: "Admyr 2004-2010"},
+		},
+		GradeType: "Pass/Fail",
+		Remarks:   []string{"Not offered as Unrestricted Elective"},
+	}
+	if !reflect.DeepEqual(expected, subject) {
+		t.Errorf("expected=%#v got=%#v", expected, subject)
+	}
+}
+
+func TestDropSubjects(t *testing.T) {
+	var page bytes.Buffer
+	// The second page ends in a broken tag so it has to go last
+	for _, name := range []string{"subject-with-details.html", "schedule-with-subject.html"} {
+		body, err := ioutil.ReadFile("../../testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page.Write(body)
+	}
+
+	cases := []struct {
+		drop     map[string]bool
+		expected []string
+	}{
+		{nil, []string{"CZ2001", "AB0601"}},
+		{map[string]bool{"AB0601": true}, []string{"CZ2001"}},
+		{map[string]bool{"CZ2001": true}, []string{"AB0601"}},
+		{map[string]bool{"AB0601": true, "CZ2001": true}, nil},
+	}
+	for id, c := range cases {
+		body, err := parser.DropSubjects(bytes.NewReader(page.Bytes()), func(id string) bool {
+			return c.drop[id]
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects, err := parser.FindSchedule(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, subject := range subjects {
+			ids = append(ids, subject.Id)
+			if len(subject.Schedules) == 0 {
+				t.Errorf("id=%d expected sessions for %s", id, subject.Id)
+			}
+		}
+		if !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("id=%d expected=%v got=%v", id, c.expected, ids)
+		}
+	}
+}
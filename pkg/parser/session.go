@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownWeekday     = errors.New("parser: unknown weekday")
+	ErrUnknownSessionType = errors.New("parser: unknown session type")
+)
+
+// Day of a session as written in the DAY column. Values that are not
+// one of the constants below are kept as they appear on the page.
+type Weekday string
+
+const (
+	Monday    Weekday = "MON"
+	Tuesday   Weekday = "TUE"
+	Wednesday Weekday = "WED"
+	Thursday  Weekday = "THU"
+	Friday    Weekday = "FRI"
+	Saturday  Weekday = "SAT"
+	Sunday    Weekday = "SUN"
+)
+
+// Every known weekday, Monday first
+var Weekdays = []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday}
+
+// Accepts abbreviations like "wed" and full names like "Wednesday". An
+// unknown day is returned unchanged along with ErrUnknownWeekday.
+func ParseWeekday(s string) (Weekday, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	for _, day := range Weekdays {
+		if text == string(day) || (len(text) > 3 && strings.HasPrefix(text, string(day)) &&
+			strings.HasSuffix(text, "DAY")) {
+			return day, nil
+		}
+	}
+	return Weekday(s), fmt.Errorf("%w: %q", ErrUnknownWeekday, s)
+}
+
+// Converts from the time package, where the week starts on Sunday
+func WeekdayOf(day time.Weekday) Weekday {
+	return Weekdays[(int(day)+6)%7]
+}
+
+// ISO day number, 1 for Monday to 7 for Sunday and 0 when unknown
+func (d Weekday) Number() int {
+	for i, day := range Weekdays {
+		if d == day {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (d Weekday) Valid() bool {
+	return d.Number() != 0
+}
+
+// Orders days from Monday to Sunday, unknown days go last
+func (d Weekday) Before(o Weekday) bool {
+	a, b := d.Number(), o.Number()
+	if a == 0 {
+		a = len(Weekdays) + 1
+	}
+	if b == 0 {
+		b = len(Weekdays) + 1
+	}
+	if a != b {
+		return a < b
+	}
+	return d < o
+}
+
+// Kind of session as written in the TYPE column. Values that are not one
+// of the constants below are kept as they appear on the page.
+type SessionType string
+
+const (
+	Lecture    SessionType = "LEC/STUDIO"
+	Tutorial   SessionType = "TUT"
+	Laboratory SessionType = "LAB"
+	Seminar    SessionType = "SEM"
+)
+
+// Every known session type in the order they are listed
+var SessionTypes = []SessionType{Lecture, Tutorial, Laboratory, Seminar}
+
+// Accepts the TYPE column text in any case, "LEC" is read as a lecture.
+// An unknown type is returned unchanged along with ErrUnknownSessionType.
+func ParseSessionType(s string) (SessionType, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	if text == "LEC" {
+		return Lecture, nil
+	}
+	for _, t := range SessionTypes {
+		if text == string(t) {
+			return t, nil
+		}
+	}
+	return SessionType(s), fmt.Errorf("%w: %q", ErrUnknownSessionType, s)
+}
+
+// Position in SessionTypes, len(SessionTypes) when unknown
+func (t SessionType) rank() int {
+	for i, known := range SessionTypes {
+		if t == known {
+			return i
+		}
+	}
+	return len(SessionTypes)
+}
+
+func (t SessionType) Valid() bool {
+	return t.rank() < len(SessionTypes)
+}
+
+// Orders lectures before tutorials, labs and seminars, unknown types go
+// last in alphabetical order
+func (t SessionType) Before(o SessionType) bool {
+	a, b := t.rank(), o.rank()
+	if a != b {
+		return a < b
+	}
+	return t < o
+}
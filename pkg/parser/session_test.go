@@ -0,0 +1,94 @@
+package parser_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseWeekday(t *testing.T) {
+	cases := []struct {
+		text        string
+		expected    parser.Weekday
+		expectedErr error
+	}{
+		{"WED", parser.Wednesday, nil},
+		{" wed ", parser.Wednesday, nil},
+		{"Wednesday", parser.Wednesday, nil},
+		{"SUNDAY", parser.Sunday, nil},
+		{"WEDNES", "WEDNES", parser.ErrUnknownWeekday},
+		{"", "", parser.ErrUnknownWeekday},
+		{"Tba", "Tba", parser.ErrUnknownWeekday},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseWeekday(test.text)
+		if result != test.expected {
+			t.Errorf("id=%d expected=%q got=%q", i, test.expected, result)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
+
+func TestWeekdayOrder(t *testing.T) {
+	days := []parser.Weekday{"TBA", parser.Sunday, parser.Wednesday, "", parser.Monday}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	expected := []parser.Weekday{parser.Monday, parser.Wednesday, parser.Sunday, "", "TBA"}
+	for i := range expected {
+		if days[i] != expected[i] {
+			t.Fatalf("expected=%q got=%q", expected, days)
+		}
+	}
+	if parser.WeekdayOf(time.Sunday) != parser.Sunday || parser.WeekdayOf(time.Monday) != parser.Monday {
+		t.Errorf("WeekdayOf does not follow the time package")
+	}
+	if parser.Monday.Number() != 1 || parser.Sunday.Number() != 7 || parser.Weekday("X").Number() != 0 {
+		t.Errorf("unexpected day numbers")
+	}
+}
+
+func TestParseSessionType(t *testing.T) {
+	cases := []struct {
+		text        string
+		expected    parser.SessionType
+		expectedErr error
+	}{
+		{"LEC/STUDIO", parser.Lecture, nil},
+		{"lec", parser.Lecture, nil},
+		{"TUT", parser.Tutorial, nil},
+		{" lab", parser.Laboratory, nil},
+		{"SEM", parser.Seminar, nil},
+		{"PRJ", "PRJ", parser.ErrUnknownSessionType},
+		{"", "", parser.ErrUnknownSessionType},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseSessionType(test.text)
+		if result != test.expected {
+			t.Errorf("id=%d expected=%q got=%q", i, test.expected, result)
+		}
+		if result.Valid() != (test.expectedErr == nil) {
+			t.Errorf("id=%d expected valid=%v", i, test.expectedErr == nil)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
+
+func TestSessionTypeOrder(t *testing.T) {
+	types := []parser.SessionType{"PRJ", parser.Seminar, "DES", parser.Tutorial, parser.Lecture}
+	sort.Slice(types, func(i, j int) bool { return types[i].Before(types[j]) })
+
+	expected := []parser.SessionType{parser.Lecture, parser.Tutorial, parser.Seminar, "DES", "PRJ"}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("expected=%q got=%q", expected, types)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"strings"
+)
+
+// Reads course pages token by token with html.Tokenizer instead of
+// building a DOM like FindSchedule, handing out every subject as soon
+// as its schedule table is closed.
+type ScheduleStream struct {
+	z       *html.Tokenizer
+	opts    ScheduleOptions
+	tables  []*streamTable
+	count   int
+	subject Subject
+	ready   []Subject
+	skipped []*ScheduleError
+	err     error
+	done    bool
+}
+
+// State of a <table> that is still open
+type streamTable struct {
+	index int
+	// Number of <tr> seen so far
+	rows  int
+	inRow bool
+	// Text of every <th>, decides if this is a schedule table
+	headers    []string
+	isSchedule bool
+	// Cells of every row of a subject table
+	subjectRows [][]string
+
+	cells        []string
+	firstHasText bool
+	cellText     strings.Builder
+	cellHasText  bool
+	inCell       bool
+	inHeader     bool
+
+	cachedIndex string
+	schedules   []Schedule
+}
+
+func NewScheduleStream(body io.Reader) *ScheduleStream {
+	return NewScheduleStreamWithOptions(body, ScheduleOptions{})
+}
+
+func NewScheduleStreamWithOptions(body io.Reader, opts ScheduleOptions) *ScheduleStream {
+	return &ScheduleStream{z: html.NewTokenizer(body), opts: opts}
+}
+
+// Returns the rows skipped so far in lenient mode
+func (s *ScheduleStream) Skipped() []*ScheduleError {
+	return s.skipped
+}
+
+// Returns the next subject along with its schedules, io.EOF once the
+// page has been read
+func (s *ScheduleStream) Next() (Subject, error) {
+	for len(s.ready) == 0 {
+		if s.err != nil {
+			return Subject{}, s.err
+		}
+		if s.done {
+			return Subject{}, io.EOF
+		}
+		s.step()
+	}
+
+	subject := s.ready[0]
+	s.ready = s.ready[1:]
+	return subject, nil
+}
+
+func (s *ScheduleStream) current() *streamTable {
+	if len(s.tables) == 0 {
+		return nil
+	}
+	return s.tables[len(s.tables)-1]
+}
+
+func (s *ScheduleStream) step() {
+	tt := s.z.Next()
+	t := s.current()
+
+	switch tt {
+	case html.ErrorToken:
+		if s.z.Err() != io.EOF {
+			s.err = s.z.Err()
+			return
+		}
+		// Like html.Parse, tables left open at the end of the page are closed
+		for len(s.tables) > 0 {
+			s.closeTable()
+		}
+		s.done = true
+	case html.StartTagToken, html.SelfClosingTagToken:
+		name, _ := s.z.TagName()
+		switch atom.Lookup(name) {
+		case atom.Table:
+			s.tables = append(s.tables, &streamTable{index: s.count})
+			s.count++
+		case atom.Tr:
+			if t != nil {
+				s.endRow(t)
+				t.rows++
+				t.inRow = true
+			}
+		case atom.Td, atom.Th:
+			if t != nil {
+				t.endCell()
+				t.inCell = true
+				t.inHeader = atom.Lookup(name) == atom.Th
+			}
+		}
+	case html.EndTagToken:
+		name, _ := s.z.TagName()
+		switch atom.Lookup(name) {
+		case atom.Table:
+			if t != nil {
+				s.closeTable()
+			}
+		case atom.Tr:
+			if t != nil {
+				s.endRow(t)
+			}
+		case atom.Td, atom.Th:
+			if t != nil {
+				t.endCell()
+			}
+		}
+	case html.TextToken:
+		if t != nil && t.inCell {
+			t.cellText.Write(s.z.Text())
+			t.cellHasText = true
+		}
+	}
+}
+
+func (t *streamTable) endCell() {
+	if !t.inCell {
+		return
+	}
+	text := strings.TrimSpace(t.cellText.String())
+	if t.inHeader {
+		t.headers = append(t.headers, text)
+	} else {
+		if len(t.cells) == 0 {
+			t.firstHasText = t.cellHasText
+		}
+		t.cells = append(t.cells, text)
+	}
+
+	t.cellText.Reset()
+	t.cellHasText = false
+	t.inCell = false
+	t.inHeader = false
+}
+
+// Finishes the current row, converting it into a schedule once the
+// header row has shown this to be a schedule table
+func (s *ScheduleStream) endRow(t *streamTable) {
+	t.endCell()
+	if !t.inRow {
+		return
+	}
+	t.inRow = false
+
+	cells := t.cells
+	t.cells = nil
+
+	row := t.rows - 1
+	if row == 0 {
+		t.isSchedule = isScheduleHeader(t.headers)
+		if t.isSchedule {
+			t.schedules = make([]Schedule, 0)
+		}
+	}
+	if !t.isSchedule {
+		t.subjectRows = append(t.subjectRows, cells)
+		return
+	}
+	if row == 0 || s.err != nil {
+		return
+	}
+
+	schedule, column, err := parseScheduleRow(cells, t.firstHasText, &t.cachedIndex)
+	if err != nil {
+		scheduleErr := &ScheduleError{
+			Course: s.opts.Course, Subject: s.subject.Id, Table: t.index, Row: row - 1, Column: column, Err: err,
+		}
+		if s.opts.Lenient {
+			s.skipped = append(s.skipped, scheduleErr)
+		} else {
+			s.err = scheduleErr
+		}
+		return
+	}
+	t.schedules = append(t.schedules, schedule)
+}
+
+func (s *ScheduleStream) closeTable() {
+	t := s.current()
+	s.endRow(t)
+	s.tables = s.tables[:len(s.tables)-1]
+
+	if t.isSchedule {
+		subject := s.subject
+		subject.Schedules = t.schedules
+		s.ready = append(s.ready, subject)
+		return
+	}
+	if t.rows >= 2 {
+		s.subject = subjectFromRows(t.subjectRows)
+	}
+}
+
+// Same as FindSchedule but reads the page with a ScheduleStream
+func StreamSchedule(body io.Reader) ([]Subject, error) {
+	subjects := make([]Subject, 0)
+	stream := NewScheduleStream(body)
+	for {
+		subject, err := stream.Next()
+		if err == io.EOF {
+			return subjects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+}
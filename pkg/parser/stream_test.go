@@ -0,0 +1,110 @@
+package parser_test
+
+import (
+	"bytes"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var scheduleFixtures = []string{
+	"acc-y1-single-lesson.html",
+	"acc-y1-single.html",
+	"schedule-with-subject.html",
+	"subject-with-details.html",
+	"main",
+}
+
+func readFixture(tb testing.TB, name string) []byte {
+	body, err := ioutil.ReadFile(filepath.Join("../../testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return body
+}
+
+func TestStreamScheduleMatchesFindSchedule(t *testing.T) {
+	for _, name := range scheduleFixtures {
+		body := readFixture(t, name)
+
+		expected, err := parser.FindSchedule(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		result, err := parser.StreamSchedule(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("%s: expected=%#v got=%#v", name, expected, result)
+		}
+	}
+}
+
+func TestScheduleStreamIsIncremental(t *testing.T) {
+	body := string(readFixture(t, "acc-y1-single-lesson.html"))
+	second := strings.Replace(body, "AB0601", "AB0602", 1)
+
+	// The second page never ends, the first subject must still come out
+	r := io.MultiReader(strings.NewReader(body+"</table>"+second), blockingReader{})
+	stream := parser.NewScheduleStream(r)
+	subject, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject.Id != "AB0601" || len(subject.Schedules) != 3 {
+		t.Errorf("got=%#v", subject)
+	}
+}
+
+// Fails the test instead of blocking forever if read
+type blockingReader struct{}
+
+func (blockingReader) Read(p []byte) (int, error) {
+	panic("stream read past the first subject")
+}
+
+func TestScheduleStreamErrors(t *testing.T) {
+	body := strings.Replace(string(readFixture(t, "acc-y1-single.html")), "1030-1230", "10x0-1230", 1)
+
+	_, err := parser.StreamSchedule(strings.NewReader(body))
+	scheduleErr, ok := err.(*parser.ScheduleError)
+	if !ok {
+		t.Fatalf("expected ScheduleError got=%v", err)
+	}
+	if scheduleErr.Table != 1 || scheduleErr.Row != 5 {
+		t.Errorf("expected table=1 row=5 got table=%d row=%d", scheduleErr.Table, scheduleErr.Row)
+	}
+
+	_, expected := parser.FindSchedule(strings.NewReader(body))
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected=%v got=%v", expected, err)
+	}
+}
+
+func benchmarkSchedule(b *testing.B, find func(io.Reader) ([]parser.Subject, error)) {
+	for _, name := range scheduleFixtures {
+		body := readFixture(b, name)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				if _, err := find(bytes.NewReader(body)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindSchedule(b *testing.B) {
+	benchmarkSchedule(b, parser.FindSchedule)
+}
+
+func BenchmarkStreamSchedule(b *testing.B) {
+	benchmarkSchedule(b, parser.StreamSchedule)
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Labels of the magenta rows below the subject code and title
+const (
+	prerequisiteLabel      = "Prerequisite"
+	mutuallyExclusiveLabel = "Mutually exclusive with"
+	gradeTypeLabel         = "Grade Type"
+	notAvailableLabel      = "Not available"
+)
+
+// Ways a subject can be unavailable to a programme, taken from the
+// "Not available ..." rows
+const (
+	// Not available to Programme
+	ExclusionAny = ""
+	// Not available to all Programme with
+	ExclusionAll = "All"
+	// Not available as Core/PE/UE to Programme
+	ExclusionCore = "Core"
+	ExclusionPE   = "PE"
+	ExclusionUE   = "UE"
+)
+
+// A "Not available ..." row of a subject, As is one of the Exclusion
+// constants
+type Exclusion struct {
+	As         string
+	Programmes []string
+	// Qualifier of the row such as "Admyr 2004-2010", students admitted
+	// in those years. Rows with only a qualifier have no programmes.
+	Admission string `json:",omitempty"`
+}
+
+func (e Exclusion) String() string {
+	text := strings.Join(e.Programmes, ", ")
+	if e.Admission != "" {
+		text = strings.TrimSpace(text + " (" + e.Admission + ")")
+	}
+	if e.As == ExclusionAny {
+		return text
+	}
+	return e.As + ": " + text
+}
+
+var qualifierPattern = regexp.MustCompile(`\(([^()]*)\)`)
+
+// Splits an exclusion value into its programmes and the text of any
+// parenthesised qualifiers, e.g. "(Admyr 2004-2010)"
+func splitExclusion(value string) ([]string, string) {
+	var qualifiers []string
+	for _, match := range qualifierPattern.FindAllStringSubmatch(value, -1) {
+		if q := strings.TrimSpace(match[1]); q != "" {
+			qualifiers = append(qualifiers, q)
+		}
+	}
+	value = qualifierPattern.ReplaceAllString(value, ",")
+	return splitCodes(value), strings.Join(qualifiers, ", ")
+}
+
+// Builds a subject from the cell texts of every row of a subject table.
+// The first row holds the code, title and AUs, the rows after it are
+// "Label: value" pairs where a row without a label continues the value
+// of the previous one.
+func subjectFromRows(rows [][]string) Subject {
+	var subject Subject
+	if len(rows) == 0 {
+		return subject
+	}
+	for i, text := range rows[0] {
+		switch i {
+		case 0:
+			subject.Id = text
+		case 1:
+			subject.Title = text
+		case 2:
+			subject.AuRaw = text
+		}
+	}
+	subject.AU, _ = ParseAU(subject.AuRaw)
+
+	var label string
+	for _, cells := range rows[1:] {
+		if len(cells) == 0 {
+			continue
+		}
+		var value string
+		if cells[0] == "" {
+			value = joinCells(cells[1:])
+		} else if i := strings.Index(cells[0], ":"); i >= 0 {
+			label = strings.TrimSpace(cells[0][:i])
+			value = joinCells(append([]string{cells[0][i+1:]}, cells[1:]...))
+		} else {
+			label = ""
+			value = joinCells(cells)
+		}
+		if value == "" {
+			continue
+		}
+		subject.addDetail(label, value)
+	}
+	return subject
+}
+
+// Joins the non empty cells of a row with spaces
+func joinCells(cells []string) string {
+	var texts []string
+	for _, cell := range cells {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			texts = append(texts, cell)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Splits a comma separated list of subject or programme codes
+func splitCodes(value string) []string {
+	var codes []string
+	for _, code := range strings.Split(value, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+func (s *Subject) addDetail(label, value string) {
+	switch {
+	case strings.EqualFold(label, prerequisiteLabel):
+		s.Prerequisite = strings.TrimSpace(s.Prerequisite + " " + value)
+	case strings.EqualFold(label, mutuallyExclusiveLabel):
+		s.MutuallyExclusive = append(s.MutuallyExclusive, splitCodes(value)...)
+	case strings.EqualFold(label, gradeTypeLabel):
+		s.GradeType = value
+	case strings.HasPrefix(label, notAvailableLabel):
+		as := exclusionKind(label)
+		programmes, admission := splitExclusion(value)
+		// Continuation rows extend the last exclusion of the same kind
+		if n := len(s.Exclusions); n > 0 && s.Exclusions[n-1].As == as {
+			last := &s.Exclusions[n-1]
+			last.Programmes = append(last.Programmes, programmes...)
+			if admission != "" {
+				last.Admission = strings.TrimPrefix(last.Admission+", "+admission, ", ")
+			}
+			return
+		}
+		s.Exclusions = append(s.Exclusions, Exclusion{As: as, Programmes: programmes, Admission: admission})
+	default:
+		if label != "" {
+			value = label + ": " + value
+		}
+		s.Remarks = append(s.Remarks, value)
+	}
+}
+
+// Reads the kind of exclusion out of labels like "Not available as PE
+// to Programme"
+func exclusionKind(label string) string {
+	fields := strings.Fields(label)
+	for i, field := range fields {
+		switch {
+		case strings.EqualFold(field, "all"):
+			return ExclusionAll
+		case strings.EqualFold(field, "as") && i+1 < len(fields):
+			for _, kind := range []string{ExclusionCore, ExclusionPE, ExclusionUE} {
+				if strings.EqualFold(fields[i+1], kind) {
+					return kind
+				}
+			}
+		}
+	}
+	return ExclusionAny
+}
@@ -0,0 +1,16 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Returns the building a venue is in, the part before the first dash of
+// venues like NS4-05-37 or SPMS-TR+5. Venues without a dash such as LT2A
+// or TR+15 are buildings of their own.
+func Building(venue string) string {
+	venue = strings.ToUpper(strings.TrimSpace(venue))
+	if i := strings.Index(venue, "-"); i > 0 {
+		return strings.TrimSpace(venue[:i])
+	}
+	return venue
+}
@@ -0,0 +1,27 @@
+package parser_test
+
+import (
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func TestBuilding(t *testing.T) {
+	cases := []struct {
+		venue    string
+		expected string
+	}{
+		{"NS4-05-37", "NS4"},
+		{"SPMS-TR+5", "SPMS"},
+		{"LT2A", "LT2A"},
+		{" tr+15 ", "TR+15"},
+		{"NORTH SPINE HALL", "NORTH SPINE HALL"},
+		{"-ONLINE", "-ONLINE"},
+		{"", ""},
+	}
+
+	for i, test := range cases {
+		if result := parser.Building(test.venue); result != test.expected {
+			t.Errorf("id=%d expected=%s got=%s", i, test.expected, result)
+		}
+	}
+}
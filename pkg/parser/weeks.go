@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidWeeks = errors.New("parser: invalid teaching weeks")
+)
+
+// Number of teaching weeks in a semester
+const TeachingWeeks = 13
+
+// Set of teaching weeks, bit n-1 is set when the session is held in week n
+type Weeks uint32
+
+// Sessions without a teaching week remark are held every week
+const AllWeeks Weeks = 1<<TeachingWeeks - 1
+
+var weeksPattern = regexp.MustCompile(`(?i)\bwk\s*(\d[\d\s,-]*)`)
+
+// Reads the weeks of remarks like "Teaching Wk2-13" or "Teaching
+// Wk1,3,5". Remarks without weeks mean every week and are not an error.
+func ParseWeeks(remark string) (Weeks, error) {
+	match := weeksPattern.FindStringSubmatch(remark)
+	if match == nil {
+		return AllWeeks, nil
+	}
+
+	var weeks Weeks
+	for _, part := range strings.Split(match[1], ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, to = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			return AllWeeks, fmt.Errorf("%w: %q", ErrInvalidWeeks, remark)
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil || first < 1 || last > TeachingWeeks || first > last {
+			return AllWeeks, fmt.Errorf("%w: %q", ErrInvalidWeeks, remark)
+		}
+		for week := first; week <= last; week++ {
+			weeks |= 1 << (week - 1)
+		}
+	}
+	if weeks == 0 {
+		return AllWeeks, fmt.Errorf("%w: %q", ErrInvalidWeeks, remark)
+	}
+	return weeks, nil
+}
+
+// The weeks the session is held, every week when the remark has none or
+// cannot be read
+func (s *Schedule) Weeks() Weeks {
+	weeks, _ := ParseWeeks(s.Remark)
+	return weeks
+}
+
+func (w Weeks) Has(week int) bool {
+	return week >= 1 && week <= TeachingWeeks && w&(1<<(week-1)) != 0
+}
+
+func (w Weeks) Overlaps(o Weeks) bool {
+	return w&o != 0
+}
+
+// Writes the weeks like the remarks do, e.g. 1-13 or 1,3,5-7
+func (w Weeks) String() string {
+	var parts []string
+	for week := 1; week <= TeachingWeeks; week++ {
+		if !w.Has(week) {
+			continue
+		}
+		last := week
+		for last < TeachingWeeks && w.Has(last+1) {
+			last++
+		}
+		switch {
+		case last == week:
+			parts = append(parts, strconv.Itoa(week))
+		case last == week+1:
+			parts = append(parts, strconv.Itoa(week), strconv.Itoa(last))
+		default:
+			parts = append(parts, fmt.Sprintf("%d-%d", week, last))
+		}
+		week = last
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w Weeks) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
@@ -0,0 +1,56 @@
+package parser_test
+
+import (
+	"errors"
+	"github.com/jaxsax/ntu-room-finder/pkg/parser"
+	"testing"
+)
+
+func TestParseWeeks(t *testing.T) {
+	cases := []struct {
+		remark      string
+		expected    string
+		expectedErr error
+	}{
+		{"", "1-13", nil},
+		{"Online", "1-13", nil},
+		{"Teaching Wk11", "11", nil},
+		{"Teaching Wk2-13", "2-13", nil},
+		{"Teaching Wk1,3,5,7,9,11,13", "1,3,5,7,9,11,13", nil},
+		{"Teaching Wk1-6, 8-13", "1-6,8-13", nil},
+		{"teaching wk 4,5", "4,5", nil},
+		{"Teaching Wk0", "1-13", parser.ErrInvalidWeeks},
+		{"Teaching Wk14", "1-13", parser.ErrInvalidWeeks},
+		{"Teaching Wk5-2", "1-13", parser.ErrInvalidWeeks},
+		{"Teaching Wk1--2", "1-13", parser.ErrInvalidWeeks},
+	}
+
+	for i, test := range cases {
+		result, err := parser.ParseWeeks(test.remark)
+		if result.String() != test.expected {
+			t.Errorf("id=%d expected=%s got=%s", i, test.expected, result)
+		}
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expectedErr, err)
+		}
+	}
+}
+
+func TestWeeksOverlaps(t *testing.T) {
+	odd, _ := parser.ParseWeeks("Teaching Wk1,3,5,7,9,11,13")
+	even, _ := parser.ParseWeeks("Teaching Wk2,4,6,8,10,12")
+	cases := []struct {
+		a, b     parser.Weeks
+		expected bool
+	}{
+		{odd, even, false},
+		{odd, parser.AllWeeks, true},
+		{even, even, true},
+	}
+
+	for i, test := range cases {
+		if result := test.a.Overlaps(test.b); result != test.expected {
+			t.Errorf("id=%d expected=%v got=%v", i, test.expected, result)
+		}
+	}
+}
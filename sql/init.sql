@@ -1,10 +1,17 @@
 DROP TABLE IF EXISTS schedule;
 
+-- uid, index_uid and subject_uid are stable IDs that stay the same across
+-- snapshots of a semester, see docs/flow.md
 CREATE TABLE IF NOT EXISTS schedule (
+    uid STRING NOT NULL,
+    index_uid STRING NOT NULL,
+    subject_uid STRING NOT NULL,
     schedule_index STRING NOT NULL,
     schedule_type STRING NOT NULL,
     schedule_group INT NOT NULL,
     day STRING NOT NULL,
+    -- 1 for MON to 7 for SUN, 0 when the day is not recognised
+    day_number INT NOT NULL,
     timeText STRING NOT NULL,
     timeStart STRING NOT NULL,
     timeEnd STRING NOT NULL,
@@ -12,13 +19,72 @@ CREATE TABLE IF NOT EXISTS schedule (
     remark STRING
 );
 
+DROP TABLE IF EXISTS course;
+
+-- Programmes and elective lists, decoded from keys like ACC;GA;1;F
+CREATE TABLE IF NOT EXISTS course (
+    uid STRING NOT NULL,
+    course_key STRING NOT NULL,
+    title STRING NOT NULL,
+    -- programme, minor, general-education, general-elective, scholars or other
+    kind STRING NOT NULL,
+    programme STRING NOT NULL,
+    specialisation STRING NOT NULL,
+    -- 0 for elective lists
+    year INT NOT NULL,
+    part_time INT NOT NULL,
+    general_elective INT NOT NULL
+);
+
 DROP TABLE IF EXISTS subject;
 
 CREATE TABLE IF NOT EXISTS subject (
+    uid STRING NOT NULL,
     id STRING NOT NULL,
+    course_uid STRING NOT NULL,
+    course_key STRING NOT NULL,
+    index_uid STRING NOT NULL,
     schedule_index NOT NULL,
     title STRING NOT NULL,
-    rawAU STRING NOT NULL
+    rawAU STRING NOT NULL,
+    -- NULL when rawAU is not a number, equal unless rawAU is a range
+    au_min REAL,
+    au_max REAL,
+    prerequisite STRING,
+    -- Comma separated subject codes
+    mutually_exclusive STRING,
+    grade_type STRING
+);
+
+DROP TABLE IF EXISTS subject_exclusion;
+
+-- Programmes a subject is not available to, exclusion_as is empty when
+-- it is not available at all, otherwise one of All, Core, PE or UE.
+-- admission qualifies the row, e.g. Admyr 2004-2010, and programme is
+-- empty when a row only has the qualifier.
+CREATE TABLE IF NOT EXISTS subject_exclusion (
+    subject_uid STRING NOT NULL,
+    subject_id STRING NOT NULL,
+    exclusion_as STRING NOT NULL,
+    programme STRING NOT NULL,
+    admission STRING NOT NULL
+);
+
+DROP TABLE IF EXISTS exam;
+
+-- Papers of the exam timetable, venue is empty when it is not listed
+CREATE TABLE IF NOT EXISTS exam (
+    subject_uid STRING NOT NULL,
+    subject_id STRING NOT NULL,
+    title STRING NOT NULL,
+    day STRING NOT NULL,
+    day_number INT NOT NULL,
+    date_text STRING NOT NULL,
+    time_text STRING NOT NULL,
+    timeStart STRING NOT NULL,
+    timeEnd STRING NOT NULL,
+    duration_minutes INT NOT NULL,
+    venue STRING NOT NULL
 );
 
 DROP VIEW IF EXISTS schedule_d;
@@ -27,21 +93,25 @@ CREATE VIEW schedule_d AS SELECT DISTINCT * FROM schedule;
 DROP VIEW IF EXISTS subject_d;
 CREATE VIEW subject_d AS SELECT DISTINCT * FROM subject;
 
+DROP VIEW IF EXISTS subject_exclusion_d;
+CREATE VIEW subject_exclusion_d AS SELECT DISTINCT * FROM subject_exclusion;
+
 DROP VIEW IF EXISTS schedule_day;
 CREATE VIEW schedule_day AS
-    SELECT  schedule_index, schedule_type, schedule_group, day,
-            (case
-                when day = 'MON' then 1
-                when day = 'TUE' then 2
-                when day = 'WED' then 3
-                when day = 'THU' then 4
-                when day = 'FRI' then 5
-                when day = 'SAT' then 6
-                when day = 'SUN' then 7
-            end) AS day_number,
+    SELECT  uid, index_uid, subject_uid, schedule_index, schedule_type, schedule_group, day, day_number,
             timeText, timeStart, timeEnd, venue, remark
     FROM schedule_d;
 
 DROP VIEW IF EXISTS tutorial_rooms;
 CREATE VIEW tutorial_rooms AS
     SELECT * FROM schedule_day WHERE schedule_type = 'TUT';
+
+DROP VIEW IF EXISTS course_schedule;
+-- Sessions with the course they are listed under, e.g. year 2 EEE tutorials:
+-- SELECT * FROM course_schedule WHERE programme = 'EEE' AND year = 2 AND schedule_type = 'TUT';
+CREATE VIEW course_schedule AS
+    SELECT DISTINCT c.uid AS course_uid, c.course_key, c.programme, c.year, c.part_time, c.general_elective,
+            s.id AS subject_id, d.*
+    FROM course c
+    JOIN subject_d s ON s.course_uid = c.uid
+    JOIN schedule_day d ON d.index_uid = s.index_uid;